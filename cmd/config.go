@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/deicod/gopenusage/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect gopenusage configuration",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the effective configuration and where each value came from",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if activeConfig.Path != "" {
+			cmd.Printf("# config file: %s\n", activeConfig.Path)
+		} else {
+			cmd.Printf("# config file: none (looked for %s)\n", config.DefaultPath())
+		}
+
+		var values []config.Value
+		for _, c := range []*cobra.Command{serveCmd, queryCmd} {
+			resolved, err := applyConfig(c, activeConfig)
+			if err != nil {
+				return err
+			}
+			values = append(values, resolved...)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		for _, v := range values {
+			_, _ = fmt.Fprintf(w, "%s = %s\t# %s\n", v.Key, strconv.Quote(v.Value), v.Source)
+		}
+		if activeConfig.Alerts != nil {
+			source := "file " + activeConfig.Path
+			_, _ = fmt.Fprintf(w, "alerts.sinks = %d\t# %s\n", len(activeConfig.Alerts.Sinks), source)
+			_, _ = fmt.Fprintf(w, "alerts.rules = %d\t# %s\n", len(activeConfig.Alerts.Rules), source)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configPrintCmd)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/deicod/gopenusage/internal/config"
+	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/builtin"
+	"github.com/spf13/cobra"
+)
+
+var pluginsLintDir string
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "Inspect plugin manifests",
+}
+
+var pluginsLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Validate every plugin.json and report all problems",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return lintPlugins(cmd, pluginsLintDir)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pluginsCmd)
+	pluginsCmd.AddCommand(pluginsLintCmd)
+
+	pluginsLintCmd.Flags().StringVar(&pluginsLintDir, "plugins-dir", openusage.DefaultPluginsDir, "path to plugin manifests")
+
+	commandSettings[pluginsLintCmd] = commandConfig{
+		settings: []config.Setting{
+			{Key: "serve.plugins_dir", Flag: "plugins-dir"},
+		},
+	}
+}
+
+func lintPlugins(cmd *cobra.Command, pluginsDir string) error {
+	problems, err := openusage.LintManifests(pluginsDir, builtin.PluginFactories())
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	for _, problem := range problems {
+		_, _ = fmt.Fprintln(out, problem.Error())
+	}
+	if len(problems) > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d problem(s) in %s", len(problems), pluginsDir)
+	}
+	_, _ = fmt.Fprintf(out, "%s: manifests OK\n", pluginsDir)
+	return nil
+}
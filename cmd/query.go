@@ -1,11 +1,15 @@
 package cmd
 
 import (
-	"encoding/json"
+	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/deicod/gopenusage/internal/config"
+	"github.com/deicod/gopenusage/internal/render"
+	"github.com/deicod/gopenusage/pkg/openusage"
 	openusageclient "github.com/deicod/gopenusage/pkg/openusage/client"
 	"github.com/spf13/cobra"
 )
@@ -15,6 +19,8 @@ var (
 	queryPlugin  string
 	querySocket  string
 	queryTimeout time.Duration
+	queryOutput  string
+	queryScope   string
 )
 
 var queryCmd = &cobra.Command{
@@ -27,34 +33,40 @@ var queryCmd = &cobra.Command{
 			pluginID = strings.TrimSpace(args[0])
 		}
 
+		format, color := resolveQueryOutput(queryOutput, os.Stdout)
+		if !slices.Contains(render.Formats, format) {
+			return fmt.Errorf("unknown output format %q (want one of %s)", format, strings.Join(render.Formats, ", "))
+		}
+
 		socketPath := resolveQuerySocketPath(cmd)
 
 		client, err := openusageclient.New(openusageclient.Options{
 			BaseURL:    queryBaseURL,
 			SocketPath: socketPath,
 			Timeout:    queryTimeout,
+			Scope:      queryScope,
 		})
 		if err != nil {
 			return err
 		}
 
-		var payload any
+		var outputs []openusage.PluginOutput
 		if pluginID == "" {
-			payload, err = client.QueryAll(cmd.Context())
+			outputs, err = client.QueryAll(cmd.Context())
 		} else {
-			payload, err = client.QueryOne(cmd.Context(), pluginID)
+			var output openusage.PluginOutput
+			output, err = client.QueryOne(cmd.Context(), pluginID)
+			outputs = []openusage.PluginOutput{output}
 		}
 		if err != nil {
 			return err
 		}
 
-		pretty, err := json.MarshalIndent(payload, "", "  ")
-		if err != nil {
-			return err
-		}
-
-		cmd.Println(string(pretty))
-		return nil
+		return render.Write(cmd.OutOrStdout(), outputs, render.Options{
+			Format: format,
+			Color:  color,
+			Single: pluginID != "",
+		})
 	},
 }
 
@@ -65,6 +77,30 @@ func init() {
 	queryCmd.Flags().StringVar(&queryPlugin, "plugin", "", "plugin id to query")
 	queryCmd.Flags().StringVar(&querySocket, "socket", "", "unix socket path (auto-detected when --url is not set)")
 	queryCmd.Flags().DurationVar(&queryTimeout, "timeout", 15*time.Second, "request timeout")
+	queryCmd.Flags().StringVar(&queryScope, "scope", "", "only show overview, detail or primary lines, as declared in plugin manifests")
+	queryCmd.Flags().StringVarP(&queryOutput, "output", "o", "", "output format: "+strings.Join(render.Formats, ", ")+" (default table on a terminal, json otherwise)")
+
+	commandSettings[queryCmd] = commandConfig{settings: []config.Setting{
+		{Key: "query.url", Flag: "url"},
+		{Key: "query.socket", Flag: "socket"},
+		{Key: "query.timeout", Flag: "timeout"},
+		{Key: "query.output", Flag: "output"},
+		{Key: "query.scope", Flag: "scope"},
+	}}
+}
+
+// resolveQueryOutput picks the output format and whether to use colors. An
+// empty format means table on a terminal and json when piped.
+func resolveQueryOutput(format string, stdout *os.File) (string, bool) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		if render.IsTerminal(stdout) {
+			format = render.FormatTable
+		} else {
+			format = render.FormatJSON
+		}
+	}
+	return format, format == render.FormatTable && render.ColorEnabled(stdout)
 }
 
 func resolveQuerySocketPath(cmd *cobra.Command) string {
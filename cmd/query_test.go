@@ -72,3 +72,21 @@ func newTestQueryCommand(t *testing.T) *cobra.Command {
 	cmd.Flags().String("url", "", "base URL")
 	return cmd
 }
+
+func TestResolveQueryOutputDefaultsToJSONWhenPiped(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatalf("create output file: %v", err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+
+	format, color := resolveQueryOutput("", f)
+	if format != "json" || color {
+		t.Fatalf("unexpected output: %s color=%v", format, color)
+	}
+
+	format, color = resolveQueryOutput(" Table ", f)
+	if format != "table" || color {
+		t.Fatalf("unexpected explicit output: %s color=%v", format, color)
+	}
+}
@@ -6,14 +6,83 @@ package cmd
 import (
 	"os"
 
+	"github.com/deicod/gopenusage/internal/config"
+	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/builtin"
 	"github.com/spf13/cobra"
 )
 
+var (
+	configPath string
+	// activeConfig is the loaded config file; empty when none exists.
+	activeConfig = &config.File{}
+	// commandSettings lists the config keys each command reads.
+	commandSettings = map[*cobra.Command]commandConfig{}
+)
+
+type commandConfig struct {
+	settings []config.Setting
+	plugins  []config.PluginSetting
+}
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "gopenusage",
 	Short: "Track AI coding subscriptions via CLI and JSON API",
 	Long:  "A Cobra CLI for querying OpenUsage-compatible providers, serving a JSON API, and querying that API from the terminal.",
+	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		file, err := config.Load(configPath)
+		if err != nil {
+			return err
+		}
+		activeConfig = file
+
+		_, err = applyConfig(cmd, file)
+		return err
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "config file (default ${XDG_CONFIG_HOME}/gopenusage/config.toml, or $"+config.EnvConfig+")")
+}
+
+// applyConfig fills the command's unset flags from the environment and the
+// config file, returning the effective values with their sources.
+func applyConfig(cmd *cobra.Command, file *config.File) ([]config.Value, error) {
+	bindings, ok := commandSettings[cmd]
+	if !ok {
+		return nil, nil
+	}
+
+	values, err := config.Resolve(cmd.Flags(), file, bindings.settings, os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	pluginValues, err := config.ResolvePlugins(cmd.Flags(), file, bindings.plugins, knownPluginIDs(cmd), os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	return append(values, pluginValues...), nil
+}
+
+// knownPluginIDs returns the builtin plugin IDs and those of the manifests in
+// the command's plugins dir, which per-plugin environment variables are
+// matched against.
+func knownPluginIDs(cmd *cobra.Command) []string {
+	var ids []string
+	for _, plugin := range builtin.Plugins() {
+		ids = append(ids, plugin.ID())
+	}
+	if flag := cmd.Flags().Lookup("plugins-dir"); flag != nil {
+		dir := flag.Value.String()
+		if dir == "" {
+			dir = openusage.DefaultPluginsDir
+		}
+		if _, order, err := openusage.LoadManifests(dir); err == nil {
+			ids = append(ids, order...)
+		}
+	}
+	return ids
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
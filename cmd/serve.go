@@ -1,43 +1,161 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/deicod/gopenusage/internal/api"
+	"github.com/deicod/gopenusage/internal/config"
 	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/alerts"
 	"github.com/deicod/gopenusage/pkg/openusage/builtin"
+	"github.com/deicod/gopenusage/pkg/openusage/history"
+	"github.com/deicod/gopenusage/pkg/openusage/metrics"
 	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
 	"github.com/spf13/cobra"
 )
 
 var (
-	serveAddr       string
-	servePluginsDir string
-	serveDataDir    string
+	serveAddr          string
+	servePluginsDir    string
+	serveDataDir       string
+	serveConcurrency   int
+	servePluginTimeout time.Duration
+	serveCacheTTL      time.Duration
+	servePollInterval  time.Duration
+	servePollIntervals map[string]string
+	serveHistory       bool
+	serveRetention     time.Duration
+	serveAlerts        string
+	serveMetricsAddr   string
+	serveTimeouts      map[string]string
+	serveCredentials   map[string]string
+	serveLogsDirs      map[string]string
+	serveNames         map[string]string
+	serveEnabled       []string
+	serveDisabled      []string
+	serveStrict        bool
 )
 
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Run the OpenUsage JSON API service",
 	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		dataDir := serveDataDir
+		if dataDir == "" {
+			dataDir = pluginruntime.DefaultDataDir()
+		}
+
+		var historyStore *history.Store
+		var usageHistory openusage.UsageHistory
+		if serveHistory {
+			store, err := history.Open(filepath.Join(dataDir, "history"), history.Options{
+				Retention: serveRetention,
+			})
+			if err != nil {
+				return err
+			}
+			historyStore = store
+			usageHistory = store
+			go historyStore.Maintain(ctx, time.Hour)
+		}
+
+		timeouts, err := parseDurationMap("plugin timeout", serveTimeouts)
+		if err != nil {
+			return err
+		}
+
+		collector := metrics.NewCollector()
 		manager, err := openusage.NewManager(openusage.Options{
-			PluginsDir: servePluginsDir,
-			DataDir:    serveDataDir,
+			PluginsDir:        servePluginsDir,
+			DataDir:           dataDir,
+			Concurrency:       serveConcurrency,
+			PluginTimeout:     servePluginTimeout,
+			PluginTimeouts:    timeouts,
+			CacheTTL:          serveCacheTTL,
+			PluginCredentials: serveCredentials,
+			PluginLogsDirs:    serveLogsDirs,
+			Accounts:          serveAccounts(serveNames, serveCredentials, serveLogsDirs, serveTimeouts, servePollIntervals),
+			EnabledPlugins:    serveEnabled,
+			DisabledPlugins:   serveDisabled,
+			History:           usageHistory,
+			Metrics:           collector,
+			PluginFactories:   builtin.PluginFactories(),
+			StrictManifests:   serveStrict,
 		}, builtin.Plugins())
 		if err != nil {
 			return err
 		}
+		manager.OnResult(collector.Record)
+
+		if historyStore != nil {
+			manager.OnResult(func(output openusage.PluginOutput) {
+				if err := historyStore.Record(output); err != nil {
+					cmd.PrintErrf("history: record %s: %v\n", output.ProviderID, err)
+				}
+			})
+		}
+
+		engine, err := loadAlertEngine(serveAlerts, dataDir, activeConfig.Alerts)
+		if err != nil {
+			return err
+		}
+		if engine != nil {
+			// Deliver from a single worker so rule state sees results in order
+			// and slow sinks never hold up plugin fetches.
+			pending := make(chan openusage.PluginOutput, 64)
+			go func() {
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case output := <-pending:
+						engine.Handle(ctx, output)
+					}
+				}
+			}()
+			manager.OnResult(func(output openusage.PluginOutput) {
+				select {
+				case pending <- output:
+				default:
+					cmd.PrintErrf("alerts: queue full, dropping result for %s\n", output.ProviderID)
+				}
+			})
+		}
 
-		server := api.NewServer(manager)
+		if servePollInterval > 0 {
+			intervals, err := parseDurationMap("poll interval", servePollIntervals)
+			if err != nil {
+				return err
+			}
+			scheduler := openusage.NewScheduler(manager, openusage.SchedulerOptions{
+				Interval:  servePollInterval,
+				Intervals: intervals,
+			})
+			if err := scheduler.Start(ctx); err != nil {
+				return err
+			}
+			defer scheduler.Stop()
+		}
+
+		server := api.NewServer(manager, api.Options{History: historyStore, Metrics: collector})
 		httpServer := &http.Server{
 			Addr:    serveAddr,
 			Handler: server.Handler(),
 		}
+		httpServer.RegisterOnShutdown(server.Close)
 
 		listener, listenAddr, cleanup, err := createListener(serveAddr)
 		if err != nil {
@@ -45,6 +163,35 @@ var serveCmd = &cobra.Command{
 		}
 		defer cleanup()
 
+		servers := []*http.Server{httpServer}
+		if serveMetricsAddr != "" {
+			metricsListener, metricsAddr, metricsCleanup, err := createListener(serveMetricsAddr)
+			if err != nil {
+				return err
+			}
+			defer metricsCleanup()
+
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", collector)
+			metricsServer := &http.Server{Handler: mux}
+			servers = append(servers, metricsServer)
+			go func() {
+				if err := metricsServer.Serve(metricsListener); err != nil && err != http.ErrServerClosed {
+					cmd.PrintErrf("metrics server error: %v\n", err)
+				}
+			}()
+			cmd.Printf("metrics on %s\n", metricsAddr)
+		}
+
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for _, srv := range servers {
+				_ = srv.Shutdown(shutdownCtx)
+			}
+		}()
+
 		cmd.Printf("listening on %s\n", listenAddr)
 		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
 			return fmt.Errorf("server error: %w", err)
@@ -59,6 +206,113 @@ func init() {
 	serveCmd.Flags().StringVar(&serveAddr, "addr", defaultServeAddr(), "listen address (e.g. :8080 or unix:///path/to.sock)")
 	serveCmd.Flags().StringVar(&servePluginsDir, "plugins-dir", "", "path to plugin manifests (optional)")
 	serveCmd.Flags().StringVar(&serveDataDir, "data-dir", pluginruntime.DefaultDataDir(), "state directory for plugin data")
+	serveCmd.Flags().IntVar(&serveConcurrency, "concurrency", openusage.DefaultConcurrency, "maximum number of plugins queried at once")
+	serveCmd.Flags().DurationVar(&servePluginTimeout, "plugin-timeout", openusage.DefaultPluginTimeout, "deadline for a single plugin query")
+	serveCmd.Flags().DurationVar(&serveCacheTTL, "cache-ttl", openusage.DefaultCacheTTL, "how long plugin results are served from cache before refreshing (negative disables caching)")
+	serveCmd.Flags().DurationVar(&servePollInterval, "poll-interval", openusage.DefaultPollInterval, "default background poll interval per plugin (0 disables polling)")
+	serveCmd.Flags().BoolVar(&serveHistory, "history", true, "record progress lines under <data-dir>/history and serve /v1/history")
+	serveCmd.Flags().DurationVar(&serveRetention, "history-retention", history.DefaultRetention, "how long recorded usage history is kept")
+	serveCmd.Flags().StringVar(&serveAlerts, "alerts", "", "alert rules file (default <data-dir>/alerts.json when present)")
+	serveCmd.Flags().StringVar(&serveMetricsAddr, "metrics-addr", "", "additional listen address serving only /metrics (e.g. :9464)")
+	serveCmd.Flags().StringToStringVar(&servePollIntervals, "poll-intervals", nil, "per-plugin poll intervals (e.g. claude=2m,copilot=15m; negative disables polling for a plugin)")
+	serveCmd.Flags().StringToStringVar(&serveTimeouts, "plugin-timeouts", nil, "per-plugin query deadlines (e.g. antigravity=30s)")
+	serveCmd.Flags().StringToStringVar(&serveCredentials, "plugin-credentials", nil, "per-plugin credential paths (e.g. claude=~/work/.credentials.json)")
+	serveCmd.Flags().StringToStringVar(&serveLogsDirs, "plugin-logs-dirs", nil, "per-plugin local log dirs (e.g. codex-logs=~/work/.codex/sessions)")
+	serveCmd.Flags().StringToStringVar(&serveNames, "plugin-names", nil, "display names for accounts (e.g. copilot@work=Copilot Work)")
+	serveCmd.Flags().StringSliceVar(&serveEnabled, "enable-plugins", nil, "only run these plugins (default: all)")
+	serveCmd.Flags().StringSliceVar(&serveDisabled, "disable-plugins", nil, "never run these plugins")
+	serveCmd.Flags().BoolVar(&serveStrict, "strict", false, "refuse to start when any plugin manifest is invalid (see plugins lint)")
+
+	commandSettings[serveCmd] = commandConfig{
+		settings: []config.Setting{
+			{Key: "serve.addr", Flag: "addr"},
+			{Key: "serve.metrics_addr", Flag: "metrics-addr"},
+			{Key: "serve.plugins_dir", Flag: "plugins-dir"},
+			{Key: "serve.data_dir", Flag: "data-dir"},
+			{Key: "serve.concurrency", Flag: "concurrency"},
+			{Key: "serve.plugin_timeout", Flag: "plugin-timeout"},
+			{Key: "serve.cache_ttl", Flag: "cache-ttl"},
+			{Key: "serve.poll_interval", Flag: "poll-interval"},
+			{Key: "serve.history", Flag: "history"},
+			{Key: "serve.history_retention", Flag: "history-retention"},
+			{Key: "serve.alerts", Flag: "alerts"},
+			{Key: "serve.strict", Flag: "strict"},
+			{Key: "plugins.enabled", Flag: "enable-plugins"},
+			{Key: "plugins.disabled", Flag: "disable-plugins"},
+		},
+		plugins: []config.PluginSetting{
+			{Field: "poll_interval", Flag: "poll-intervals"},
+			{Field: "timeout", Flag: "plugin-timeouts"},
+			{Field: "credentials", Flag: "plugin-credentials"},
+			{Field: "logs_dir", Flag: "plugin-logs-dirs"},
+			{Field: "name", Flag: "plugin-names"},
+		},
+	}
+}
+
+// serveAccounts returns an account for every "<plugin>@<name>" ID used in
+// the per-plugin settings, named from names.
+func serveAccounts(names map[string]string, settings ...map[string]string) []openusage.Account {
+	seen := make(map[string]bool)
+	var accounts []openusage.Account
+	for _, values := range append([]map[string]string{names}, settings...) {
+		for id := range values {
+			id = strings.TrimSpace(id)
+			if !strings.Contains(id, "@") || seen[id] {
+				continue
+			}
+			seen[id] = true
+			accounts = append(accounts, openusage.Account{ID: id, DisplayName: strings.TrimSpace(names[id])})
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	return accounts
+}
+
+// parseDurationMap parses per-plugin duration flags such as --poll-intervals.
+func parseDurationMap(what string, raw map[string]string) (map[string]time.Duration, error) {
+	out := make(map[string]time.Duration, len(raw))
+	for id, value := range raw {
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s for %q: %w", what, id, err)
+		}
+		out[strings.TrimSpace(id)] = d
+	}
+	return out, nil
+}
+
+// loadAlertEngine builds the alerting engine from, in order of preference,
+// an explicit rules file, the [alerts] table of the config file, or
+// <data-dir>/alerts.json. Without any of them alerting is disabled.
+func loadAlertEngine(path, dataDir string, fromConfig *alerts.Config) (*alerts.Engine, error) {
+	var cfg alerts.Config
+	switch {
+	case path != "":
+		loaded, err := alerts.LoadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		cfg = loaded
+	case fromConfig != nil:
+		cfg = *fromConfig
+	default:
+		path = filepath.Join(dataDir, "alerts.json")
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, nil
+		}
+		loaded, err := alerts.LoadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		cfg = loaded
+	}
+
+	sinks, err := cfg.BuildSinks()
+	if err != nil {
+		return nil, err
+	}
+	return alerts.NewEngine(cfg.Rules, sinks), nil
 }
 
 func createListener(rawAddr string) (net.Listener, string, func(), error) {
@@ -3,8 +3,12 @@ package cmd
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
 )
 
 func TestCreateListenerTCP(t *testing.T) {
@@ -67,3 +71,65 @@ func TestCreateUnixListenerRejectsNonSocketPath(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestParseDurationMap(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseDurationMap("poll interval", map[string]string{"claude": "2m", "copilot": " 15m "})
+	if err != nil {
+		t.Fatalf("parsePollIntervals error: %v", err)
+	}
+	if got["claude"] != 2*time.Minute || got["copilot"] != 15*time.Minute {
+		t.Fatalf("unexpected intervals: %v", got)
+	}
+
+	if _, err := parseDurationMap("poll interval", map[string]string{"claude": "soon"}); err == nil {
+		t.Fatalf("expected error for invalid duration")
+	}
+}
+
+func TestServeAccounts(t *testing.T) {
+	t.Parallel()
+
+	got := serveAccounts(
+		map[string]string{"copilot@work": "Copilot Work", "claude": "Claude"},
+		map[string]string{"copilot@work": "/srv/work.json", "claude@client": "/srv/client.json"},
+		map[string]string{"copilot": "30s"},
+	)
+	want := []openusage.Account{
+		{ID: "claude@client"},
+		{ID: "copilot@work", DisplayName: "Copilot Work"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected accounts: %+v", got)
+	}
+}
+
+func TestLoadAlertEngineDefaultPathIsOptional(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	engine, err := loadAlertEngine("", dir, nil)
+	if err != nil {
+		t.Fatalf("loadAlertEngine error: %v", err)
+	}
+	if engine != nil {
+		t.Fatalf("expected no engine without alerts.json")
+	}
+
+	if _, err := loadAlertEngine(filepath.Join(dir, "missing.json"), dir, nil); err == nil {
+		t.Fatalf("expected error for explicit missing file")
+	}
+
+	content := `{"sinks":[{"name":"log","type":"exec","command":["true"]}],"rules":[{"percent":80}]}`
+	if err := os.WriteFile(filepath.Join(dir, "alerts.json"), []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	engine, err = loadAlertEngine("", dir, nil)
+	if err != nil {
+		t.Fatalf("loadAlertEngine error: %v", err)
+	}
+	if engine == nil {
+		t.Fatalf("expected engine from default alerts.json")
+	}
+}
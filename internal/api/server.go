@@ -2,20 +2,50 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/history"
 )
 
+type Options struct {
+	// History enables /v1/history when set.
+	History *history.Store
+	// HeartbeatInterval defaults to DefaultHeartbeatInterval.
+	HeartbeatInterval time.Duration
+	// Metrics is served at /metrics when set.
+	Metrics http.Handler
+}
+
 type Server struct {
-	manager *openusage.Manager
-	mux     *http.ServeMux
+	manager   *openusage.Manager
+	history   *history.Store
+	events    *eventHub
+	heartbeat time.Duration
+	mux       *http.ServeMux
 }
 
-func NewServer(manager *openusage.Manager) *Server {
-	s := &Server{manager: manager, mux: http.NewServeMux()}
+func NewServer(manager *openusage.Manager, opts Options) *Server {
+	heartbeat := opts.HeartbeatInterval
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeatInterval
+	}
+
+	s := &Server{
+		manager:   manager,
+		history:   opts.History,
+		events:    newEventHub(),
+		heartbeat: heartbeat,
+		mux:       http.NewServeMux(),
+	}
+	manager.OnResult(s.events.publish)
 	s.routes()
+	if opts.Metrics != nil {
+		s.mux.Handle("/metrics", opts.Metrics)
+	}
 	return s
 }
 
@@ -23,10 +53,19 @@ func (s *Server) Handler() http.Handler {
 	return s.mux
 }
 
+// Close ends open usage streams so the HTTP server can shut down.
+func (s *Server) Close() {
+	s.events.close()
+}
+
 func (s *Server) routes() {
 	s.mux.HandleFunc("/healthz", s.handleHealth)
 	s.mux.HandleFunc("/v1/usage", s.handleUsage)
 	s.mux.HandleFunc("/v1/usage/", s.handleUsageByPlugin)
+	s.mux.HandleFunc("/v1/usage/stream", s.handleUsageStream)
+	s.mux.HandleFunc("/v1/history", s.handleHistory)
+	s.mux.HandleFunc("/v1/plugins", s.handlePlugins)
+	s.mux.HandleFunc("/v1/plugins/", s.handlePluginByID)
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
@@ -39,12 +78,20 @@ func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	scope, err := parseScope(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	ids := parseIDs(strings.TrimSpace(r.URL.Query().Get("plugins")))
 	outputs, err := s.manager.QueryAll(r.Context(), ids)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeQueryError(w, err)
 		return
 	}
+	for i, output := range outputs {
+		outputs[i] = openusage.FilterScope(output, scope)
+	}
 
 	writeJSON(w, http.StatusOK, outputs)
 }
@@ -70,14 +117,121 @@ func (s *Server) handleUsageByPlugin(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "unknown plugin")
 		return
 	}
+	scope, err := parseScope(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	output, err := s.manager.QueryOne(r.Context(), pluginID)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, openusage.FilterScope(output, scope))
+}
+
+func (s *Server) handlePlugins(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.manager.Plugins())
+}
+
+func (s *Server) handlePluginByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	pluginID := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/v1/plugins/"))
+	if pluginID == "" {
+		writeError(w, http.StatusBadRequest, "plugin id is required")
+		return
+	}
+	info, ok := s.manager.Plugin(pluginID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown plugin")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.history == nil {
+		writeError(w, http.StatusNotFound, "history is disabled")
+		return
+	}
+
+	query, err := parseHistoryQuery(r, time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	series, err := s.history.Query(query)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, output)
+	writeJSON(w, http.StatusOK, series)
+}
+
+// parseHistoryQuery reads plugins, label, from, to, range and step. The window
+// defaults to the 24h before to (or now); range is relative to to and is
+// ignored when from is set.
+func parseHistoryQuery(r *http.Request, now time.Time) (history.Query, error) {
+	values := r.URL.Query()
+	query := history.Query{
+		ProviderIDs: parseIDs(strings.TrimSpace(values.Get("plugins"))),
+		Label:       strings.TrimSpace(values.Get("label")),
+		To:          now,
+	}
+
+	if raw := strings.TrimSpace(values.Get("to")); raw != "" {
+		to, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return history.Query{}, fmt.Errorf("invalid to: %w", err)
+		}
+		query.To = to
+	}
+
+	window := 24 * time.Hour
+	if raw := strings.TrimSpace(values.Get("range")); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return history.Query{}, fmt.Errorf("invalid range %q", raw)
+		}
+		window = d
+	}
+	query.From = query.To.Add(-window)
+
+	if raw := strings.TrimSpace(values.Get("from")); raw != "" {
+		from, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return history.Query{}, fmt.Errorf("invalid from: %w", err)
+		}
+		query.From = from
+	}
+
+	if raw := strings.TrimSpace(values.Get("step")); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return history.Query{}, fmt.Errorf("invalid step %q", raw)
+		}
+		query.Step = d
+	}
+
+	return query, nil
 }
 
 func writeJSON(w http.ResponseWriter, status int, value any) {
@@ -90,6 +244,27 @@ func writeError(w http.ResponseWriter, status int, message string) {
 	writeJSON(w, status, map[string]string{"error": message})
 }
 
+// parseScope reads the scope parameter, which limits outputs to overview,
+// detail or primary lines.
+func parseScope(r *http.Request) (string, error) {
+	scope := strings.TrimSpace(r.URL.Query().Get("scope"))
+	if !openusage.ValidScope(scope) {
+		return "", fmt.Errorf("invalid scope %q (want %s, %s or %s)", scope, openusage.ScopeOverview, openusage.ScopeDetail, openusage.ScopePrimary)
+	}
+	return scope, nil
+}
+
+// writeQueryError reports a failed query with the status and errorDetail of
+// its classification, or as an internal error when it has none.
+func writeQueryError(w http.ResponseWriter, err error) {
+	detail := openusage.ClassifyError(err)
+	if detail == nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, detail.HTTPStatus, map[string]any{"error": err.Error(), "errorDetail": detail})
+}
+
 func parseIDs(raw string) []string {
 	if raw == "" {
 		return nil
@@ -8,8 +8,10 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/history"
 	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
 )
 
@@ -147,7 +149,7 @@ func newTestServer(t *testing.T) *Server {
 	if err != nil {
 		t.Fatalf("NewManager error: %v", err)
 	}
-	return NewServer(manager)
+	return NewServer(manager, Options{})
 }
 
 func writeManifest(t *testing.T, pluginsDir, id, name string) {
@@ -165,3 +167,190 @@ func writeManifest(t *testing.T, pluginsDir, id, name string) {
 		t.Fatalf("write plugin icon: %v", err)
 	}
 }
+
+func TestHistoryDisabled(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/history", nil)
+
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+}
+
+func TestHistoryReturnsRecordedSeries(t *testing.T) {
+	t.Parallel()
+
+	store, err := history.Open(t.TempDir(), history.Options{})
+	if err != nil {
+		t.Fatalf("history.Open error: %v", err)
+	}
+	at := time.Now().Add(-time.Hour).UTC()
+	if err := store.Append([]history.Sample{
+		{Time: at, ProviderID: "alpha", Label: "Weekly", Used: 40, Limit: 100},
+		{Time: at, ProviderID: "beta", Label: "Weekly", Used: 10, Limit: 100},
+	}); err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+
+	manager, err := openusage.NewManager(openusage.Options{DataDir: t.TempDir()}, nil)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	server := NewServer(manager, Options{History: store})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/history?plugins=alpha&range=2h&step=5m", nil)
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rec.Code, rec.Body.String())
+	}
+	var payload []history.Series
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if len(payload) != 1 || payload[0].ProviderID != "alpha" || payload[0].Points[0].Used != 40 {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+
+	bad := httptest.NewRecorder()
+	server.Handler().ServeHTTP(bad, httptest.NewRequest(http.MethodGet, "/v1/history?step=often", nil))
+	if bad.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request for invalid step, got %d", bad.Code)
+	}
+}
+
+func TestMetricsRouteIsOptional(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	newTestServer(t).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without metrics, got %d", rec.Code)
+	}
+
+	manager, err := openusage.NewManager(openusage.Options{DataDir: t.TempDir()}, nil)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("openusage_up 1\n"))
+	})
+	server := NewServer(manager, Options{Metrics: metrics})
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "openusage_up 1\n" {
+		t.Fatalf("unexpected metrics response: %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestUsageScope(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	pluginDir := filepath.Join(pluginsDir, "alpha")
+	if err := os.MkdirAll(pluginDir, 0o755); err != nil {
+		t.Fatalf("mkdir plugin dir: %v", err)
+	}
+	manifest := `{"id":"alpha","name":"Alpha","lines":[{"type":"text","label":"Status","scope":"overview"}]}`
+	if err := os.WriteFile(filepath.Join(pluginDir, "plugin.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write plugin manifest: %v", err)
+	}
+	// beta has no manifest, like the builtin plugins.
+	manager, err := openusage.NewManager(openusage.Options{PluginsDir: pluginsDir, DataDir: t.TempDir()}, []openusage.Plugin{apiStubPlugin{id: "alpha"}, apiStubPlugin{id: "beta"}})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	server := NewServer(manager, Options{})
+
+	for path, want := range map[string]int{
+		"/v1/usage/alpha":                1,
+		"/v1/usage/alpha?scope=overview": 1,
+		"/v1/usage/alpha?scope=detail":   0,
+		"/v1/usage/alpha?scope=primary":  1,
+		"/v1/usage/beta?scope=overview":  1,
+		"/v1/usage/beta?scope=detail":    0,
+		"/v1/usage/beta?scope=primary":   1,
+	} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status: %d", path, rec.Code)
+		}
+		var payload openusage.PluginOutput
+		if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+			t.Fatalf("unmarshal response: %v", err)
+		}
+		if len(payload.Lines) != want {
+			t.Fatalf("%s: expected %d lines, got %+v", path, want, payload.Lines)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/usage?scope=summary", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request for unknown scope, got %d", rec.Code)
+	}
+}
+
+func TestPluginsListsMetadata(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writeManifest(t, pluginsDir, "alpha", "Alpha")
+	writeManifest(t, pluginsDir, "beta", "Beta")
+	writeManifest(t, pluginsDir, "gamma", "Gamma")
+	manager, err := openusage.NewManager(openusage.Options{
+		PluginsDir:      pluginsDir,
+		DataDir:         t.TempDir(),
+		DisabledPlugins: []string{"beta"},
+	}, []openusage.Plugin{apiStubPlugin{id: "alpha"}, apiStubPlugin{id: "beta"}})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	if _, err := manager.QueryOne(context.Background(), "alpha"); err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	server := NewServer(manager, Options{})
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/plugins", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+	var plugins []openusage.PluginInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &plugins); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if len(plugins) != 3 {
+		t.Fatalf("unexpected plugins: %+v", plugins)
+	}
+	alpha, gamma, beta := plugins[0], plugins[1], plugins[2]
+	if alpha.ID != "alpha" || !alpha.Implemented || !alpha.Enabled || alpha.LastQuery == nil || !alpha.LastQuery.OK || alpha.IconURL == "" {
+		t.Fatalf("unexpected alpha: %+v", alpha)
+	}
+	if gamma.ID != "gamma" || gamma.Implemented || !gamma.Enabled || gamma.LastQuery != nil {
+		t.Fatalf("unexpected gamma: %+v", gamma)
+	}
+	if beta.ID != "beta" || beta.DisplayName != "Beta" || !beta.Implemented || beta.Enabled {
+		t.Fatalf("unexpected beta: %+v", beta)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/plugins/beta", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status for disabled plugin: %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/plugins/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected not found, got %d", rec.Code)
+	}
+}
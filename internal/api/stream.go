@@ -0,0 +1,268 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+)
+
+const (
+	// DefaultHeartbeatInterval is how often an idle stream sends a comment so
+	// clients and proxies can tell the connection is alive.
+	DefaultHeartbeatInterval = 15 * time.Second
+
+	streamRetry      = 3 * time.Second
+	subscriberBuffer = 32
+)
+
+type usageEvent struct {
+	seq    uint64
+	output openusage.PluginOutput
+}
+
+type subscriber struct {
+	ids    map[string]bool
+	events chan usageEvent
+}
+
+// eventHub keeps the latest output per provider and fans changes out to
+// stream subscribers. Event IDs are "<epoch>-<seq>"; the epoch changes with
+// every process so IDs from a previous run are never mistaken for current
+// ones.
+type eventHub struct {
+	epoch string
+
+	mu           sync.Mutex
+	seq          uint64
+	latest       map[string]usageEvent
+	fingerprints map[string]string
+	subscribers  map[*subscriber]struct{}
+	closed       bool
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		epoch:        strconv.FormatInt(time.Now().UnixNano(), 36),
+		latest:       make(map[string]usageEvent),
+		fingerprints: make(map[string]string),
+		subscribers:  make(map[*subscriber]struct{}),
+	}
+}
+
+func (h *eventHub) eventID(seq uint64) string {
+	return h.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// parseLastEventID returns the sequence to resume after, or false when the
+// ID belongs to another process or cannot be parsed.
+func (h *eventHub) parseLastEventID(raw string) (uint64, bool) {
+	epoch, seqRaw, ok := strings.Cut(strings.TrimSpace(raw), "-")
+	if !ok || epoch != h.epoch {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(seqRaw, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
+
+// publish records output and notifies subscribers when it differs from the
+// previous output for the provider. Fetch metadata and time-derived
+// forecasts are not considered changes.
+func (h *eventHub) publish(output openusage.PluginOutput) {
+	fingerprint := outputFingerprint(output)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed || h.fingerprints[output.ProviderID] == fingerprint {
+		return
+	}
+	h.fingerprints[output.ProviderID] = fingerprint
+	h.seq++
+	event := usageEvent{seq: h.seq, output: output}
+	h.latest[output.ProviderID] = event
+
+	for sub := range h.subscribers {
+		if len(sub.ids) > 0 && !sub.ids[output.ProviderID] {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// A subscriber that cannot keep up is disconnected; it resumes
+			// from its last event ID on reconnect.
+			delete(h.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// subscribe registers a subscriber for ids (all when empty) and returns the
+// latest events it has not seen yet: those after since, or every latest
+// output when since is zero.
+func (h *eventHub) subscribe(ids []string, since uint64) (*subscriber, []usageEvent) {
+	sub := &subscriber{events: make(chan usageEvent, subscriberBuffer)}
+	if len(ids) > 0 {
+		sub.ids = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			sub.ids[id] = true
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	backlog := make([]usageEvent, 0, len(h.latest))
+	for id, event := range h.latest {
+		if sub.ids != nil && !sub.ids[id] {
+			continue
+		}
+		if event.seq > since {
+			backlog = append(backlog, event)
+		}
+	}
+	sort.Slice(backlog, func(i, j int) bool { return backlog[i].seq < backlog[j].seq })
+
+	if h.closed {
+		close(sub.events)
+		return sub, backlog
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub, backlog
+}
+
+func (h *eventHub) unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func (h *eventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subscribers {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func outputFingerprint(output openusage.PluginOutput) string {
+	output.FetchedAt = ""
+	output.Cached = false
+	lines := make([]openusage.MetricLine, len(output.Lines))
+	for i, line := range output.Lines {
+		line.Forecast = nil
+		lines[i] = line
+	}
+	output.Lines = lines
+
+	data, err := json.Marshal(output)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func (s *Server) handleUsageStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	ids := parseIDs(strings.TrimSpace(r.URL.Query().Get("plugins")))
+	scope, err := parseScope(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	since, resumed := s.events.parseLastEventID(lastEventID)
+	if !resumed {
+		// Fresh subscribers start from a full snapshot. Querying makes sure
+		// every provider has an entry even before the first poll.
+		outputs, err := s.manager.QueryAll(r.Context(), ids)
+		if err != nil {
+			writeQueryError(w, err)
+			return
+		}
+		for _, output := range outputs {
+			s.events.publish(output)
+		}
+		since = 0
+	}
+
+	sub, backlog := s.events.subscribe(ids, since)
+	defer s.events.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds()); err != nil {
+		return
+	}
+	for _, event := range backlog {
+		if err := s.writeEvent(w, event, scope); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(s.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.events:
+			if !ok {
+				return
+			}
+			if err := s.writeEvent(w, event, scope); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) writeEvent(w http.ResponseWriter, event usageEvent, scope string) error {
+	data, err := json.Marshal(openusage.FilterScope(event.output, scope))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: usage\ndata: %s\n\n", s.events.eventID(event.seq), data)
+	return err
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+type countingPlugin struct {
+	id    string
+	calls *atomic.Int64
+}
+
+func (p countingPlugin) ID() string {
+	return p.id
+}
+
+func (p countingPlugin) Query(_ context.Context, _ *pluginruntime.Env) (openusage.QueryResult, error) {
+	n := p.calls.Add(1)
+	return openusage.QueryResult{
+		Lines: []openusage.MetricLine{
+			openusage.NewProgressLine("Session", float64(n), 100, openusage.PercentFormat(), openusage.ProgressLineOptions{}),
+		},
+	}, nil
+}
+
+type sseEvent struct {
+	id     string
+	event  string
+	data   string
+	isBeat bool
+}
+
+func readSSE(t *testing.T, reader *bufio.Reader) sseEvent {
+	t.Helper()
+
+	var ev sseEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if ev.data != "" || ev.isBeat {
+				return ev
+			}
+		case strings.HasPrefix(line, ": heartbeat"):
+			ev.isBeat = true
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func newStreamTestServer(t *testing.T, heartbeat time.Duration) (*openusage.Manager, *httptest.Server) {
+	t.Helper()
+
+	pluginsDir := t.TempDir()
+	writeManifest(t, pluginsDir, "alpha", "Alpha")
+
+	manager, err := openusage.NewManager(openusage.Options{
+		PluginsDir: pluginsDir,
+		DataDir:    t.TempDir(),
+	}, []openusage.Plugin{countingPlugin{id: "alpha", calls: new(atomic.Int64)}})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	server := NewServer(manager, Options{HeartbeatInterval: heartbeat})
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(func() {
+		server.Close()
+		httpServer.Close()
+	})
+	return manager, httpServer
+}
+
+func openSSE(t *testing.T, ctx context.Context, url, lastEventID string) *bufio.Reader {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("NewRequest error: %v", err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("stream request error: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type: %q", ct)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+func decodeUsed(t *testing.T, ev sseEvent) float64 {
+	t.Helper()
+
+	var output openusage.PluginOutput
+	if err := json.Unmarshal([]byte(ev.data), &output); err != nil {
+		t.Fatalf("unmarshal event: %v", err)
+	}
+	if len(output.Lines) != 1 || output.Lines[0].Used == nil {
+		t.Fatalf("unexpected event output: %+v", output)
+	}
+	return *output.Lines[0].Used
+}
+
+func TestUsageStreamSendsSnapshotAndChanges(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	manager, httpServer := newStreamTestServer(t, time.Hour)
+	reader := openSSE(t, ctx, httpServer.URL+"/v1/usage/stream", "")
+
+	first := readSSE(t, reader)
+	if first.event != "usage" || first.id == "" {
+		t.Fatalf("unexpected first event: %+v", first)
+	}
+	if used := decodeUsed(t, first); used != 1 {
+		t.Fatalf("expected snapshot with used=1, got %v", used)
+	}
+
+	if _, err := manager.Refresh(ctx, "alpha"); err != nil {
+		t.Fatalf("Refresh error: %v", err)
+	}
+	second := readSSE(t, reader)
+	if used := decodeUsed(t, second); used != 2 {
+		t.Fatalf("expected change with used=2, got %v", used)
+	}
+	if second.id == first.id {
+		t.Fatalf("expected a new event id, got %q twice", second.id)
+	}
+}
+
+func TestUsageStreamResumesFromLastEventID(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	manager, httpServer := newStreamTestServer(t, time.Hour)
+	firstCtx, closeFirst := context.WithCancel(ctx)
+	first := readSSE(t, openSSE(t, firstCtx, httpServer.URL+"/v1/usage/stream", ""))
+	closeFirst()
+
+	// Changes while disconnected are replayed on resume.
+	if _, err := manager.Refresh(ctx, "alpha"); err != nil {
+		t.Fatalf("Refresh error: %v", err)
+	}
+	if _, err := manager.Refresh(ctx, "alpha"); err != nil {
+		t.Fatalf("Refresh error: %v", err)
+	}
+
+	reader := openSSE(t, ctx, httpServer.URL+"/v1/usage/stream", first.id)
+	resumed := readSSE(t, reader)
+	if used := decodeUsed(t, resumed); used != 3 {
+		t.Fatalf("expected latest state used=3 on resume, got %v", used)
+	}
+
+	// Resuming from the newest ID sends nothing until the next change.
+	latestCtx, closeLatest := context.WithCancel(ctx)
+	defer closeLatest()
+	latest := openSSE(t, latestCtx, httpServer.URL+"/v1/usage/stream", resumed.id)
+	if _, err := manager.Refresh(ctx, "alpha"); err != nil {
+		t.Fatalf("Refresh error: %v", err)
+	}
+	if used := decodeUsed(t, readSSE(t, latest)); used != 4 {
+		t.Fatalf("expected only the new change used=4, got %v", used)
+	}
+}
+
+func TestUsageStreamSendsHeartbeats(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, httpServer := newStreamTestServer(t, 20*time.Millisecond)
+	reader := openSSE(t, ctx, httpServer.URL+"/v1/usage/stream?plugins=alpha", "")
+
+	_ = readSSE(t, reader)
+	if ev := readSSE(t, reader); !ev.isBeat {
+		t.Fatalf("expected heartbeat, got %+v", ev)
+	}
+}
+
+func TestEventHubSkipsUnchangedOutputs(t *testing.T) {
+	t.Parallel()
+
+	hub := newEventHub()
+	sub, _ := hub.subscribe(nil, 0)
+	defer hub.unsubscribe(sub)
+
+	output := openusage.PluginOutput{ProviderID: "alpha", Plan: "Pro", FetchedAt: "2026-01-01T00:00:00Z"}
+	hub.publish(output)
+	output.FetchedAt = "2026-01-01T00:05:00Z"
+	output.Cached = true
+	hub.publish(output)
+	output.Plan = "Max"
+	hub.publish(output)
+
+	if got := len(sub.events); got != 2 {
+		t.Fatalf("expected 2 events for 2 distinct outputs, got %d", got)
+	}
+	if _, ok := hub.parseLastEventID("other-1"); ok {
+		t.Fatalf("expected ID from another epoch to be rejected")
+	}
+	if seq, ok := hub.parseLastEventID(hub.eventID(7)); !ok || seq != 7 {
+		t.Fatalf("expected own ID to round-trip, got %d %v", seq, ok)
+	}
+}
@@ -0,0 +1,392 @@
+// Package config loads the optional TOML config file and merges it with
+// environment variables and command-line flags. Precedence, highest first:
+// flag, environment, file, default.
+package config
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/deicod/gopenusage/pkg/openusage/alerts"
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+	"github.com/spf13/pflag"
+)
+
+const (
+	FileName  = "config.toml"
+	EnvPrefix = "GOPENUSAGE_"
+	// EnvConfig points at a config file instead of the default location.
+	EnvConfig = EnvPrefix + "CONFIG"
+)
+
+const (
+	sourceDefault = "default"
+	sourceFile    = "file "
+	sourceEnv     = "env "
+	sourceFlag    = "flag --"
+)
+
+// sectionKeys lists the scalar keys accepted in each table. The plugins
+// table additionally accepts one sub-table per plugin ID with pluginKeys.
+var sectionKeys = map[string][]string{
+	"serve": {
+		"addr", "metrics_addr", "plugins_dir", "data_dir", "concurrency", "plugin_timeout",
+		"cache_ttl", "poll_interval", "history", "history_retention", "alerts", "strict",
+	},
+	"query":   {"url", "socket", "timeout", "output", "scope"},
+	"plugins": {"enabled", "disabled"},
+}
+
+var pluginKeys = []string{"poll_interval", "timeout", "credentials", "logs_dir", "name"}
+
+// File is a parsed config file, flattened to dotted keys.
+type File struct {
+	// Path is the file that was read; empty when no file exists.
+	Path   string
+	values map[string]string
+	// Alerts holds the [alerts] table, nil when absent.
+	Alerts *alerts.Config
+}
+
+// DefaultPath is ${XDG_CONFIG_HOME}/gopenusage/config.toml.
+func DefaultPath() string {
+	return filepath.Join(pluginruntime.DefaultDataDir(), FileName)
+}
+
+// Load reads path, or $GOPENUSAGE_CONFIG, or DefaultPath. A missing file is
+// only an error when it was asked for explicitly.
+func Load(path string) (*File, error) {
+	explicit := path != ""
+	if !explicit {
+		if envPath := strings.TrimSpace(os.Getenv(EnvConfig)); envPath != "" {
+			path = envPath
+			explicit = true
+		} else {
+			path = DefaultPath()
+		}
+	}
+	path = pluginruntime.ExpandPath(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return &File{values: map[string]string{}}, nil
+		}
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	f.Path = path
+	return f, nil
+}
+
+// Parse decodes TOML config data.
+func Parse(data []byte) (*File, error) {
+	var raw map[string]any
+	if _, err := toml.NewDecoder(bytes.NewReader(data)).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	f := &File{values: map[string]string{}}
+	for section, value := range raw {
+		if section == "alerts" {
+			continue
+		}
+		table, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected a table", section)
+		}
+		known, ok := sectionKeys[section]
+		if !ok {
+			return nil, fmt.Errorf("unknown section [%s]", section)
+		}
+		if err := f.flatten(section, table, known); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := raw["alerts"]; ok {
+		var wrapper struct {
+			Alerts alerts.Config `toml:"alerts"`
+		}
+		if _, err := toml.NewDecoder(bytes.NewReader(data)).Decode(&wrapper); err != nil {
+			return nil, fmt.Errorf("alerts: %w", err)
+		}
+		if err := wrapper.Alerts.Validate(); err != nil {
+			return nil, fmt.Errorf("alerts: %w", err)
+		}
+		f.Alerts = &wrapper.Alerts
+	}
+
+	return f, nil
+}
+
+func (f *File) flatten(section string, table map[string]any, known []string) error {
+	for key, value := range table {
+		fullKey := section + "." + key
+
+		if sub, ok := value.(map[string]any); ok && section == "plugins" {
+			if err := f.flatten(fullKey, sub, pluginKeys); err != nil {
+				return err
+			}
+			continue
+		}
+		if !slices.Contains(known, key) {
+			return fmt.Errorf("unknown key %s", fullKey)
+		}
+
+		text, err := scalarString(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fullKey, err)
+		}
+		f.values[fullKey] = text
+	}
+	return nil
+}
+
+func scalarString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("expected a list of strings")
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// Lookup returns the file value for a dotted key.
+func (f *File) Lookup(key string) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	value, ok := f.values[key]
+	return value, ok
+}
+
+func (f *File) source() string {
+	return sourceFile + f.Path
+}
+
+// EnvName is the environment variable overriding key, e.g. serve.addr is
+// GOPENUSAGE_SERVE_ADDR and plugins.claude.timeout is
+// GOPENUSAGE_PLUGINS_CLAUDE_TIMEOUT.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// Setting binds a config key to a flag.
+type Setting struct {
+	Key  string
+	Flag string
+}
+
+// PluginSetting binds a per-plugin key (plugins.<id>.<Field>) to a
+// key=value map flag.
+type PluginSetting struct {
+	Field string
+	Flag  string
+}
+
+// Value is one effective setting and where it came from.
+type Value struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Resolve fills every flag the user did not set from the environment or the
+// file and reports the effective values.
+func Resolve(flags *pflag.FlagSet, f *File, settings []Setting, lookupEnv func(string) (string, bool)) ([]Value, error) {
+	values := make([]Value, 0, len(settings))
+	for _, s := range settings {
+		flag := flags.Lookup(s.Flag)
+		if flag == nil {
+			return nil, fmt.Errorf("config key %s: unknown flag --%s", s.Key, s.Flag)
+		}
+
+		source := sourceDefault
+		switch {
+		case flag.Changed:
+			source = sourceFlag + s.Flag
+		default:
+			if value, ok := lookupEnv(EnvName(s.Key)); ok {
+				if err := flags.Set(s.Flag, value); err != nil {
+					return nil, fmt.Errorf("%s: %w", EnvName(s.Key), err)
+				}
+				source = sourceEnv + EnvName(s.Key)
+			} else if value, ok := f.Lookup(s.Key); ok {
+				if err := flags.Set(s.Flag, value); err != nil {
+					return nil, fmt.Errorf("%s in %s: %w", s.Key, f.Path, err)
+				}
+				source = f.source()
+			}
+		}
+
+		values = append(values, Value{Key: s.Key, Value: flagString(flag), Source: source})
+	}
+	return values, nil
+}
+
+// ResolvePlugins merges per-plugin entries from the file, the environment
+// (GOPENUSAGE_PLUGINS_<ID>_<FIELD>) and the map flag, in that order of
+// increasing precedence, and writes the result back into the flag.
+// Environment variables are only matched for ids and for the plugin IDs
+// used in the file or the flags, since IDs such as claude-logs cannot be
+// recovered from the variable name.
+func ResolvePlugins(flags *pflag.FlagSet, f *File, settings []PluginSetting, ids []string, environ []string) ([]Value, error) {
+	known, err := pluginIDs(flags, f, settings, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []Value
+	for _, s := range settings {
+		entries := make(map[string]Value)
+
+		if f != nil {
+			for key, value := range f.values {
+				id, field, ok := splitPluginKey(key)
+				if ok && field == s.Field {
+					entries[id] = Value{Key: key, Value: value, Source: f.source()}
+				}
+			}
+		}
+
+		envIDs := make(map[string]string, len(known))
+		for _, id := range known {
+			envIDs[EnvName("plugins."+id+"."+s.Field)] = id
+		}
+		for _, kv := range environ {
+			name, value, _ := strings.Cut(kv, "=")
+			id, ok := envIDs[name]
+			if !ok {
+				continue
+			}
+			entries[id] = Value{Key: "plugins." + id + "." + s.Field, Value: value, Source: sourceEnv + name}
+		}
+
+		flagMap, err := flags.GetStringToString(s.Flag)
+		if err != nil {
+			return nil, fmt.Errorf("config key plugins.*.%s: %w", s.Field, err)
+		}
+		for id, value := range flagMap {
+			entries[id] = Value{Key: "plugins." + id + "." + s.Field, Value: value, Source: sourceFlag + s.Flag}
+		}
+
+		ids := make([]string, 0, len(entries))
+		pairs := make([]string, 0, len(entries))
+		for id := range entries {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			pairs = append(pairs, id+"="+entries[id].Value)
+			values = append(values, entries[id])
+		}
+
+		if len(pairs) > len(flagMap) {
+			encoded, err := encodeCSV(pairs)
+			if err != nil {
+				return nil, err
+			}
+			if err := flags.Set(s.Flag, encoded); err != nil {
+				return nil, fmt.Errorf("plugins.*.%s: %w", s.Field, err)
+			}
+		}
+	}
+	return values, nil
+}
+
+// pluginIDs returns ids plus every plugin ID set in the file or the flags of
+// settings, sorted and without duplicates.
+func pluginIDs(flags *pflag.FlagSet, f *File, settings []PluginSetting, ids []string) ([]string, error) {
+	seen := make(map[string]bool)
+	add := func(id string) {
+		if id = strings.TrimSpace(id); id != "" {
+			seen[id] = true
+		}
+	}
+	for _, id := range ids {
+		add(id)
+	}
+	if f != nil {
+		for key := range f.values {
+			if id, _, ok := splitPluginKey(key); ok {
+				add(id)
+			}
+		}
+	}
+	for _, s := range settings {
+		flagMap, err := flags.GetStringToString(s.Flag)
+		if err != nil {
+			return nil, fmt.Errorf("config key plugins.*.%s: %w", s.Field, err)
+		}
+		for id := range flagMap {
+			add(id)
+		}
+	}
+
+	known := make([]string, 0, len(seen))
+	for id := range seen {
+		known = append(known, id)
+	}
+	sort.Strings(known)
+	return known, nil
+}
+
+func splitPluginKey(key string) (id, field string, ok bool) {
+	rest, ok := strings.CutPrefix(key, "plugins.")
+	if !ok {
+		return "", "", false
+	}
+	dot := strings.LastIndex(rest, ".")
+	if dot <= 0 {
+		return "", "", false
+	}
+	return rest[:dot], rest[dot+1:], true
+}
+
+// flagString renders slice flags without pflag's surrounding brackets.
+func flagString(flag *pflag.Flag) string {
+	if sv, ok := flag.Value.(pflag.SliceValue); ok {
+		return strings.Join(sv.GetSlice(), ",")
+	}
+	return flag.Value.String()
+}
+
+func encodeCSV(fields []string) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(fields); err != nil {
+		return "", err
+	}
+	w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n"), w.Error()
+}
@@ -0,0 +1,239 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const sampleConfig = `
+[serve]
+addr = ":9000"
+concurrency = 2
+history = false
+poll_interval = "10m"
+
+[plugins]
+enabled = ["claude", "codex"]
+
+[plugins.claude]
+timeout = "30s"
+credentials = "/srv/claude.json"
+
+[plugins.codex]
+poll_interval = "2m"
+
+[plugins."copilot@work"]
+credentials = "/srv/copilot-work.json"
+name = "Copilot Work"
+
+[[alerts.sinks]]
+name = "desktop"
+type = "desktop"
+
+[[alerts.rules]]
+provider = "claude"
+percent = 80
+`
+
+func TestParseFlattensSections(t *testing.T) {
+	t.Parallel()
+
+	f, err := Parse([]byte(sampleConfig))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	for key, want := range map[string]string{
+		"serve.addr":                  ":9000",
+		"serve.concurrency":           "2",
+		"serve.history":               "false",
+		"plugins.enabled":             "claude,codex",
+		"plugins.claude.timeout":      "30s",
+		"plugins.claude.credentials":  "/srv/claude.json",
+		"plugins.copilot@work.name":   "Copilot Work",
+		"plugins.codex.poll_interval": "2m",
+	} {
+		if got, ok := f.Lookup(key); !ok || got != want {
+			t.Fatalf("%s: expected %q, got %q (%v)", key, want, got, ok)
+		}
+	}
+	if f.Alerts == nil || len(f.Alerts.Rules) != 1 || *f.Alerts.Rules[0].Percent != 80 {
+		t.Fatalf("unexpected alerts: %+v", f.Alerts)
+	}
+}
+
+func TestParseRejectsUnknownKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"[serve]\nadress = \":1\"\n":           "unknown key serve.adress",
+		"[server]\naddr = \":1\"\n":            "unknown section [server]",
+		"[plugins.claude]\ntimeot = \"1s\"\n":  "unknown key plugins.claude.timeot",
+		"[[alerts.rules]]\nprovider = \"x\"\n": "exactly one of percent and used",
+	}
+	for input, want := range tests {
+		if _, err := Parse([]byte(input)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: expected error containing %q, got %v", input, want, err)
+		}
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	t.Parallel()
+
+	missing := filepath.Join(t.TempDir(), "config.toml")
+	if _, err := Load(missing); err == nil {
+		t.Fatalf("expected error for explicit missing file")
+	}
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(sampleConfig), 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if f.Path != path {
+		t.Fatalf("unexpected path: %q", f.Path)
+	}
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	t.Parallel()
+
+	f, err := Parse([]byte(sampleConfig))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	f.Path = "/etc/gopenusage.toml"
+
+	flags := pflag.NewFlagSet("serve", pflag.ContinueOnError)
+	addr := flags.String("addr", "unix:///run.sock", "")
+	concurrency := flags.Int("concurrency", 4, "")
+	pollInterval := flags.Duration("poll-interval", 5*time.Minute, "")
+	cacheTTL := flags.Duration("cache-ttl", time.Minute, "")
+	enabled := flags.StringSlice("enable-plugins", nil, "")
+	if err := flags.Parse([]string{"--concurrency=8"}); err != nil {
+		t.Fatalf("Parse flags error: %v", err)
+	}
+
+	env := map[string]string{"GOPENUSAGE_SERVE_POLL_INTERVAL": "1m"}
+	values, err := Resolve(flags, f, []Setting{
+		{Key: "serve.addr", Flag: "addr"},
+		{Key: "serve.concurrency", Flag: "concurrency"},
+		{Key: "serve.poll_interval", Flag: "poll-interval"},
+		{Key: "serve.cache_ttl", Flag: "cache-ttl"},
+		{Key: "plugins.enabled", Flag: "enable-plugins"},
+	}, func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatalf("Resolve error: %v", err)
+	}
+
+	if *addr != ":9000" || *concurrency != 8 || *pollInterval != time.Minute || *cacheTTL != time.Minute {
+		t.Fatalf("unexpected flag values: addr=%q concurrency=%d poll=%s ttl=%s", *addr, *concurrency, *pollInterval, *cacheTTL)
+	}
+	if !reflect.DeepEqual(*enabled, []string{"claude", "codex"}) {
+		t.Fatalf("unexpected enabled plugins: %v", *enabled)
+	}
+
+	want := []Value{
+		{Key: "serve.addr", Value: ":9000", Source: "file /etc/gopenusage.toml"},
+		{Key: "serve.concurrency", Value: "8", Source: "flag --concurrency"},
+		{Key: "serve.poll_interval", Value: "1m0s", Source: "env GOPENUSAGE_SERVE_POLL_INTERVAL"},
+		{Key: "serve.cache_ttl", Value: "1m0s", Source: "default"},
+		{Key: "plugins.enabled", Value: "claude,codex", Source: "file /etc/gopenusage.toml"},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Fatalf("unexpected values:\n got %+v\nwant %+v", values, want)
+	}
+}
+
+func TestResolvePluginsMergesSources(t *testing.T) {
+	t.Parallel()
+
+	f, err := Parse([]byte(sampleConfig))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	f.Path = "cfg.toml"
+
+	flags := pflag.NewFlagSet("serve", pflag.ContinueOnError)
+	intervals := flags.StringToString("poll-intervals", nil, "")
+	timeouts := flags.StringToString("plugin-timeouts", nil, "")
+	if err := flags.Parse([]string{"--poll-intervals=copilot=15m"}); err != nil {
+		t.Fatalf("Parse flags error: %v", err)
+	}
+
+	values, err := ResolvePlugins(flags, f, []PluginSetting{
+		{Field: "poll_interval", Flag: "poll-intervals"},
+		{Field: "timeout", Flag: "plugin-timeouts"},
+	}, []string{"codex", "cursor"}, []string{
+		"GOPENUSAGE_PLUGINS_CODEX_POLL_INTERVAL=3m",
+		"GOPENUSAGE_PLUGINS_CURSOR_TIMEOUT=5s",
+		"UNRELATED=1",
+	})
+	if err != nil {
+		t.Fatalf("ResolvePlugins error: %v", err)
+	}
+
+	if !reflect.DeepEqual(*intervals, map[string]string{"codex": "3m", "copilot": "15m"}) {
+		t.Fatalf("unexpected intervals: %v", *intervals)
+	}
+	if !reflect.DeepEqual(*timeouts, map[string]string{"claude": "30s", "cursor": "5s"}) {
+		t.Fatalf("unexpected timeouts: %v", *timeouts)
+	}
+
+	sources := make(map[string]string, len(values))
+	for _, v := range values {
+		sources[v.Key] = v.Source
+	}
+	want := map[string]string{
+		"plugins.codex.poll_interval":   "env GOPENUSAGE_PLUGINS_CODEX_POLL_INTERVAL",
+		"plugins.copilot.poll_interval": "flag --poll-intervals",
+		"plugins.claude.timeout":        "file cfg.toml",
+		"plugins.cursor.timeout":        "env GOPENUSAGE_PLUGINS_CURSOR_TIMEOUT",
+	}
+	if !reflect.DeepEqual(sources, want) {
+		t.Fatalf("unexpected sources: %v", sources)
+	}
+}
+
+func TestResolvePluginsMatchesKnownIDs(t *testing.T) {
+	t.Parallel()
+
+	flags := pflag.NewFlagSet("serve", pflag.ContinueOnError)
+	credentials := flags.StringToString("plugin-credentials", nil, "")
+	logsDirs := flags.StringToString("plugin-logs-dirs", nil, "")
+	if err := flags.Parse(nil); err != nil {
+		t.Fatalf("Parse flags error: %v", err)
+	}
+
+	_, err := ResolvePlugins(flags, nil, []PluginSetting{
+		{Field: "credentials", Flag: "plugin-credentials"},
+		{Field: "logs_dir", Flag: "plugin-logs-dirs"},
+	}, []string{"claude", "claude-logs"}, []string{
+		"GOPENUSAGE_PLUGINS_CLAUDE_LOGS_CREDENTIALS=/srv/projects",
+		"GOPENUSAGE_PLUGINS_CLAUDE_LOGS_DIR=/srv/claude",
+		"GOPENUSAGE_PLUGINS_UNKNOWN_CREDENTIALS=/srv/unknown",
+	})
+	if err != nil {
+		t.Fatalf("ResolvePlugins error: %v", err)
+	}
+
+	if !reflect.DeepEqual(*credentials, map[string]string{"claude-logs": "/srv/projects"}) {
+		t.Fatalf("unexpected credentials: %v", *credentials)
+	}
+	if !reflect.DeepEqual(*logsDirs, map[string]string{"claude": "/srv/claude"}) {
+		t.Fatalf("unexpected logs dirs: %v", *logsDirs)
+	}
+}
@@ -0,0 +1,234 @@
+// Package render formats plugin outputs for the terminal and for scripts.
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FormatTable  = "table"
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatYAML   = "yaml"
+	FormatCSV    = "csv"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{FormatTable, FormatText, FormatJSON, FormatNDJSON, FormatYAML, FormatCSV}
+
+type Options struct {
+	Format string
+	// Color enables ANSI colors in the table format.
+	Color bool
+	// Single renders json and yaml as one object instead of a list.
+	Single bool
+	// Now is the reference for relative reset times; zero uses time.Now.
+	Now time.Time
+}
+
+// Write renders outputs in opts.Format.
+func Write(w io.Writer, outputs []openusage.PluginOutput, opts Options) error {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	switch opts.Format {
+	case FormatTable:
+		return writeTable(w, outputs, opts)
+	case FormatText:
+		return writeText(w, outputs, opts)
+	case FormatJSON:
+		return writeJSON(w, outputs, opts.Single)
+	case FormatNDJSON:
+		return writeNDJSON(w, outputs)
+	case FormatYAML:
+		return writeYAML(w, outputs, opts.Single)
+	case FormatCSV:
+		return writeCSV(w, outputs)
+	default:
+		return fmt.Errorf("unknown output format %q (want one of %s)", opts.Format, strings.Join(Formats, ", "))
+	}
+}
+
+// IsTerminal reports whether f is a character device such as a terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// ColorEnabled reports whether colors should be used on f: only on a
+// terminal, and never when NO_COLOR is set to a non-empty value.
+func ColorEnabled(f *os.File) bool {
+	return os.Getenv("NO_COLOR") == "" && IsTerminal(f)
+}
+
+func writeJSON(w io.Writer, outputs []openusage.PluginOutput, single bool) error {
+	var payload any = outputs
+	if single && len(outputs) == 1 {
+		payload = outputs[0]
+	}
+	pretty, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(pretty))
+	return err
+}
+
+func writeNDJSON(w io.Writer, outputs []openusage.PluginOutput) error {
+	enc := json.NewEncoder(w)
+	for _, output := range outputs {
+		if err := enc.Encode(output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeYAML goes through JSON so keys match the JSON API.
+func writeYAML(w io.Writer, outputs []openusage.PluginOutput, single bool) error {
+	var payload any = outputs
+	if single && len(outputs) == 1 {
+		payload = outputs[0]
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(generic); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+var csvHeader = []string{"provider", "display_name", "plan", "type", "label", "value", "used", "limit", "format", "resets_at", "error"}
+
+// writeCSV emits one row per metric line.
+func writeCSV(w io.Writer, outputs []openusage.PluginOutput) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, output := range outputs {
+		for _, line := range output.Lines {
+			row := []string{
+				output.ProviderID,
+				output.DisplayName,
+				output.Plan,
+				line.Type,
+				line.Label,
+				lineText(line),
+				optionalFloat(line.Used),
+				optionalFloat(line.Limit),
+				formatKind(line),
+				deref(line.ResetsAt),
+				output.Error,
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeText(w io.Writer, outputs []openusage.PluginOutput, opts Options) error {
+	for i, output := range outputs {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w, title(output)); err != nil {
+			return err
+		}
+		if output.Error != "" {
+			if _, err := fmt.Fprintf(w, "  error: %s\n", output.Error); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, line := range output.Lines {
+			text := lineText(line)
+			if line.Type == openusage.LineTypeProgress {
+				text = progressValue(line)
+				if reset := resetText(line, opts.Now); reset != "" {
+					text += " (" + reset + ")"
+				}
+			}
+			if _, err := fmt.Fprintf(w, "  %s: %s\n", line.Label, text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func title(output openusage.PluginOutput) string {
+	name := output.DisplayName
+	if name == "" {
+		name = output.ProviderID
+	}
+	if output.Plan != "" {
+		return name + " · " + output.Plan
+	}
+	return name
+}
+
+// lineText is the display value of a text or badge line.
+func lineText(line openusage.MetricLine) string {
+	switch {
+	case line.Value != nil:
+		return *line.Value
+	case line.Text != nil:
+		return *line.Text
+	case line.Type == openusage.LineTypeProgress:
+		return progressValue(line)
+	default:
+		return ""
+	}
+}
+
+func formatKind(line openusage.MetricLine) string {
+	if line.Format == nil {
+		return ""
+	}
+	return line.Format.Kind
+}
+
+func optionalFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
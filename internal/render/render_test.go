@@ -0,0 +1,191 @@
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+)
+
+var testNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func ptr[T any](v T) *T { return &v }
+
+func testOutputs() []openusage.PluginOutput {
+	return []openusage.PluginOutput{
+		{
+			ProviderID:  "claude",
+			DisplayName: "Claude",
+			Plan:        "Pro",
+			Lines: []openusage.MetricLine{
+				{
+					Type:     openusage.LineTypeProgress,
+					Label:    "Session",
+					Used:     ptr(42.0),
+					Limit:    ptr(100.0),
+					Format:   &openusage.ProgressFormat{Kind: openusage.FormatKindPercent},
+					ResetsAt: ptr("2026-03-01T15:12:00.000Z"),
+				},
+				{
+					Type:   openusage.LineTypeProgress,
+					Label:  "Extra",
+					Used:   ptr(12.5),
+					Limit:  ptr(50.0),
+					Format: &openusage.ProgressFormat{Kind: openusage.FormatKindDollars},
+					Color:  ptr("#ff0000"),
+				},
+				{Type: openusage.LineTypeBadge, Label: "Status", Text: ptr("ok")},
+			},
+		},
+		{ProviderID: "codex", DisplayName: "Codex", Error: "not logged in"},
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, testOutputs(), Options{Format: FormatTable, Now: testNow}); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+
+	want := "Claude · Pro\n" +
+		"  Session  ████████▍░░░░░░░░░░░  42%              resets in 3h12m\n" +
+		"  Extra    █████░░░░░░░░░░░░░░░  $12.50 / $50.00\n" +
+		"  Status   ok\n" +
+		"\n" +
+		"Codex\n" +
+		"  error: not logged in\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected table:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTableColors(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, testOutputs(), Options{Format: FormatTable, Color: true, Now: testNow}); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	got := buf.String()
+
+	if !strings.Contains(got, "\x1b[38;2;255;0;0m█████") {
+		t.Fatalf("expected bar colored by line color, got %q", got)
+	}
+	if !strings.Contains(got, "\x1b[38;2;34;197;94m████████▍") {
+		t.Fatalf("expected default green bar, got %q", got)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, testOutputs()[:1], Options{Format: FormatText, Now: testNow}); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+
+	want := "Claude · Pro\n" +
+		"  Session: 42% (resets in 3h12m)\n" +
+		"  Extra: $12.50 / $50.00\n" +
+		"  Status: ok\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected text:\n%s", got)
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, testOutputs(), Options{Format: FormatNDJSON}); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	var output openusage.PluginOutput
+	if err := json.Unmarshal([]byte(lines[1]), &output); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if output.ProviderID != "codex" {
+		t.Fatalf("unexpected provider: %s", output.ProviderID)
+	}
+}
+
+func TestWriteJSONSingleIsObject(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, testOutputs()[1:], Options{Format: FormatJSON, Single: true}); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "{") {
+		t.Fatalf("expected object, got %s", buf.String())
+	}
+}
+
+func TestWriteYAMLUsesJSONKeys(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, testOutputs()[1:], Options{Format: FormatYAML}); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	got := buf.String()
+	if !strings.Contains(got, "providerId: codex") || !strings.Contains(got, "displayName: Codex") {
+		t.Fatalf("unexpected yaml:\n%s", got)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, testOutputs(), Options{Format: FormatCSV}); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll error: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected header and 3 rows, got %d", len(records))
+	}
+	want := []string{"claude", "Claude", "Pro", "progress", "Session", "42%", "42", "100", "percent", "2026-03-01T15:12:00.000Z", ""}
+	if strings.Join(records[1], "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected row: %v", records[1])
+	}
+}
+
+func TestWriteRejectsUnknownFormat(t *testing.T) {
+	t.Parallel()
+
+	if err := Write(&bytes.Buffer{}, nil, Options{Format: "xml"}); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
+
+func TestShortDuration(t *testing.T) {
+	t.Parallel()
+
+	cases := map[time.Duration]string{
+		30 * time.Second:              "<1m",
+		12 * time.Minute:              "12m",
+		3*time.Hour + 12*time.Minute:  "3h12m",
+		52*time.Hour + 30*time.Minute: "2d4h",
+	}
+	for d, want := range cases {
+		if got := shortDuration(d); got != want {
+			t.Fatalf("shortDuration(%s) = %s, want %s", d, got, want)
+		}
+	}
+}
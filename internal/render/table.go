@@ -0,0 +1,228 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+)
+
+const barWidth = 20
+
+// Default bar colors by share of the limit used.
+const (
+	colorOK       = "#22c55e"
+	colorWarning  = "#eab308"
+	colorCritical = "#ef4444"
+	colorMuted    = "#a3a3a3"
+)
+
+var barEighths = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+func writeTable(w io.Writer, outputs []openusage.PluginOutput, opts Options) error {
+	for i, output := range outputs {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w, bold(title(output), opts.Color)); err != nil {
+			return err
+		}
+		if output.Error != "" {
+			if _, err := fmt.Fprintf(w, "  %s\n", paint("error: "+output.Error, colorCritical, opts.Color)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		labelWidth, valueWidth := 0, 0
+		for _, line := range output.Lines {
+			labelWidth = max(labelWidth, utf8.RuneCountInString(line.Label))
+			if line.Type == openusage.LineTypeProgress {
+				valueWidth = max(valueWidth, utf8.RuneCountInString(progressValue(line)))
+			}
+		}
+
+		for _, line := range output.Lines {
+			if _, err := fmt.Fprintln(w, tableRow(line, labelWidth, valueWidth, opts)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func tableRow(line openusage.MetricLine, labelWidth, valueWidth int, opts Options) string {
+	label := pad(line.Label, labelWidth)
+
+	if line.Type != openusage.LineTypeProgress {
+		return strings.TrimRight("  "+label+"  "+paint(lineText(line), deref(line.Color), opts.Color), " ")
+	}
+
+	fraction := 0.0
+	if line.Used != nil && line.Limit != nil && *line.Limit > 0 {
+		fraction = *line.Used / *line.Limit
+	}
+	color := deref(line.Color)
+	if color == "" {
+		color = usageColor(fraction)
+	}
+
+	row := "  " + label + "  " + paint(bar(fraction, barWidth), color, opts.Color) + "  " + pad(progressValue(line), valueWidth)
+	if reset := resetText(line, opts.Now); reset != "" {
+		row += "  " + paint(reset, colorMuted, opts.Color)
+	}
+	return strings.TrimRight(row, " ")
+}
+
+// bar renders fraction (clamped to 0..1) as a fixed-width Unicode bar with
+// eighth-block precision.
+func bar(fraction float64, width int) string {
+	fraction = openusage.Clamp(fraction, 0, 1)
+	eighths := int(math.Round(fraction * float64(width*8)))
+	full, partial := eighths/8, eighths%8
+
+	var b strings.Builder
+	b.WriteString(strings.Repeat("█", full))
+	cells := full
+	if partial > 0 {
+		b.WriteString(barEighths[partial])
+		cells++
+	}
+	b.WriteString(strings.Repeat("░", width-cells))
+	return b.String()
+}
+
+func usageColor(fraction float64) string {
+	switch {
+	case fraction >= 0.9:
+		return colorCritical
+	case fraction >= 0.7:
+		return colorWarning
+	default:
+		return colorOK
+	}
+}
+
+// progressValue formats used and limit according to the line's format.
+func progressValue(line openusage.MetricLine) string {
+	if line.Used == nil {
+		return ""
+	}
+	used := *line.Used
+	limit := 0.0
+	if line.Limit != nil {
+		limit = *line.Limit
+	}
+
+	kind, suffix := "", ""
+	if line.Format != nil {
+		kind, suffix = line.Format.Kind, line.Format.Suffix
+	}
+
+	switch kind {
+	case openusage.FormatKindPercent:
+		if limit > 0 && limit != 100 {
+			return formatNumber(used/limit*100) + "%"
+		}
+		return formatNumber(used) + "%"
+	case openusage.FormatKindDollars:
+		if limit > 0 {
+			return fmt.Sprintf("$%.2f / $%.2f", used, limit)
+		}
+		return fmt.Sprintf("$%.2f", used)
+	default:
+		value := formatNumber(used)
+		if limit > 0 {
+			value += " / " + formatNumber(limit)
+		}
+		if suffix != "" {
+			value += " " + suffix
+		}
+		return value
+	}
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(openusage.RoundTo(v, 1), 'f', -1, 64)
+}
+
+// resetText describes ResetsAt relative to now, e.g. "resets in 3h12m".
+func resetText(line openusage.MetricLine, now time.Time) string {
+	if line.ResetsAt == nil {
+		return ""
+	}
+	resetsAt, err := time.Parse(time.RFC3339, *line.ResetsAt)
+	if err != nil {
+		return ""
+	}
+	d := resetsAt.Sub(now)
+	if d <= 0 {
+		return "reset due"
+	}
+	return "resets in " + shortDuration(d)
+}
+
+// shortDuration keeps the two most significant units: 4d2h, 3h12m, 12m.
+func shortDuration(d time.Duration) string {
+	if d < time.Minute {
+		return "<1m"
+	}
+	minutes := int(d / time.Minute)
+	days, hours, mins := minutes/(24*60), minutes/60%24, minutes%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%02dm", hours, mins)
+	default:
+		return fmt.Sprintf("%dm", mins)
+	}
+}
+
+func pad(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+func bold(s string, enabled bool) string {
+	if !enabled {
+		return s
+	}
+	return "\x1b[1m" + s + "\x1b[0m"
+}
+
+// paint colors s with a #rrggbb color using 24-bit ANSI escapes.
+func paint(s, hex string, enabled bool) string {
+	if !enabled || s == "" {
+		return s
+	}
+	r, g, b, ok := parseHex(hex)
+	if !ok {
+		return s
+	}
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm%s\x1b[0m", r, g, b, s)
+}
+
+func parseHex(hex string) (r, g, b uint8, ok bool) {
+	hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return 0, 0, 0, false
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return uint8(v >> 16), uint8(v >> 8), uint8(v), true
+}
@@ -0,0 +1,22 @@
+package openusage
+
+import "strings"
+
+// Account is an extra instance of a plugin, such as "copilot@work". It is
+// queried as a separate plugin with its own ID, so PluginCredentials,
+// PluginTimeouts and PluginCacheTTLs apply to it by that ID, and it gets its
+// own plugin data dir. Accounts must have credentials configured; plugins do
+// not fall back to ambient logins for them.
+type Account struct {
+	// ID is "<plugin>@<name>".
+	ID string
+	// DisplayName defaults to "<plugin name> (<name>)".
+	DisplayName string
+}
+
+// SplitAccountID splits "copilot@work" into "copilot" and "work". IDs without
+// an account return the ID and "".
+func SplitAccountID(id string) (plugin, account string) {
+	plugin, account, _ = strings.Cut(id, "@")
+	return plugin, account
+}
@@ -0,0 +1,159 @@
+package openusage
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+func TestManagerQueriesAccountsSeparately(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writePluginManifest(t, pluginsDir, "copilot", "Copilot")
+	writePluginManifest(t, pluginsDir, "claude", "Claude")
+	dataDir := t.TempDir()
+
+	var mu sync.Mutex
+	envs := make(map[string]pluginruntime.Env)
+	copilot := stubPlugin{
+		id: "copilot",
+		fn: func(_ context.Context, env *pluginruntime.Env) (QueryResult, error) {
+			mu.Lock()
+			envs[env.PluginID] = *env
+			mu.Unlock()
+			return QueryResult{Plan: env.CredentialsPathOr("ambient"), Lines: []MetricLine{NewTextLine("Status", "ok", TextLineOptions{})}}, nil
+		},
+	}
+
+	manager, err := NewManager(Options{
+		PluginsDir: pluginsDir,
+		DataDir:    dataDir,
+		PluginCredentials: map[string]string{
+			"copilot@work":   "/srv/work.json",
+			"copilot@client": "/srv/client.json",
+		},
+		Accounts: []Account{
+			{ID: "copilot@work", DisplayName: "Copilot Work"},
+			{ID: "copilot@client"},
+		},
+	}, []Plugin{copilot})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	want := []string{"claude", "copilot", "copilot@client", "copilot@work"}
+	if ids := manager.PluginIDs(); !reflect.DeepEqual(ids, want) {
+		t.Fatalf("unexpected plugin IDs: got %v want %v", ids, want)
+	}
+
+	outputs, err := manager.QueryAll(context.Background(), []string{"copilot", "copilot@client", "copilot@work"})
+	if err != nil {
+		t.Fatalf("QueryAll error: %v", err)
+	}
+	for i, want := range []struct{ provider, account, name, plan string }{
+		{"copilot", "", "Copilot", "ambient"},
+		{"copilot", "client", "Copilot (client)", "/srv/client.json"},
+		{"copilot", "work", "Copilot Work", "/srv/work.json"},
+	} {
+		got := outputs[i]
+		if got.Provider != want.provider || got.Account != want.account || got.DisplayName != want.name || got.Plan != want.plan {
+			t.Fatalf("unexpected output %d: %+v", i, got)
+		}
+	}
+
+	work := envs["copilot@work"]
+	if work.Account != "work" || work.PluginDataDir != filepath.Join(dataDir, "plugins_data", "copilot@work") {
+		t.Fatalf("unexpected account env: %+v", work)
+	}
+	if envs["copilot"].Account != "" {
+		t.Fatalf("expected default instance without account, got %q", envs["copilot"].Account)
+	}
+
+	info, ok := manager.Plugin("copilot@work")
+	if !ok || info.Provider != "copilot" || info.Account != "work" || info.DisplayName != "Copilot Work" {
+		t.Fatalf("unexpected plugin info: %+v", info)
+	}
+}
+
+func TestManagerAccountWithoutCredentials(t *testing.T) {
+	t.Parallel()
+
+	called := false
+	manager, err := NewManager(Options{
+		DataDir:  t.TempDir(),
+		Accounts: []Account{{ID: "alpha@work"}},
+	}, []Plugin{stubPlugin{
+		id: "alpha",
+		fn: func(context.Context, *pluginruntime.Env) (QueryResult, error) {
+			called = true
+			return QueryResult{}, nil
+		},
+	}})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	out, err := manager.QueryOne(context.Background(), "alpha@work")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	if called || out.ErrorDetail == nil || out.ErrorDetail.Code != ErrorAuthRequired {
+		t.Fatalf("expected auth_required without querying, got %+v (called %v)", out, called)
+	}
+}
+
+func TestManagerAccountFilters(t *testing.T) {
+	t.Parallel()
+
+	plugins := []Plugin{stubPlugin{id: "alpha"}, stubPlugin{id: "beta"}}
+	accounts := []Account{{ID: "alpha@one"}, {ID: "alpha@two"}, {ID: "beta@one"}}
+
+	cases := []struct {
+		name              string
+		enabled, disabled []string
+		want              []string
+	}{
+		{"enabled plugin includes accounts", []string{"alpha"}, nil, []string{"alpha", "alpha@one", "alpha@two"}},
+		{"enabled account only", []string{"beta@one"}, nil, []string{"beta@one"}},
+		{"disabled account", nil, []string{"alpha@two"}, []string{"alpha", "alpha@one", "beta", "beta@one"}},
+		{"disabled plugin keeps accounts", nil, []string{"alpha"}, []string{"alpha@one", "alpha@two", "beta", "beta@one"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			manager, err := NewManager(Options{
+				DataDir:         t.TempDir(),
+				Accounts:        accounts,
+				EnabledPlugins:  tc.enabled,
+				DisabledPlugins: tc.disabled,
+			}, plugins)
+			if err != nil {
+				t.Fatalf("NewManager error: %v", err)
+			}
+			if ids := manager.PluginIDs(); !reflect.DeepEqual(ids, tc.want) {
+				t.Fatalf("unexpected plugin IDs: got %v want %v", ids, tc.want)
+			}
+		})
+	}
+}
+
+func TestManagerRejectsInvalidAccounts(t *testing.T) {
+	t.Parallel()
+
+	for _, id := range []string{"alpha", "alpha@", "missing@work", "alpha@a/b"} {
+		_, err := NewManager(Options{
+			DataDir:  t.TempDir(),
+			Accounts: []Account{{ID: id}},
+		}, []Plugin{stubPlugin{id: "alpha"}})
+		if err == nil || !strings.Contains(err.Error(), id) {
+			t.Fatalf("expected error for account %q, got %v", id, err)
+		}
+	}
+}
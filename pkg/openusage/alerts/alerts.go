@@ -0,0 +1,316 @@
+// Package alerts evaluates threshold rules against plugin outputs and
+// delivers notifications through pluggable sinks.
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+)
+
+const (
+	// DefaultHysteresis is how far (in percent points) usage must fall below a
+	// percent threshold before the rule can fire again.
+	DefaultHysteresis = 5.0
+	// defaultUsedHysteresisRatio applies to absolute thresholds without an
+	// explicit hysteresis: 5% of the threshold value.
+	defaultUsedHysteresisRatio = 0.05
+
+	deliveryTimeout = 10 * time.Second
+)
+
+// Config is the alerting file format.
+type Config struct {
+	Sinks []SinkConfig `json:"sinks"`
+	Rules []Rule       `json:"rules"`
+}
+
+// Rule fires when a matching progress line crosses its threshold. Exactly one
+// of Percent (of the line limit, 0-100) and Used (absolute, in line units)
+// must be set.
+type Rule struct {
+	Name string `json:"name"`
+	// Provider matches a provider ID; empty or "*" matches every provider.
+	// A plugin ID such as "copilot" also matches its accounts ("copilot@work").
+	Provider string `json:"provider,omitempty"`
+	// Label matches a line label case-insensitively; empty matches every
+	// progress line.
+	Label   string   `json:"label,omitempty"`
+	Percent *float64 `json:"percent,omitempty"`
+	Used    *float64 `json:"used,omitempty"`
+	// Hysteresis is how far below the threshold usage must fall before the
+	// rule re-arms, in the threshold's units.
+	Hysteresis *float64 `json:"hysteresis,omitempty"`
+	// Sinks names the sinks to notify; empty means every sink.
+	Sinks []string `json:"sinks,omitempty"`
+}
+
+// Notification is what sinks receive when a rule fires.
+type Notification struct {
+	Rule        string    `json:"rule"`
+	ProviderID  string    `json:"providerId"`
+	DisplayName string    `json:"displayName"`
+	Label       string    `json:"label"`
+	Used        float64   `json:"used"`
+	Limit       float64   `json:"limit"`
+	Percent     float64   `json:"percent"`
+	Format      string    `json:"format,omitempty"`
+	Threshold   string    `json:"threshold"`
+	Title       string    `json:"title"`
+	Message     string    `json:"message"`
+	Time        time.Time `json:"time"`
+}
+
+// Sink delivers notifications.
+type Sink interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// LoadConfig reads a JSON alerting config file.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("read alerts config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("parse alerts config %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks rules and sink references.
+func (c Config) Validate() error {
+	sinkNames := make(map[string]bool, len(c.Sinks))
+	for i, sink := range c.Sinks {
+		if strings.TrimSpace(sink.Name) == "" {
+			return fmt.Errorf("sinks[%d]: name is required", i)
+		}
+		if sinkNames[sink.Name] {
+			return fmt.Errorf("sinks[%d]: duplicate sink name %q", i, sink.Name)
+		}
+		sinkNames[sink.Name] = true
+	}
+
+	for i, rule := range c.Rules {
+		if (rule.Percent == nil) == (rule.Used == nil) {
+			return fmt.Errorf("rules[%d]: exactly one of percent and used is required", i)
+		}
+		if rule.Hysteresis != nil && *rule.Hysteresis < 0 {
+			return fmt.Errorf("rules[%d]: hysteresis must not be negative", i)
+		}
+		for _, name := range rule.Sinks {
+			if !sinkNames[name] {
+				return fmt.Errorf("rules[%d]: unknown sink %q", i, name)
+			}
+		}
+	}
+	return nil
+}
+
+func (r Rule) displayName(index int) string {
+	if strings.TrimSpace(r.Name) != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("rule-%d", index+1)
+}
+
+func (r Rule) matches(providerID string, line openusage.MetricLine) bool {
+	if line.Type != openusage.LineTypeProgress || line.Used == nil || line.Limit == nil {
+		return false
+	}
+	if plugin, _ := openusage.SplitAccountID(providerID); r.Provider != "" && r.Provider != "*" && r.Provider != providerID && r.Provider != plugin {
+		return false
+	}
+	if r.Label != "" && !strings.EqualFold(r.Label, line.Label) {
+		return false
+	}
+	return true
+}
+
+// measure returns the value compared against the threshold, the threshold
+// itself and the re-arm level.
+func (r Rule) measure(line openusage.MetricLine) (value, threshold, rearm float64, ok bool) {
+	if r.Percent != nil {
+		if *line.Limit <= 0 {
+			return 0, 0, 0, false
+		}
+		hysteresis := DefaultHysteresis
+		if r.Hysteresis != nil {
+			hysteresis = *r.Hysteresis
+		}
+		return *line.Used / *line.Limit * 100, *r.Percent, *r.Percent - hysteresis, true
+	}
+
+	hysteresis := *r.Used * defaultUsedHysteresisRatio
+	if r.Hysteresis != nil {
+		hysteresis = *r.Hysteresis
+	}
+	return *line.Used, *r.Used, *r.Used - hysteresis, true
+}
+
+// Engine tracks which rules are firing so each crossing notifies once.
+type Engine struct {
+	rules []Rule
+	sinks map[string]Sink
+	order []string
+	now   func() time.Time
+
+	mu     sync.Mutex
+	firing map[string]bool
+}
+
+// NewEngine builds an engine for rules delivering to sinks (by name).
+func NewEngine(rules []Rule, sinks map[string]Sink) *Engine {
+	order := make([]string, 0, len(sinks))
+	for name := range sinks {
+		order = append(order, name)
+	}
+	sort.Strings(order)
+
+	return &Engine{
+		rules:  rules,
+		sinks:  sinks,
+		order:  order,
+		now:    time.Now,
+		firing: make(map[string]bool),
+	}
+}
+
+type firedAlert struct {
+	notification Notification
+	sinks        []string
+}
+
+// Evaluate updates rule state for output and returns the notifications for
+// rules that crossed their threshold. A rule stays silent while it is firing
+// and re-arms once usage falls below threshold minus hysteresis.
+func (e *Engine) Evaluate(output openusage.PluginOutput) []Notification {
+	fired := e.evaluate(output)
+	out := make([]Notification, 0, len(fired))
+	for _, alert := range fired {
+		out = append(out, alert.notification)
+	}
+	return out
+}
+
+// Handle evaluates output and delivers any notifications. Delivery errors are
+// logged; one failing sink does not stop the others.
+func (e *Engine) Handle(ctx context.Context, output openusage.PluginOutput) {
+	for _, alert := range e.evaluate(output) {
+		for _, sinkName := range alert.sinks {
+			deliverCtx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+			if err := e.sinks[sinkName].Notify(deliverCtx, alert.notification); err != nil {
+				log.Printf("alerts: sink %s: %v", sinkName, err)
+			}
+			cancel()
+		}
+	}
+}
+
+func (e *Engine) evaluate(output openusage.PluginOutput) []firedAlert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	fired := make([]firedAlert, 0)
+	for i, rule := range e.rules {
+		for _, line := range output.Lines {
+			if !rule.matches(output.ProviderID, line) {
+				continue
+			}
+			value, threshold, rearm, ok := rule.measure(line)
+			if !ok {
+				continue
+			}
+
+			key := fmt.Sprintf("%d\x00%s\x00%s", i, output.ProviderID, line.Label)
+			switch {
+			case value >= threshold && !e.firing[key]:
+				e.firing[key] = true
+				fired = append(fired, firedAlert{
+					notification: e.buildNotification(rule.displayName(i), rule, output, line),
+					sinks:        e.sinksFor(rule),
+				})
+			case value < rearm && e.firing[key]:
+				delete(e.firing, key)
+			}
+		}
+	}
+	return fired
+}
+
+func (e *Engine) sinksFor(rule Rule) []string {
+	if len(rule.Sinks) == 0 {
+		return e.order
+	}
+	out := make([]string, 0, len(rule.Sinks))
+	for _, name := range rule.Sinks {
+		if _, ok := e.sinks[name]; ok {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+func (e *Engine) buildNotification(ruleName string, rule Rule, output openusage.PluginOutput, line openusage.MetricLine) Notification {
+	used := *line.Used
+	limit := *line.Limit
+	percent := 0.0
+	if limit > 0 {
+		percent = openusage.RoundTo(used/limit*100, 1)
+	}
+	format := ""
+	if line.Format != nil {
+		format = line.Format.Kind
+	}
+
+	displayName := output.DisplayName
+	if displayName == "" {
+		displayName = output.ProviderID
+	}
+
+	threshold := ""
+	if rule.Percent != nil {
+		threshold = fmt.Sprintf("%g%%", *rule.Percent)
+	} else {
+		threshold = formatValue(*rule.Used, format)
+	}
+
+	return Notification{
+		Rule:        ruleName,
+		ProviderID:  output.ProviderID,
+		DisplayName: displayName,
+		Label:       line.Label,
+		Used:        used,
+		Limit:       limit,
+		Percent:     percent,
+		Format:      format,
+		Threshold:   threshold,
+		Title:       fmt.Sprintf("%s %s passed %s", displayName, line.Label, threshold),
+		Message:     fmt.Sprintf("%s of %s used (%g%%)", formatValue(used, format), formatValue(limit, format), percent),
+		Time:        e.now().UTC(),
+	}
+}
+
+func formatValue(value float64, format string) string {
+	switch format {
+	case openusage.FormatKindDollars:
+		return fmt.Sprintf("$%.2f", value)
+	case openusage.FormatKindPercent:
+		return fmt.Sprintf("%g%%", openusage.RoundTo(value, 1))
+	default:
+		return fmt.Sprintf("%g", openusage.RoundTo(value, 2))
+	}
+}
@@ -0,0 +1,196 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+)
+
+type recordingSink struct {
+	mu    sync.Mutex
+	got   []Notification
+	fail  error
+	calls int
+}
+
+func (s *recordingSink) Notify(_ context.Context, n Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.fail != nil {
+		return s.fail
+	}
+	s.got = append(s.got, n)
+	return nil
+}
+
+func float(v float64) *float64 { return &v }
+
+func usageOutput(providerID string, used float64) openusage.PluginOutput {
+	return openusage.PluginOutput{
+		ProviderID:  providerID,
+		DisplayName: "Claude",
+		Lines: []openusage.MetricLine{
+			openusage.NewProgressLine("Session", used, 100, openusage.PercentFormat(), openusage.ProgressLineOptions{}),
+			openusage.NewTextLine("Plan", "Pro", openusage.TextLineOptions{}),
+		},
+	}
+}
+
+func TestEvaluateFiresOnceUntilRearmed(t *testing.T) {
+	t.Parallel()
+
+	engine := NewEngine([]Rule{{Name: "session-80", Label: "session", Percent: float(80)}}, nil)
+
+	steps := []struct {
+		used  float64
+		fires bool
+	}{
+		{used: 50, fires: false},
+		{used: 82, fires: true},
+		{used: 90, fires: false},
+		{used: 77, fires: false}, // inside hysteresis band, still firing
+		{used: 85, fires: false},
+		{used: 70, fires: false}, // re-arms
+		{used: 81, fires: true},
+	}
+
+	for i, step := range steps {
+		got := engine.Evaluate(usageOutput("claude", step.used))
+		if step.fires != (len(got) == 1) {
+			t.Fatalf("step %d (used %v): expected fires=%v, got %d notifications", i, step.used, step.fires, len(got))
+		}
+	}
+}
+
+func TestEvaluateTracksProvidersIndependently(t *testing.T) {
+	t.Parallel()
+
+	engine := NewEngine([]Rule{{Percent: float(80)}}, nil)
+
+	if got := engine.Evaluate(usageOutput("claude", 90)); len(got) != 1 {
+		t.Fatalf("expected claude to fire, got %d", len(got))
+	}
+	if got := engine.Evaluate(usageOutput("codex", 90)); len(got) != 1 {
+		t.Fatalf("expected codex to fire independently, got %d", len(got))
+	}
+
+	n := engine.Evaluate(usageOutput("cursor", 95))[0]
+	if n.Rule != "rule-1" || n.ProviderID != "cursor" || n.Label != "Session" {
+		t.Fatalf("unexpected notification identity: %+v", n)
+	}
+	if n.Percent != 95 || n.Threshold != "80%" {
+		t.Fatalf("unexpected notification values: %+v", n)
+	}
+	if !strings.Contains(n.Title, "Session passed 80%") {
+		t.Fatalf("unexpected title: %q", n.Title)
+	}
+}
+
+func TestEvaluateAbsoluteThreshold(t *testing.T) {
+	t.Parallel()
+
+	engine := NewEngine([]Rule{{Provider: "claude", Used: float(40), Hysteresis: float(0)}}, nil)
+
+	if got := engine.Evaluate(usageOutput("codex", 90)); len(got) != 0 {
+		t.Fatalf("expected provider filter to skip codex, got %d", len(got))
+	}
+	if got := engine.Evaluate(usageOutput("claude", 40)); len(got) != 1 {
+		t.Fatalf("expected threshold to fire at 40, got %d", len(got))
+	}
+	if got := engine.Evaluate(usageOutput("claude", 39.9)); len(got) != 0 {
+		t.Fatalf("expected re-arm without notification, got %d", len(got))
+	}
+	if got := engine.Evaluate(usageOutput("claude", 41)); len(got) != 1 {
+		t.Fatalf("expected second crossing to fire, got %d", len(got))
+	}
+}
+
+func TestHandleDeliversToSelectedSinks(t *testing.T) {
+	t.Parallel()
+
+	desktop := &recordingSink{}
+	broken := &recordingSink{fail: errors.New("boom")}
+	hook := &recordingSink{}
+	engine := NewEngine([]Rule{
+		{Name: "all", Percent: float(50)},
+		{Name: "hook-only", Percent: float(90), Sinks: []string{"hook"}},
+	}, map[string]Sink{"desktop": desktop, "broken": broken, "hook": hook})
+
+	engine.Handle(context.Background(), usageOutput("claude", 95))
+
+	if len(desktop.got) != 1 || desktop.got[0].Rule != "all" {
+		t.Fatalf("unexpected desktop deliveries: %+v", desktop.got)
+	}
+	if len(hook.got) != 2 {
+		t.Fatalf("expected hook to receive both rules, got %+v", hook.got)
+	}
+	if broken.calls != 1 {
+		t.Fatalf("expected failing sink to be attempted once, got %d", broken.calls)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "alerts.json")
+	content := `{
+  "sinks": [
+    {"name": "desktop", "type": "desktop"},
+    {"name": "hook", "type": "webhook", "url": "http://127.0.0.1:9/alerts"}
+  ],
+  "rules": [
+    {"name": "claude-session", "provider": "claude", "label": "Session", "percent": 80, "sinks": ["hook"]}
+  ]
+}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	if len(cfg.Sinks) != 2 || len(cfg.Rules) != 1 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	sinks, err := cfg.BuildSinks()
+	if err != nil {
+		t.Fatalf("BuildSinks error: %v", err)
+	}
+	if _, ok := sinks["hook"].(*WebhookSink); !ok {
+		t.Fatalf("expected webhook sink, got %T", sinks["hook"])
+	}
+	if _, ok := sinks["desktop"].(*DesktopSink); !ok {
+		t.Fatalf("expected desktop sink, got %T", sinks["desktop"])
+	}
+}
+
+func TestValidateRejectsBadRules(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{name: "no threshold", cfg: Config{Rules: []Rule{{}}}, want: "exactly one of percent and used"},
+		{name: "both thresholds", cfg: Config{Rules: []Rule{{Percent: float(1), Used: float(1)}}}, want: "exactly one of percent and used"},
+		{name: "unknown sink", cfg: Config{Rules: []Rule{{Percent: float(1), Sinks: []string{"nope"}}}}, want: `unknown sink "nope"`},
+		{name: "duplicate sink", cfg: Config{Sinks: []SinkConfig{{Name: "a", Type: "desktop"}, {Name: "a", Type: "desktop"}}}, want: "duplicate sink name"},
+	}
+
+	for _, tc := range tests {
+		err := tc.cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected error containing %q, got %v", tc.name, tc.want, err)
+		}
+	}
+}
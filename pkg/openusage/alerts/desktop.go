@@ -0,0 +1,63 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	notificationsDest   = "org.freedesktop.Notifications"
+	notificationsPath   = "/org/freedesktop/Notifications"
+	notificationsNotify = "org.freedesktop.Notifications.Notify"
+	notificationTimeout = int32(10000)
+)
+
+// busCaller is the subset of dbus.BusObject the desktop sink needs.
+type busCaller interface {
+	CallWithContext(ctx context.Context, method string, flags dbus.Flags, args ...any) *dbus.Call
+}
+
+// DesktopSink shows notifications through the freedesktop notification
+// service on the session bus.
+type DesktopSink struct {
+	connect func() (busCaller, func(), error)
+}
+
+func NewDesktopSink() *DesktopSink {
+	return &DesktopSink{connect: sessionNotifications}
+}
+
+func (s *DesktopSink) Notify(ctx context.Context, n Notification) error {
+	obj, closeConn, err := s.connect()
+	if err != nil {
+		return fmt.Errorf("connect to session bus: %w", err)
+	}
+	defer closeConn()
+
+	call := obj.CallWithContext(ctx, notificationsNotify, 0,
+		"gopenusage",     // app_name
+		uint32(0),        // replaces_id
+		"dialog-warning", // app_icon
+		n.Title,          // summary
+		n.Message,        // body
+		[]string{},       // actions
+		map[string]dbus.Variant{ // hints
+			"urgency": dbus.MakeVariant(byte(1)),
+		},
+		notificationTimeout, // expire_timeout
+	)
+	if call.Err != nil {
+		return fmt.Errorf("desktop notification failed: %w", call.Err)
+	}
+	return nil
+}
+
+func sessionNotifications() (busCaller, func(), error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn.Object(notificationsDest, notificationsPath), func() { _ = conn.Close() }, nil
+}
@@ -0,0 +1,137 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	SinkTypeDesktop = "desktop"
+	SinkTypeWebhook = "webhook"
+	SinkTypeExec    = "exec"
+)
+
+// SinkConfig configures one named sink. Fields apply by Type:
+// webhook uses URL and Headers, exec uses Command.
+type SinkConfig struct {
+	Name    string            `json:"name"`
+	Type    string            `json:"type"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Command []string          `json:"command,omitempty"`
+}
+
+// BuildSinks constructs the sinks declared in the config, keyed by name.
+func (c Config) BuildSinks() (map[string]Sink, error) {
+	sinks := make(map[string]Sink, len(c.Sinks))
+	for _, sc := range c.Sinks {
+		sink, err := sc.build()
+		if err != nil {
+			return nil, fmt.Errorf("sink %q: %w", sc.Name, err)
+		}
+		sinks[sc.Name] = sink
+	}
+	return sinks, nil
+}
+
+func (sc SinkConfig) build() (Sink, error) {
+	switch sc.Type {
+	case SinkTypeDesktop:
+		return NewDesktopSink(), nil
+	case SinkTypeWebhook:
+		if strings.TrimSpace(sc.URL) == "" {
+			return nil, fmt.Errorf("webhook url is required")
+		}
+		return &WebhookSink{URL: sc.URL, Headers: sc.Headers}, nil
+	case SinkTypeExec:
+		if len(sc.Command) == 0 {
+			return nil, fmt.Errorf("exec command is required")
+		}
+		return &ExecSink{Command: sc.Command}, nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", sc.Type)
+	}
+}
+
+// WebhookSink POSTs the notification as JSON.
+type WebhookSink struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+func (s *WebhookSink) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ExecSink runs a command with the notification as JSON on stdin and as
+// OPENUSAGE_ALERT_* environment variables.
+type ExecSink struct {
+	Command []string
+}
+
+func (s *ExecSink) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"OPENUSAGE_ALERT_RULE="+n.Rule,
+		"OPENUSAGE_ALERT_PROVIDER="+n.ProviderID,
+		"OPENUSAGE_ALERT_LABEL="+n.Label,
+		"OPENUSAGE_ALERT_USED="+strconv.FormatFloat(n.Used, 'f', -1, 64),
+		"OPENUSAGE_ALERT_LIMIT="+strconv.FormatFloat(n.Limit, 'f', -1, 64),
+		"OPENUSAGE_ALERT_PERCENT="+strconv.FormatFloat(n.Percent, 'f', -1, 64),
+		"OPENUSAGE_ALERT_TITLE="+n.Title,
+		"OPENUSAGE_ALERT_MESSAGE="+n.Message,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(output))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("exec %s: %s", s.Command[0], msg)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func testNotification() Notification {
+	return Notification{
+		Rule:        "session-80",
+		ProviderID:  "claude",
+		DisplayName: "Claude",
+		Label:       "Session",
+		Used:        85,
+		Limit:       100,
+		Percent:     85,
+		Format:      "percent",
+		Threshold:   "80%",
+		Title:       "Claude Session passed 80%",
+		Message:     "85% of 100% used (85%)",
+		Time:        time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestWebhookSinkPostsJSON(t *testing.T) {
+	t.Parallel()
+
+	var got Notification
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body error: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := &WebhookSink{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}}
+	if err := sink.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatalf("Notify error: %v", err)
+	}
+	if got.Rule != "session-80" || got.ProviderID != "claude" || got.Used != 85 {
+		t.Fatalf("unexpected payload: %+v", got)
+	}
+	if auth != "Bearer token" {
+		t.Fatalf("expected custom header, got %q", auth)
+	}
+}
+
+func TestWebhookSinkReportsHTTPErrors(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := (&WebhookSink{URL: server.URL}).Notify(context.Background(), testNotification())
+	if err == nil || !strings.Contains(err.Error(), "HTTP 502") {
+		t.Fatalf("expected HTTP 502 error, got %v", err)
+	}
+}
+
+func TestExecSinkPassesNotification(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	script := filepath.Join(dir, "notify.sh")
+	content := "#!/bin/sh\n{ echo \"$OPENUSAGE_ALERT_PROVIDER $OPENUSAGE_ALERT_LABEL $OPENUSAGE_ALERT_PERCENT\"; cat; } > \"$1\"\n"
+	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	sink := &ExecSink{Command: []string{script, out}}
+	if err := sink.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatalf("Notify error: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	envLine, body, _ := strings.Cut(string(data), "\n")
+	if envLine != "claude Session 85" {
+		t.Fatalf("unexpected env line: %q", envLine)
+	}
+	var got Notification
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("Unmarshal stdin error: %v", err)
+	}
+	if got.Title != "Claude Session passed 80%" {
+		t.Fatalf("unexpected stdin payload: %+v", got)
+	}
+}
+
+func TestExecSinkReportsFailureOutput(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	sink := &ExecSink{Command: []string{"sh", "-c", "echo nope >&2; exit 3"}}
+	err := sink.Notify(context.Background(), testNotification())
+	if err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Fatalf("expected stderr in error, got %v", err)
+	}
+}
+
+type fakeBus struct {
+	method string
+	args   []any
+	err    error
+}
+
+func (b *fakeBus) CallWithContext(_ context.Context, method string, _ dbus.Flags, args ...any) *dbus.Call {
+	b.method = method
+	b.args = args
+	return &dbus.Call{Err: b.err}
+}
+
+func TestDesktopSinkCallsNotify(t *testing.T) {
+	t.Parallel()
+
+	bus := &fakeBus{}
+	closed := false
+	sink := &DesktopSink{connect: func() (busCaller, func(), error) {
+		return bus, func() { closed = true }, nil
+	}}
+
+	if err := sink.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatalf("Notify error: %v", err)
+	}
+	if bus.method != "org.freedesktop.Notifications.Notify" {
+		t.Fatalf("unexpected method: %q", bus.method)
+	}
+	if len(bus.args) != 8 {
+		t.Fatalf("expected 8 Notify arguments, got %d", len(bus.args))
+	}
+	if bus.args[3] != "Claude Session passed 80%" || bus.args[4] != "85% of 100% used (85%)" {
+		t.Fatalf("unexpected summary/body: %v / %v", bus.args[3], bus.args[4])
+	}
+	if !closed {
+		t.Fatalf("expected bus connection to be closed")
+	}
+}
+
+func TestDesktopSinkReportsErrors(t *testing.T) {
+	t.Parallel()
+
+	sink := &DesktopSink{connect: func() (busCaller, func(), error) {
+		return nil, nil, errors.New("no session bus")
+	}}
+	if err := sink.Notify(context.Background(), testNotification()); err == nil || !strings.Contains(err.Error(), "no session bus") {
+		t.Fatalf("expected connect error, got %v", err)
+	}
+
+	bus := &fakeBus{err: errors.New("service unknown")}
+	sink = &DesktopSink{connect: func() (busCaller, func(), error) { return bus, func() {}, nil }}
+	if err := sink.Notify(context.Background(), testNotification()); err == nil || !strings.Contains(err.Error(), "service unknown") {
+		t.Fatalf("expected call error, got %v", err)
+	}
+}
@@ -2,23 +2,37 @@ package builtin
 
 import (
 	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/jsplugin"
 	"github.com/deicod/gopenusage/pkg/openusage/plugins/antigravity"
 	"github.com/deicod/gopenusage/pkg/openusage/plugins/claude"
+	"github.com/deicod/gopenusage/pkg/openusage/plugins/claudelogs"
 	"github.com/deicod/gopenusage/pkg/openusage/plugins/codex"
+	"github.com/deicod/gopenusage/pkg/openusage/plugins/codexlogs"
 	"github.com/deicod/gopenusage/pkg/openusage/plugins/copilot"
 	"github.com/deicod/gopenusage/pkg/openusage/plugins/cursor"
 	"github.com/deicod/gopenusage/pkg/openusage/plugins/mock"
 	"github.com/deicod/gopenusage/pkg/openusage/plugins/windsurf"
+	"github.com/deicod/gopenusage/pkg/openusage/wasmplugin"
 )
 
 func Plugins() []openusage.Plugin {
 	return []openusage.Plugin{
 		antigravity.New(),
 		claude.New(),
+		claudelogs.New(),
 		codex.New(),
+		codexlogs.New(),
 		copilot.New(),
 		cursor.New(),
 		mock.New(),
 		windsurf.New(),
 	}
 }
+
+// PluginFactories loads JavaScript and WebAssembly manifest entries.
+func PluginFactories() openusage.PluginFactories {
+	return openusage.PluginFactories{
+		".js":   jsplugin.Factory,
+		".wasm": wasmplugin.Factory,
+	}
+}
@@ -0,0 +1,73 @@
+package openusage
+
+import (
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	output    PluginOutput
+	fetchedAt time.Time
+}
+
+type resultCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func newResultCache() *resultCache {
+	return &resultCache{entries: make(map[string]cacheEntry)}
+}
+
+func (c *resultCache) get(id string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[id]
+	return entry, ok
+}
+
+func (c *resultCache) put(id string, output PluginOutput, fetchedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[id] = cacheEntry{output: output, fetchedAt: fetchedAt}
+}
+
+// flightGroup merges concurrent fetches for the same key into a single call.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done   chan struct{}
+	output PluginOutput
+	err    error
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{calls: make(map[string]*flightCall)}
+}
+
+// start runs fn for key unless a call for key is already in flight, in which
+// case the existing call is returned. Callers wait on call.done.
+func (g *flightGroup) start(key string, fn func() (PluginOutput, error)) *flightCall {
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		return call
+	}
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	go func() {
+		call.output, call.err = fn()
+
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	return call
+}
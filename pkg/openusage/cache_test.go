@@ -0,0 +1,192 @@
+package openusage
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newCountingManager(t *testing.T, ttl time.Duration, calls *atomic.Int32, fn func(context.Context) error) (*Manager, *fakeClock) {
+	t.Helper()
+
+	manager, err := NewManager(Options{
+		DataDir:  t.TempDir(),
+		CacheTTL: ttl,
+	}, []Plugin{
+		stubPlugin{
+			id: "alpha",
+			fn: func(ctx context.Context, _ *pluginruntime.Env) (QueryResult, error) {
+				n := calls.Add(1)
+				if fn != nil {
+					if err := fn(ctx); err != nil {
+						return QueryResult{}, err
+					}
+				}
+				return QueryResult{Plan: "Pro", Lines: []MetricLine{NewTextLine("Calls", strconv.Itoa(int(n)), TextLineOptions{})}}, nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	manager.now = clock.Now
+	return manager, clock
+}
+
+func TestQueryOneServesFreshResultFromCache(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	manager, _ := newCountingManager(t, time.Minute, &calls, nil)
+
+	first, err := manager.QueryOne(context.Background(), "alpha")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	if first.Cached {
+		t.Fatalf("first result must not be marked cached")
+	}
+
+	second, err := manager.QueryOne(context.Background(), "alpha")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	if !second.Cached {
+		t.Fatalf("second result should come from cache")
+	}
+	if second.FetchedAt != first.FetchedAt {
+		t.Fatalf("cached fetchedAt changed: %s != %s", second.FetchedAt, first.FetchedAt)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected one upstream call, got %d", got)
+	}
+}
+
+func TestQueryOneServesStaleWhileRefreshing(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	manager, clock := newCountingManager(t, time.Minute, &calls, func(context.Context) error {
+		if calls.Load() > 1 {
+			<-release
+		}
+		return nil
+	})
+
+	if _, err := manager.QueryOne(context.Background(), "alpha"); err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	clock.Advance(2 * time.Minute)
+
+	for i := 0; i < 3; i++ {
+		out, err := manager.QueryOne(context.Background(), "alpha")
+		if err != nil {
+			t.Fatalf("QueryOne error: %v", err)
+		}
+		if !out.Cached || *out.Lines[0].Value != "1" {
+			t.Fatalf("expected stale cached result, got %+v", out)
+		}
+	}
+	close(release)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		out, err := manager.QueryOne(context.Background(), "alpha")
+		if err != nil {
+			t.Fatalf("QueryOne error: %v", err)
+		}
+		if *out.Lines[0].Value == "2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("background refresh never landed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("expected exactly one background refresh, got %d calls", got)
+	}
+}
+
+func TestQueryOneMergesConcurrentMisses(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	manager, _ := newCountingManager(t, time.Minute, &calls, func(context.Context) error {
+		<-release
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	results := make([]PluginOutput, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			out, err := manager.QueryOne(context.Background(), "alpha")
+			if err != nil {
+				t.Errorf("QueryOne error: %v", err)
+			}
+			results[i] = out
+		}(i)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected one merged upstream call, got %d", got)
+	}
+	for _, out := range results {
+		if out.Plan != "Pro" {
+			t.Fatalf("unexpected merged result: %+v", out)
+		}
+	}
+}
+
+func TestQueryOneNegativeTTLDisablesCache(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	manager, _ := newCountingManager(t, -1, &calls, nil)
+
+	for i := 0; i < 2; i++ {
+		out, err := manager.QueryOne(context.Background(), "alpha")
+		if err != nil {
+			t.Fatalf("QueryOne error: %v", err)
+		}
+		if out.Cached {
+			t.Fatalf("result must not be cached when caching is disabled")
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("expected two upstream calls, got %d", got)
+	}
+}
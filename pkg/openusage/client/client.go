@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"net/url"
@@ -21,22 +20,55 @@ type Options struct {
 	BaseURL    string
 	SocketPath string
 	Timeout    time.Duration
+	// Scope limits usage outputs to openusage.ScopeOverview, ScopeDetail or
+	// ScopePrimary lines; empty returns every line.
+	Scope string
+	// OnError, when set, receives the errors the client recovers from, such
+	// as failed stream reconnects and undecodable stream events.
+	OnError func(error)
 }
 
 type Client struct {
 	baseURL    *url.URL
+	scope      string
+	onError    func(error)
 	httpClient *http.Client
+	// streamClient shares the transport but has no overall timeout, which
+	// would cut long-lived streams.
+	streamClient *http.Client
+}
+
+// HistoryQuery selects recorded usage. Zero values fall back to the server
+// defaults: all plugins and labels over the last 24h at full resolution.
+type HistoryQuery struct {
+	Plugins []string
+	Label   string
+	From    time.Time
+	To      time.Time
+	// Range is a window ending at To (or now); ignored when From is set.
+	Range time.Duration
+	Step  time.Duration
 }
 
 type APIError struct {
 	StatusCode int
 	Message    string
+	// Detail classifies the failure when the server reported a plugin error.
+	Detail *openusage.PluginError
 }
 
 func (e *APIError) Error() string {
 	return fmt.Sprintf("request failed (HTTP %d): %s", e.StatusCode, e.Message)
 }
 
+// Unwrap lets errors.As find the server's *openusage.PluginError.
+func (e *APIError) Unwrap() error {
+	if e.Detail == nil {
+		return nil
+	}
+	return e.Detail
+}
+
 func New(opts Options) (*Client, error) {
 	base, err := normalizeBaseURL(opts.BaseURL, opts.SocketPath != "")
 	if err != nil {
@@ -60,14 +92,17 @@ func New(opts Options) (*Client, error) {
 	}
 
 	return &Client{
-		baseURL:    base,
-		httpClient: httpClient,
+		baseURL:      base,
+		scope:        strings.TrimSpace(opts.Scope),
+		onError:      opts.OnError,
+		httpClient:   httpClient,
+		streamClient: &http.Client{Transport: httpClient.Transport},
 	}, nil
 }
 
 func (c *Client) QueryAll(ctx context.Context) ([]openusage.PluginOutput, error) {
 	var output []openusage.PluginOutput
-	if err := c.getJSON(ctx, "/v1/usage", nil, &output); err != nil {
+	if err := c.getJSON(ctx, "/v1/usage", c.usageQuery(), &output); err != nil {
 		return nil, err
 	}
 	return output, nil
@@ -86,7 +121,7 @@ func (c *Client) QueryPlugins(ctx context.Context, pluginIDs []string) ([]openus
 		return c.QueryAll(ctx)
 	}
 
-	query := url.Values{}
+	query := c.usageQuery()
 	query.Set("plugins", strings.Join(filtered, ","))
 
 	var output []openusage.PluginOutput
@@ -103,18 +138,94 @@ func (c *Client) QueryOne(ctx context.Context, pluginID string) (openusage.Plugi
 	}
 
 	var output openusage.PluginOutput
-	if err := c.getJSON(ctx, "/v1/usage/"+url.PathEscape(id), nil, &output); err != nil {
+	if err := c.getJSON(ctx, "/v1/usage/"+url.PathEscape(id), c.usageQuery(), &output); err != nil {
 		return openusage.PluginOutput{}, err
 	}
 	return output, nil
 }
 
-func (c *Client) getJSON(ctx context.Context, path string, query url.Values, target any) error {
+// ListPlugins returns the metadata of every plugin known to the server,
+// including disabled ones.
+func (c *Client) ListPlugins(ctx context.Context) ([]openusage.PluginInfo, error) {
+	var plugins []openusage.PluginInfo
+	if err := c.getJSON(ctx, "/v1/plugins", nil, &plugins); err != nil {
+		return nil, err
+	}
+	return plugins, nil
+}
+
+func (c *Client) GetPlugin(ctx context.Context, pluginID string) (openusage.PluginInfo, error) {
+	id := strings.TrimSpace(pluginID)
+	if id == "" {
+		return openusage.PluginInfo{}, fmt.Errorf("plugin id is required")
+	}
+
+	var plugin openusage.PluginInfo
+	if err := c.getJSON(ctx, "/v1/plugins/"+url.PathEscape(id), nil, &plugin); err != nil {
+		return openusage.PluginInfo{}, err
+	}
+	return plugin, nil
+}
+
+func (c *Client) History(ctx context.Context, q HistoryQuery) ([]openusage.HistorySeries, error) {
+	query := url.Values{}
+	ids := make([]string, 0, len(q.Plugins))
+	for _, id := range q.Plugins {
+		if trimmed := strings.TrimSpace(id); trimmed != "" {
+			ids = append(ids, trimmed)
+		}
+	}
+	if len(ids) > 0 {
+		query.Set("plugins", strings.Join(ids, ","))
+	}
+	if label := strings.TrimSpace(q.Label); label != "" {
+		query.Set("label", label)
+	}
+	if !q.From.IsZero() {
+		query.Set("from", q.From.UTC().Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		query.Set("to", q.To.UTC().Format(time.RFC3339))
+	}
+	if q.Range > 0 {
+		query.Set("range", q.Range.String())
+	}
+	if q.Step > 0 {
+		query.Set("step", q.Step.String())
+	}
+
+	var output []openusage.HistorySeries
+	if err := c.getJSON(ctx, "/v1/history", query, &output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// usageQuery returns the query parameters shared by usage requests.
+func (c *Client) usageQuery() url.Values {
+	query := url.Values{}
+	if c.scope != "" {
+		query.Set("scope", c.scope)
+	}
+	return query
+}
+
+func (c *Client) endpoint(path string, query url.Values) string {
 	targetURL := *c.baseURL
 	targetURL.Path = strings.TrimRight(targetURL.Path, "/") + path
 	targetURL.RawQuery = query.Encode()
+	return targetURL.String()
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL.String(), nil)
+// reportError passes an error the client recovered from to Options.OnError.
+func (c *Client) reportError(err error) {
+	if c.onError != nil {
+		c.onError(err)
+	}
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint(path, query), nil)
 	if err != nil {
 		return err
 	}
@@ -126,7 +237,7 @@ func (c *Client) getJSON(ctx context.Context, path string, query url.Values, tar
 	}
 	defer func() {
 		if closeErr := resp.Body.Close(); closeErr != nil {
-			log.Printf("openusage client: close response body: %v", closeErr)
+			c.reportError(fmt.Errorf("close response body: %w", closeErr))
 		}
 	}()
 
@@ -136,10 +247,7 @@ func (c *Client) getJSON(ctx context.Context, path string, query url.Values, tar
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return &APIError{
-			StatusCode: resp.StatusCode,
-			Message:    errorMessage(body),
-		}
+		return newAPIError(resp.StatusCode, body)
 	}
 
 	if err := json.Unmarshal(body, target); err != nil {
@@ -172,17 +280,20 @@ func normalizeBaseURL(rawBaseURL string, hasSocket bool) (*url.URL, error) {
 	return u, nil
 }
 
-func errorMessage(body []byte) string {
-	trimmed := strings.TrimSpace(string(body))
-	if trimmed == "" {
-		return "empty response"
+func newAPIError(status int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: status, Message: strings.TrimSpace(string(body))}
+	if apiErr.Message == "" {
+		apiErr.Message = "empty response"
+		return apiErr
 	}
 
 	var payload struct {
-		Error string `json:"error"`
+		Error       string                 `json:"error"`
+		ErrorDetail *openusage.PluginError `json:"errorDetail"`
 	}
 	if err := json.Unmarshal(body, &payload); err == nil && strings.TrimSpace(payload.Error) != "" {
-		return strings.TrimSpace(payload.Error)
+		apiErr.Message = strings.TrimSpace(payload.Error)
+		apiErr.Detail = payload.ErrorDetail
 	}
-	return trimmed
+	return apiErr
 }
@@ -3,9 +3,11 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/deicod/gopenusage/pkg/openusage"
 )
@@ -48,6 +50,52 @@ func TestQueryOne(t *testing.T) {
 	}
 }
 
+func TestQueryPluginsSendsScope(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.RawQuery; got != "plugins=claude%2Ccodex&scope=primary" {
+			t.Fatalf("unexpected query: %s", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	c, err := New(Options{BaseURL: srv.URL, Scope: openusage.ScopePrimary})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	if _, err := c.QueryPlugins(context.Background(), []string{"claude", "codex"}); err != nil {
+		t.Fatalf("QueryPlugins error: %v", err)
+	}
+}
+
+func TestGetPlugin(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/plugins/copilot" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(openusage.PluginInfo{ID: "copilot", DisplayName: "Copilot", BrandColor: "#000000", Implemented: true, Enabled: true})
+	}))
+	defer srv.Close()
+
+	c, err := New(Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	got, err := c.GetPlugin(context.Background(), "copilot")
+	if err != nil {
+		t.Fatalf("GetPlugin error: %v", err)
+	}
+	if got.DisplayName != "Copilot" || got.BrandColor != "#000000" || !got.Implemented {
+		t.Fatalf("unexpected plugin: %+v", got)
+	}
+}
+
 func TestQueryAll(t *testing.T) {
 	t.Parallel()
 
@@ -112,3 +160,59 @@ func TestQueryOneReturnsAPIError(t *testing.T) {
 		t.Fatalf("unexpected message: %s", apiErr.Message)
 	}
 }
+
+func TestAPIErrorCarriesDetail(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusGatewayTimeout)
+		_, _ = w.Write([]byte(`{"error":"context deadline exceeded","errorDetail":{"code":"timeout","message":"context deadline exceeded","retryable":true,"httpStatus":504}}`))
+	}))
+	defer srv.Close()
+
+	c, err := New(Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	_, err = c.QueryOne(context.Background(), "claude")
+	var detail *openusage.PluginError
+	if !errors.As(err, &detail) || detail.Code != openusage.ErrorTimeout || !detail.Retryable {
+		t.Fatalf("expected timeout detail, got %v", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/history" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("plugins") != "claude" || q.Get("label") != "Weekly" || q.Get("range") != "48h0m0s" || q.Get("step") != "1h0m0s" {
+			t.Fatalf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"providerId":"claude","label":"Weekly","format":"percent","points":[{"time":"2026-03-01T12:00:00Z","used":42,"limit":100}]}]`))
+	}))
+	defer srv.Close()
+
+	c, err := New(Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	got, err := c.History(context.Background(), HistoryQuery{
+		Plugins: []string{"claude"},
+		Label:   "Weekly",
+		Range:   48 * time.Hour,
+		Step:    time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("History error: %v", err)
+	}
+	if len(got) != 1 || len(got[0].Points) != 1 || got[0].Points[0].Used != 42 {
+		t.Fatalf("unexpected history: %+v", got)
+	}
+}
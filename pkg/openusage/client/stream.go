@@ -0,0 +1,177 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+)
+
+const (
+	defaultStreamRetry = 3 * time.Second
+	maxStreamRetry     = 30 * time.Second
+	// streamIdleTimeout reconnects when neither events nor heartbeats arrive;
+	// the server sends heartbeats every 15s by default.
+	streamIdleTimeout = 45 * time.Second
+)
+
+// streamState is carried across reconnects.
+type streamState struct {
+	lastEventID string
+	retry       time.Duration
+}
+
+// Subscribe streams plugin outputs from /v1/usage/stream, starting with the
+// current output of every selected plugin (all when pluginIDs is empty). The
+// stream reconnects with Last-Event-ID after errors, so only changes missed
+// while disconnected are redelivered. The channel is closed when ctx ends.
+// An error is returned only if the first connection fails.
+func (c *Client) Subscribe(ctx context.Context, pluginIDs []string) (<-chan openusage.PluginOutput, error) {
+	query := c.usageQuery()
+	ids := make([]string, 0, len(pluginIDs))
+	for _, id := range pluginIDs {
+		if trimmed := strings.TrimSpace(id); trimmed != "" {
+			ids = append(ids, trimmed)
+		}
+	}
+	if len(ids) > 0 {
+		query.Set("plugins", strings.Join(ids, ","))
+	}
+	endpoint := c.endpoint("/v1/usage/stream", query)
+
+	state := &streamState{retry: defaultStreamRetry}
+	body, err := c.openStream(ctx, endpoint, state.lastEventID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan openusage.PluginOutput)
+	go func() {
+		defer close(out)
+
+		failures := 0
+		for {
+			if body != nil {
+				failures = 0
+				c.readStream(ctx, body, state, out)
+				_ = body.Close()
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(reconnectDelay(state.retry, failures)):
+			}
+
+			body, err = c.openStream(ctx, endpoint, state.lastEventID)
+			if err != nil {
+				failures++
+				if ctx.Err() == nil {
+					c.reportError(fmt.Errorf("reconnect stream: %w", err))
+				}
+				body = nil
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func reconnectDelay(retry time.Duration, failures int) time.Duration {
+	delay := retry
+	for i := 0; i < failures && delay < maxStreamRetry; i++ {
+		delay *= 2
+	}
+	return min(delay, maxStreamRetry)
+}
+
+// openStream connects and returns the response body once the server has
+// accepted the stream. The body's lifetime is bounded by ctx.
+func (c *Client) openStream(ctx context.Context, endpoint, lastEventID string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := c.streamClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newAPIError(resp.StatusCode, body)
+	}
+	return resp.Body, nil
+}
+
+// readStream parses server-sent events until the stream ends, goes idle or
+// ctx is done, delivering usage events to out.
+func (c *Client) readStream(ctx context.Context, body io.ReadCloser, state *streamState, out chan<- openusage.PluginOutput) {
+	idle := time.AfterFunc(streamIdleTimeout, func() { _ = body.Close() })
+	defer idle.Stop()
+
+	reader := bufio.NewReader(body)
+	var id, event string
+	var data []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		idle.Reset(streamIdleTimeout)
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if len(data) > 0 && (event == "" || event == "usage") {
+				var output openusage.PluginOutput
+				if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &output); err != nil {
+					c.reportError(fmt.Errorf("invalid stream event: %w", err))
+				} else {
+					select {
+					case out <- output:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			if id != "" {
+				state.lastEventID = id
+			}
+			id, event, data = "", "", nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			id = value
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms > 0 {
+				state.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
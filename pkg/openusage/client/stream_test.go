@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+)
+
+func TestSubscribeReconnectsWithLastEventID(t *testing.T) {
+	t.Parallel()
+
+	var connections atomic.Int64
+	lastIDs := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/usage/stream" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("plugins"); got != "codex" {
+			t.Errorf("unexpected plugins query: %q", got)
+		}
+		n := connections.Add(1)
+		select {
+		case lastIDs <- r.Header.Get("Last-Event-ID"):
+		default:
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		output, _ := json.Marshal(openusage.PluginOutput{ProviderID: "codex", Plan: fmt.Sprintf("plan-%d", n)})
+		_, _ = fmt.Fprintf(w, "retry: 10\n\n: heartbeat\n\nid: ep-%d\nevent: usage\ndata: %s\n\n", n, output)
+		// Ending the response simulates a dropped connection.
+	}))
+	defer srv.Close()
+
+	c, err := New(Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	updates, err := c.Subscribe(ctx, []string{" codex "})
+	if err != nil {
+		t.Fatalf("Subscribe error: %v", err)
+	}
+
+	for i, want := range []string{"plan-1", "plan-2"} {
+		select {
+		case got := <-updates:
+			if got.ProviderID != "codex" || got.Plan != want {
+				t.Fatalf("update %d: unexpected output %+v", i, got)
+			}
+		case <-ctx.Done():
+			t.Fatalf("update %d: timed out", i)
+		}
+	}
+
+	if got := <-lastIDs; got != "" {
+		t.Fatalf("expected first connection without Last-Event-ID, got %q", got)
+	}
+	if got := <-lastIDs; got != "ep-1" {
+		t.Fatalf("expected reconnect with Last-Event-ID ep-1, got %q", got)
+	}
+
+	cancel()
+	for range updates {
+	}
+}
+
+func TestSubscribeReturnsInitialError(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"not found"}`))
+	}))
+	defer srv.Close()
+
+	c, err := New(Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	_, err = c.Subscribe(context.Background(), nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected APIError 404, got %v", err)
+	}
+}
+
+func TestSubscribeReportsRecoveredErrors(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		output, _ := json.Marshal(openusage.PluginOutput{ProviderID: "codex"})
+		_, _ = fmt.Fprintf(w, "event: usage\ndata: {broken\n\nevent: usage\ndata: %s\n\n", output)
+	}))
+	defer srv.Close()
+
+	errs := make(chan error, 16)
+	c, err := New(Options{BaseURL: srv.URL, OnError: func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	updates, err := c.Subscribe(ctx, nil)
+	if err != nil {
+		t.Fatalf("Subscribe error: %v", err)
+	}
+
+	select {
+	case got := <-updates:
+		if got.ProviderID != "codex" {
+			t.Fatalf("unexpected output %+v", got)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for an update")
+	}
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "invalid stream event") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the error")
+	}
+
+	cancel()
+	for range updates {
+	}
+}
+
+func TestReconnectDelay(t *testing.T) {
+	t.Parallel()
+
+	if got := reconnectDelay(time.Second, 0); got != time.Second {
+		t.Fatalf("expected base delay, got %s", got)
+	}
+	if got := reconnectDelay(time.Second, 2); got != 4*time.Second {
+		t.Fatalf("expected doubled delay, got %s", got)
+	}
+	if got := reconnectDelay(time.Second, 10); got != maxStreamRetry {
+		t.Fatalf("expected capped delay, got %s", got)
+	}
+}
@@ -0,0 +1,142 @@
+package openusage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+// ErrorCode classifies why a plugin query failed.
+type ErrorCode string
+
+const (
+	// ErrorAuthRequired means no credentials were found.
+	ErrorAuthRequired ErrorCode = "auth_required"
+	// ErrorAuthExpired means credentials were found but rejected or expired.
+	ErrorAuthExpired ErrorCode = "auth_expired"
+	// ErrorNetwork means the provider could not be reached.
+	ErrorNetwork ErrorCode = "network"
+	// ErrorUpstreamHTTP means the provider answered with an unexpected status.
+	ErrorUpstreamHTTP ErrorCode = "upstream_http"
+	// ErrorParse means the provider's response or local data was not understood.
+	ErrorParse ErrorCode = "parse"
+	// ErrorNotRunning means a local app the plugin reads from is not running.
+	ErrorNotRunning ErrorCode = "not_running"
+	// ErrorNotConfigured means there is nothing to read yet, e.g. the local
+	// logs of a tool that was never used on this machine.
+	ErrorNotConfigured ErrorCode = "not_configured"
+	// ErrorUnsupportedPlatform means the plugin cannot work on this OS.
+	ErrorUnsupportedPlatform ErrorCode = "unsupported_platform"
+	// ErrorTimeout means the query exceeded its deadline.
+	ErrorTimeout ErrorCode = "timeout"
+)
+
+// errorDefaults holds whether retrying can help and the HTTP status that
+// represents each code.
+var errorDefaults = map[ErrorCode]struct {
+	retryable bool
+	status    int
+}{
+	ErrorAuthRequired:        {false, http.StatusUnauthorized},
+	ErrorAuthExpired:         {false, http.StatusUnauthorized},
+	ErrorNetwork:             {true, http.StatusBadGateway},
+	ErrorUpstreamHTTP:        {true, http.StatusBadGateway},
+	ErrorParse:               {false, http.StatusBadGateway},
+	ErrorNotRunning:          {true, http.StatusServiceUnavailable},
+	ErrorNotConfigured:       {false, http.StatusNotFound},
+	ErrorUnsupportedPlatform: {false, http.StatusNotImplemented},
+	ErrorTimeout:             {true, http.StatusGatewayTimeout},
+}
+
+// Valid reports whether c is one of the defined codes.
+func (c ErrorCode) Valid() bool {
+	_, ok := errorDefaults[c]
+	return ok
+}
+
+// PluginError is a classified plugin failure. Plugins return it from Query,
+// and it is reported as PluginOutput.ErrorDetail.
+type PluginError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	// Retryable is true when the same query may succeed later without the
+	// user doing anything.
+	Retryable bool `json:"retryable"`
+	// Hint tells the user how to fix the problem, e.g. "run `claude` to
+	// authenticate".
+	Hint string `json:"hint,omitempty"`
+	// HTTPStatus is the status the JSON API uses for this error.
+	HTTPStatus int   `json:"httpStatus"`
+	Err        error `json:"-"`
+}
+
+// NewPluginError returns an error with the code's default retryable flag and
+// HTTP status.
+func NewPluginError(code ErrorCode, message, hint string) *PluginError {
+	defaults := errorDefaults[code]
+	return &PluginError{
+		Code:       code,
+		Message:    message,
+		Retryable:  defaults.retryable,
+		Hint:       hint,
+		HTTPStatus: defaults.status,
+	}
+}
+
+// UpstreamStatusError reports an unexpected status from a provider's API;
+// only 429 and 5xx responses are retryable.
+func UpstreamStatusError(status int, hint string) *PluginError {
+	err := NewPluginError(ErrorUpstreamHTTP, fmt.Sprintf("usage request failed (HTTP %d)", status), hint)
+	err.Retryable = status == http.StatusTooManyRequests || status >= 500
+	return err
+}
+
+// Error returns the message followed by the hint, if any.
+func (e *PluginError) Error() string {
+	if e.Hint == "" {
+		return e.Message
+	}
+	return e.Message + "; " + e.Hint
+}
+
+func (e *PluginError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyError returns the PluginError in err's chain. Untyped deadline,
+// network, JSON decoding and unsupported language server discovery errors
+// are classified by type; anything else returns nil.
+func ClassifyError(err error) *PluginError {
+	if err == nil {
+		return nil
+	}
+	var pluginErr *PluginError
+	if errors.As(err, &pluginErr) {
+		return pluginErr
+	}
+
+	var code ErrorCode
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		code = ErrorTimeout
+	case errors.As(err, &netErr):
+		code = ErrorNetwork
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		code = ErrorParse
+	case errors.Is(err, pluginruntime.ErrLSDiscoveryUnsupported):
+		code = ErrorUnsupportedPlatform
+	default:
+		return nil
+	}
+	classified := NewPluginError(code, err.Error(), "")
+	classified.Err = err
+	return classified
+}
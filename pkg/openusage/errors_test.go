@@ -0,0 +1,91 @@
+package openusage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+func TestPluginErrorDefaults(t *testing.T) {
+	t.Parallel()
+
+	err := NewPluginError(ErrorAuthRequired, "not logged in", "run `claude` to authenticate")
+	if err.Error() != "not logged in; run `claude` to authenticate" {
+		t.Fatalf("unexpected message: %s", err.Error())
+	}
+	if err.Retryable || err.HTTPStatus != http.StatusUnauthorized {
+		t.Fatalf("unexpected defaults: %+v", err)
+	}
+
+	for status, retryable := range map[int]bool{404: false, 429: true, 503: true} {
+		if got := UpstreamStatusError(status, "").Retryable; got != retryable {
+			t.Fatalf("HTTP %d: expected retryable %v, got %v", status, retryable, got)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	typed := NewPluginError(ErrorNotRunning, "app is not running", "")
+	cases := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"typed", fmt.Errorf("query: %w", typed), ErrorNotRunning},
+		{"deadline", fmt.Errorf("fetch: %w", context.DeadlineExceeded), ErrorTimeout},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrorNetwork},
+		{"parse", json.Unmarshal([]byte("{"), &struct{}{}), ErrorParse},
+		{"ls discovery", pluginruntime.ErrLSDiscoveryUnsupported, ErrorUnsupportedPlatform},
+		{"unknown", errors.New("no active subscription"), ""},
+	}
+	for _, tc := range cases {
+		got := ClassifyError(tc.err)
+		if tc.want == "" {
+			if got != nil {
+				t.Fatalf("%s: expected no classification, got %+v", tc.name, got)
+			}
+			continue
+		}
+		if got == nil || got.Code != tc.want {
+			t.Fatalf("%s: expected %s, got %+v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestManagerReportsErrorDetail(t *testing.T) {
+	t.Parallel()
+
+	manager, err := NewManager(Options{DataDir: t.TempDir(), PluginTimeout: 50 * time.Millisecond}, []Plugin{
+		stubPlugin{id: "expired", fn: func(context.Context, *pluginruntime.Env) (QueryResult, error) {
+			return QueryResult{}, NewPluginError(ErrorAuthExpired, "token expired", "log in again")
+		}},
+		stubPlugin{id: "slow", fn: func(ctx context.Context, _ *pluginruntime.Env) (QueryResult, error) {
+			<-ctx.Done()
+			return QueryResult{}, ctx.Err()
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	outputs, err := manager.QueryAll(context.Background(), []string{"expired", "slow"})
+	if err != nil {
+		t.Fatalf("QueryAll error: %v", err)
+	}
+
+	expired, slow := outputs[0], outputs[1]
+	if expired.Error != "token expired; log in again" || expired.ErrorDetail == nil || expired.ErrorDetail.Code != ErrorAuthExpired {
+		t.Fatalf("unexpected expired output: %+v", expired)
+	}
+	if slow.ErrorDetail == nil || slow.ErrorDetail.Code != ErrorTimeout || !slow.ErrorDetail.Retryable || slow.ErrorDetail.Hint != `raise plugins."slow".timeout` {
+		t.Fatalf("unexpected slow output: %+v", slow)
+	}
+}
@@ -0,0 +1,324 @@
+package openusage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+// ExternalProtocolVersion is sent with every query so plugins can reject a
+// host they do not understand.
+const ExternalProtocolVersion = 1
+
+// ExternalMessageLimit caps a single message from an external or
+// WebAssembly plugin.
+const ExternalMessageLimit = 16 << 20
+
+// externalExitGrace is how long a plugin may take to exit after answering.
+const externalExitGrace = 2 * time.Second
+
+var errNoAnswer = errors.New("plugin exited without answering the query")
+
+// ExternalPlugin runs a manifest's entry executable once per query. Host and
+// plugin exchange JSON-RPC 2.0 messages, one per line, over the process's
+// stdin and stdout:
+//
+//   - the host sends a "query" request with id 1 and the plugin's environment;
+//   - the plugin may call pluginruntime.Host methods, as requests with its own
+//     IDs (each gets a response) or as notifications;
+//   - the plugin answers the query with {plan, lines, source}, or with an
+//     error whose data may carry the same fields.
+//
+// Stderr goes to the plugin logger, and its last line is added to the error
+// when the process fails. A crash or malformed message only fails that query.
+type ExternalPlugin struct {
+	id          string
+	dir         string
+	entry       string
+	permissions pluginruntime.Permissions
+}
+
+// NewExternalPlugin returns the plugin for a manifest whose entry is an
+// executable inside the plugin dir.
+func NewExternalPlugin(loaded LoadedManifest) (*ExternalPlugin, error) {
+	manifest := loaded.Manifest
+	if manifest.Entry == "" {
+		return nil, fmt.Errorf("plugin %s has no entry", manifest.ID)
+	}
+	if !filepath.IsLocal(manifest.Entry) {
+		return nil, fmt.Errorf("plugin %s entry %q is outside the plugin dir", manifest.ID, manifest.Entry)
+	}
+	entry := filepath.Join(loaded.PluginDir, manifest.Entry)
+	if !isExecutable(entry) {
+		return nil, fmt.Errorf("plugin %s entry %s is not executable", manifest.ID, entry)
+	}
+	return &ExternalPlugin{
+		id:          manifest.ID,
+		dir:         loaded.PluginDir,
+		entry:       entry,
+		permissions: manifest.Permissions,
+	}, nil
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".exe", ".bat", ".cmd", ".com":
+			return true
+		}
+		return false
+	}
+	return info.Mode().Perm()&0o111 != 0
+}
+
+func (p *ExternalPlugin) ID() string {
+	return p.id
+}
+
+func (p *ExternalPlugin) Query(ctx context.Context, env *pluginruntime.Env) (QueryResult, error) {
+	cmd := exec.CommandContext(ctx, p.entry)
+	cmd.Dir = p.dir
+	stderr := pluginruntime.NewOutputTail(env.Logger)
+	cmd.Stderr = stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return QueryResult{}, fmt.Errorf("open plugin stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return QueryResult{}, fmt.Errorf("open plugin stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return QueryResult{}, fmt.Errorf("start plugin: %w", err)
+	}
+
+	result, runErr := p.converse(ctx, env, stdin, stdout)
+	_ = stdin.Close()
+	if runErr != nil {
+		_ = cmd.Process.Kill()
+	}
+	waitErr := waitWithGrace(cmd, externalExitGrace)
+	stderr.Flush()
+
+	if runErr == nil {
+		return result, nil
+	}
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	if errors.Is(runErr, errNoAnswer) && waitErr != nil {
+		runErr = fmt.Errorf("plugin exited without answering: %w", waitErr)
+	}
+	if line := stderr.LastLine(); line != "" {
+		return result, fmt.Errorf("%w: %s", runErr, line)
+	}
+	return result, runErr
+}
+
+// waitWithGrace waits for the process to exit, killing it after grace.
+func waitWithGrace(cmd *exec.Cmd, grace time.Duration) error {
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(grace):
+		_ = cmd.Process.Kill()
+		return <-done
+	}
+}
+
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+const (
+	rpcMethodNotFound   = -32601
+	rpcServerError      = -32000
+	rpcPermissionDenied = -32001
+)
+
+type externalQuery struct {
+	ProtocolVersion int    `json:"protocolVersion"`
+	PluginID        string `json:"pluginId"`
+	DataDir         string `json:"dataDir"`
+	PluginDataDir   string `json:"pluginDataDir"`
+	CredentialsPath string `json:"credentialsPath,omitempty"`
+	// Account is set for account instances; see pluginruntime.Env.Account.
+	Account string `json:"account,omitempty"`
+}
+
+// ExternalQueryParams returns the params of the query request for env, as
+// external and WebAssembly plugins receive them.
+func ExternalQueryParams(env *pluginruntime.Env) (json.RawMessage, error) {
+	params, err := json.Marshal(externalQuery{
+		ProtocolVersion: ExternalProtocolVersion,
+		PluginID:        env.PluginID,
+		DataDir:         env.DataDir,
+		PluginDataDir:   env.PluginDataDir,
+		CredentialsPath: env.CredentialsPathOr(""),
+		Account:         env.Account,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode query: %w", err)
+	}
+	return params, nil
+}
+
+type externalResult struct {
+	Plan   string       `json:"plan"`
+	Lines  []MetricLine `json:"lines"`
+	Source string       `json:"source"`
+}
+
+// externalError is the optional classification in a failed answer's data.
+type externalError struct {
+	Code      ErrorCode `json:"code"`
+	Hint      string    `json:"hint"`
+	Retryable *bool     `json:"retryable"`
+}
+
+// pluginError classifies message when code is known, otherwise returns a
+// plain error.
+func (e externalError) pluginError(message string) error {
+	if !e.Code.Valid() {
+		return errors.New(message)
+	}
+	err := NewPluginError(e.Code, message, e.Hint)
+	if e.Retryable != nil {
+		err.Retryable = *e.Retryable
+	}
+	return err
+}
+
+// converse sends the query and serves host calls until the plugin answers.
+func (p *ExternalPlugin) converse(ctx context.Context, env *pluginruntime.Env, w io.Writer, r io.Reader) (QueryResult, error) {
+	host := &pluginruntime.Host{Env: env, Permissions: p.permissions}
+	enc := json.NewEncoder(w)
+
+	params, err := ExternalQueryParams(env)
+	if err != nil {
+		return QueryResult{}, err
+	}
+	if err := enc.Encode(rpcMessage{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "query", Params: params}); err != nil {
+		return QueryResult{}, fmt.Errorf("send query: %w", err)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), ExternalMessageLimit)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return QueryResult{}, fmt.Errorf("invalid message from plugin: %w", err)
+		}
+
+		if msg.Method != "" {
+			value, callErr := host.Call(ctx, msg.Method, msg.Params)
+			if len(msg.ID) == 0 {
+				continue
+			}
+			if err := enc.Encode(rpcResponse(msg.ID, value, callErr)); err != nil {
+				return QueryResult{}, fmt.Errorf("reply to plugin: %w", err)
+			}
+			continue
+		}
+		if string(bytes.TrimSpace(msg.ID)) != "1" {
+			continue
+		}
+		return decodeAnswer(msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return QueryResult{}, fmt.Errorf("read plugin output: %w", err)
+	}
+	return QueryResult{}, errNoAnswer
+}
+
+func rpcResponse(id json.RawMessage, value any, err error) rpcMessage {
+	resp := rpcMessage{JSONRPC: "2.0", ID: id}
+	if err == nil {
+		if resp.Result, err = json.Marshal(value); err == nil {
+			return resp
+		}
+	}
+	code := rpcServerError
+	switch {
+	case errors.Is(err, pluginruntime.ErrUnknownHostMethod):
+		code = rpcMethodNotFound
+	case errors.Is(err, pluginruntime.ErrPermissionDenied):
+		code = rpcPermissionDenied
+	}
+	resp.Result = nil
+	resp.Error = &rpcError{Code: code, Message: err.Error()}
+	return resp
+}
+
+// EncodeHostResponse returns the JSON-RPC response, without an ID, to a host
+// call that returned value and err.
+func EncodeHostResponse(value any, err error) []byte {
+	data, marshalErr := json.Marshal(rpcResponse(nil, value, err))
+	if marshalErr != nil {
+		data, _ = json.Marshal(rpcResponse(nil, nil, marshalErr))
+	}
+	return data
+}
+
+// DecodeAnswer decodes a plugin's JSON-RPC answer to the query request.
+func DecodeAnswer(data []byte) (QueryResult, error) {
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return QueryResult{}, fmt.Errorf("invalid message from plugin: %w", err)
+	}
+	return decodeAnswer(msg)
+}
+
+func decodeAnswer(msg rpcMessage) (QueryResult, error) {
+	var payload externalResult
+	if msg.Error != nil {
+		var classified externalError
+		if len(msg.Error.Data) > 0 {
+			_ = json.Unmarshal(msg.Error.Data, &payload)
+			_ = json.Unmarshal(msg.Error.Data, &classified)
+		}
+		message := msg.Error.Message
+		if message == "" {
+			message = "plugin failed"
+		}
+		return QueryResult{Plan: payload.Plan, Lines: payload.Lines, Source: payload.Source}, classified.pluginError(message)
+	}
+	if err := json.Unmarshal(msg.Result, &payload); err != nil {
+		return QueryResult{}, fmt.Errorf("invalid query result: %w", err)
+	}
+	return QueryResult{Plan: payload.Plan, Lines: payload.Lines, Source: payload.Source}, nil
+}
@@ -0,0 +1,199 @@
+package openusage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+const helperModeEnv = "GOPENUSAGE_EXTERNAL_HELPER"
+
+// TestExternalPluginHelperProcess is the external plugin run by the tests
+// below; it does nothing unless started by one of them.
+func TestExternalPluginHelperProcess(t *testing.T) {
+	mode := os.Getenv(helperModeEnv)
+	if mode == "" {
+		return
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	out := json.NewEncoder(os.Stdout)
+	read := func() map[string]any {
+		line, err := in.ReadBytes('\n')
+		if err != nil {
+			os.Exit(10)
+		}
+		var msg map[string]any
+		_ = json.Unmarshal(line, &msg)
+		return msg
+	}
+	call := func(id int, method string, params any) map[string]any {
+		_ = out.Encode(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+		return read()
+	}
+
+	query := read()
+	params, _ := query["params"].(map[string]any)
+
+	switch mode {
+	case "ok":
+		fmt.Fprintln(os.Stderr, "helper started")
+		_ = out.Encode(map[string]any{"jsonrpc": "2.0", "method": "log", "params": map[string]any{"message": "hello"}})
+		call(1, "state.write", map[string]any{"name": "seen.txt", "text": "yes"})
+		resp := call(2, "http.request", map[string]any{"url": os.Getenv("HELPER_URL")})
+		body, _ := resp["result"].(map[string]any)["bodyText"].(string)
+		denied := call(3, "http.request", map[string]any{"url": "https://example.com/"})
+		code := denied["error"].(map[string]any)["code"]
+		_ = out.Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": map[string]any{
+			"plan":   "Pro",
+			"source": params["pluginDataDir"],
+			"lines": []any{
+				map[string]any{"type": "text", "label": "Body", "value": body},
+				map[string]any{"type": "text", "label": "Denied", "value": fmt.Sprint(code)},
+			},
+		}})
+	case "fail":
+		_ = out.Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "error": map[string]any{
+			"code": 1, "message": "not logged in", "data": map[string]any{"source": "/tmp/creds"},
+		}})
+	case "crash":
+		fmt.Fprintln(os.Stderr, "boom: something broke")
+		os.Exit(3)
+	case "hang":
+		time.Sleep(time.Minute)
+	}
+	os.Exit(0)
+}
+
+// writeExternalPlugin writes a manifest whose entry re-runs the test binary
+// as TestExternalPluginHelperProcess in the given mode.
+func writeExternalPlugin(t *testing.T, pluginsDir, id, mode, url string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("external plugin tests use a shell script entry")
+	}
+
+	pluginDir := filepath.Join(pluginsDir, id)
+	if err := os.MkdirAll(pluginDir, 0o755); err != nil {
+		t.Fatalf("mkdir plugin dir: %v", err)
+	}
+	manifest := `{"id":"` + id + `","name":"External","entry":"plugin.sh","permissions":{"http":["127.0.0.1"]}}`
+	if err := os.WriteFile(filepath.Join(pluginDir, "plugin.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	script := fmt.Sprintf("#!/bin/sh\n%s=%s HELPER_URL=%s exec %q -test.run='^TestExternalPluginHelperProcess$'\n",
+		helperModeEnv, mode, url, os.Args[0])
+	if err := os.WriteFile(filepath.Join(pluginDir, "plugin.sh"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write entry: %v", err)
+	}
+}
+
+func TestExternalPluginServesHostCalls(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("pong"))
+	}))
+	defer server.Close()
+
+	pluginsDir := t.TempDir()
+	writeExternalPlugin(t, pluginsDir, "ext", "ok", server.URL)
+
+	dataDir := t.TempDir()
+	manager, err := NewManager(Options{PluginsDir: pluginsDir, DataDir: dataDir}, nil)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	if !manager.HasImplementation("ext") {
+		t.Fatal("expected external plugin to be registered")
+	}
+
+	out, err := manager.QueryOne(context.Background(), "ext")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	if out.Error != "" {
+		t.Fatalf("unexpected error: %s", out.Error)
+	}
+	if out.DisplayName != "External" || out.Plan != "Pro" {
+		t.Fatalf("unexpected output: %+v", out)
+	}
+	if len(out.Lines) != 2 || *out.Lines[0].Value != "pong" {
+		t.Fatalf("unexpected lines: %+v", out.Lines)
+	}
+	if got := *out.Lines[1].Value; got != "-32001" {
+		t.Fatalf("expected permission denied code, got %s", got)
+	}
+
+	stateDir := filepath.Join(dataDir, "plugins_data", "ext")
+	if out.Source != stateDir {
+		t.Fatalf("unexpected source: %s", out.Source)
+	}
+	if text, err := pluginruntime.ReadText(filepath.Join(stateDir, "seen.txt")); err != nil || text != "yes" {
+		t.Fatalf("unexpected state file: %q, %v", text, err)
+	}
+}
+
+func TestExternalPluginFailures(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		mode      string
+		wantError string
+	}{
+		{mode: "fail", wantError: "not logged in"},
+		{mode: "crash", wantError: "exit status 3: boom: something broke"},
+		{mode: "hang", wantError: "Plugin timed out after 500ms"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.mode, func(t *testing.T) {
+			t.Parallel()
+
+			pluginsDir := t.TempDir()
+			writeExternalPlugin(t, pluginsDir, "ext", tc.mode, "")
+
+			manager, err := NewManager(Options{
+				PluginsDir:    pluginsDir,
+				DataDir:       t.TempDir(),
+				PluginTimeout: 500 * time.Millisecond,
+			}, nil)
+			if err != nil {
+				t.Fatalf("NewManager error: %v", err)
+			}
+
+			out, err := manager.QueryOne(context.Background(), "ext")
+			if err != nil {
+				t.Fatalf("QueryOne error: %v", err)
+			}
+			if !strings.Contains(out.Error, tc.wantError) {
+				t.Fatalf("expected error containing %q, got %q", tc.wantError, out.Error)
+			}
+			if tc.mode == "fail" && out.Source != "/tmp/creds" {
+				t.Fatalf("expected source from error data, got %q", out.Source)
+			}
+		})
+	}
+}
+
+func TestNewExternalPluginRejectsEntryOutsidePluginDir(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewExternalPlugin(LoadedManifest{
+		Manifest:  PluginManifest{ID: "ext", Entry: "../escape.sh"},
+		PluginDir: t.TempDir(),
+	})
+	if err == nil || !strings.Contains(err.Error(), "outside the plugin dir") {
+		t.Fatalf("expected entry outside plugin dir error, got %v", err)
+	}
+}
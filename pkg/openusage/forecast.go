@@ -0,0 +1,155 @@
+package openusage
+
+import (
+	"time"
+)
+
+const (
+	PaceAhead   = "ahead"
+	PaceOnTrack = "on-track"
+	PaceBehind  = "behind"
+)
+
+const (
+	ForecastBasisPeriod  = "period"
+	ForecastBasisHistory = "history"
+)
+
+const (
+	// paceTolerance is the share of the limit that usage may deviate from the
+	// expected value and still count as on track.
+	paceTolerance = 0.1
+	// burnRateLookback bounds how far back history is used for the burn rate.
+	burnRateLookback = 24 * time.Hour
+	// minBurnRateSpan is the shortest history span trusted for a burn rate.
+	minBurnRateSpan = 15 * time.Minute
+)
+
+// Forecast describes how a progress line is tracking against its period.
+type Forecast struct {
+	// Pace is ahead (using less than expected), on-track or behind.
+	Pace string `json:"pace"`
+	// ExpectedUsed is the usage expected at this point of the period if the
+	// limit were consumed evenly.
+	ExpectedUsed float64 `json:"expectedUsed"`
+	// ElapsedFraction is the share of the period that has passed (0..1).
+	ElapsedFraction float64 `json:"elapsedFraction"`
+	// BurnRatePerHour is the current consumption rate in line units per hour.
+	BurnRatePerHour float64 `json:"burnRatePerHour"`
+	// ExhaustsAt is the projected time the limit is reached, when that happens
+	// before the period resets.
+	ExhaustsAt *string `json:"exhaustsAt,omitempty"`
+	// Basis says whether the burn rate came from recorded history or from the
+	// period average.
+	Basis string `json:"basis"`
+}
+
+// UsageSample is one recorded observation of a progress line.
+type UsageSample struct {
+	Time time.Time
+	Used float64
+}
+
+// UsageHistory supplies recorded samples for burn-rate forecasting.
+type UsageHistory interface {
+	// UsageSamples returns the samples recorded for providerID since since,
+	// keyed by line label.
+	UsageSamples(providerID string, since time.Time) (map[string][]UsageSample, error)
+}
+
+// ComputeForecast derives a forecast for a progress line that carries a
+// limit, a reset time and a period duration. samples are optional earlier
+// observations from the same period. It returns nil when the line lacks the
+// data or its period has already ended.
+func ComputeForecast(line MetricLine, now time.Time, samples []UsageSample) *Forecast {
+	if line.Type != LineTypeProgress || line.Used == nil || line.Limit == nil || *line.Limit <= 0 {
+		return nil
+	}
+	if line.ResetsAt == nil || line.PeriodDurationMs == nil || *line.PeriodDurationMs <= 0 {
+		return nil
+	}
+	resetsAt, err := time.Parse(time.RFC3339Nano, *line.ResetsAt)
+	if err != nil || !resetsAt.After(now) {
+		return nil
+	}
+
+	used := *line.Used
+	limit := *line.Limit
+	period := time.Duration(*line.PeriodDurationMs) * time.Millisecond
+	periodStart := resetsAt.Add(-period)
+
+	elapsed := Clamp(float64(now.Sub(periodStart))/float64(period), 0, 1)
+	expected := limit * elapsed
+
+	pace := PaceOnTrack
+	switch {
+	case used < expected-limit*paceTolerance:
+		pace = PaceAhead
+	case used > expected+limit*paceTolerance:
+		pace = PaceBehind
+	}
+
+	forecast := &Forecast{
+		Pace:            pace,
+		ExpectedUsed:    RoundTo(expected, 2),
+		ElapsedFraction: RoundTo(elapsed, 4),
+		Basis:           ForecastBasisPeriod,
+	}
+
+	rate, ok := historyBurnRate(samples, periodStart, now, used)
+	if ok {
+		forecast.Basis = ForecastBasisHistory
+	} else if elapsedHours := now.Sub(periodStart).Hours(); elapsedHours > 0 {
+		rate = used / elapsedHours
+	}
+	if rate < 0 {
+		rate = 0
+	}
+	forecast.BurnRatePerHour = RoundTo(rate, 4)
+
+	switch {
+	case used >= limit:
+		forecast.ExhaustsAt = Ptr(formatForecastTime(now))
+	case rate > 0:
+		exhaustsAt := now.Add(time.Duration((limit - used) / rate * float64(time.Hour)))
+		if exhaustsAt.Before(resetsAt) {
+			forecast.ExhaustsAt = Ptr(formatForecastTime(exhaustsAt))
+		}
+	}
+
+	return forecast
+}
+
+// historyBurnRate computes units per hour between the oldest usable sample
+// and the current value. Samples from before the current period are ignored
+// so a reset does not look like negative consumption.
+func historyBurnRate(samples []UsageSample, periodStart, now time.Time, used float64) (float64, bool) {
+	since := now.Add(-burnRateLookback)
+	if periodStart.After(since) {
+		since = periodStart
+	}
+
+	var oldest *UsageSample
+	for i := range samples {
+		sample := samples[i]
+		if sample.Time.Before(since) || !sample.Time.Before(now) {
+			continue
+		}
+		if oldest == nil || sample.Time.Before(oldest.Time) {
+			oldest = &samples[i]
+		}
+	}
+	if oldest == nil {
+		return 0, false
+	}
+
+	span := now.Sub(oldest.Time)
+	if span < minBurnRateSpan {
+		return 0, false
+	}
+	return (used - oldest.Used) / span.Hours(), true
+}
+
+func formatForecastTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
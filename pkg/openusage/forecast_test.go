@@ -0,0 +1,158 @@
+package openusage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+func TestComputeForecastPace(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)
+	thirtyDaysMs := int64((30 * 24 * time.Hour) / time.Millisecond)
+	resetsAt := now.Add(15 * 24 * time.Hour).Format(time.RFC3339)
+
+	cases := []struct {
+		used float64
+		want string
+	}{
+		{used: 30, want: PaceAhead},
+		{used: 45, want: PaceOnTrack},
+		{used: 65, want: PaceBehind},
+	}
+
+	for _, tc := range cases {
+		line := NewProgressLine("Usage", tc.used, 100, PercentFormat(), ProgressLineOptions{ResetsAt: resetsAt, PeriodDurationMs: thirtyDaysMs})
+		forecast := ComputeForecast(line, now, nil)
+		if forecast == nil {
+			t.Fatalf("expected forecast for used=%v", tc.used)
+		}
+		if forecast.Pace != tc.want {
+			t.Fatalf("used=%v: got pace %s want %s", tc.used, forecast.Pace, tc.want)
+		}
+		if forecast.ExpectedUsed != 50 || forecast.ElapsedFraction != 0.5 {
+			t.Fatalf("unexpected expectation: %+v", forecast)
+		}
+		if forecast.Basis != ForecastBasisPeriod {
+			t.Fatalf("unexpected basis: %s", forecast.Basis)
+		}
+	}
+}
+
+func TestComputeForecastProjectsExhaustion(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	fiveHoursMs := int64((5 * time.Hour) / time.Millisecond)
+	// One hour into a five hour window at 40%: 40%/h runs out after 1.5h more.
+	line := NewProgressLine("Session", 40, 100, PercentFormat(), ProgressLineOptions{
+		ResetsAt:         now.Add(4 * time.Hour).Format(time.RFC3339),
+		PeriodDurationMs: fiveHoursMs,
+	})
+
+	forecast := ComputeForecast(line, now, nil)
+	if forecast == nil || forecast.ExhaustsAt == nil {
+		t.Fatalf("expected exhaustion forecast, got %+v", forecast)
+	}
+	if forecast.BurnRatePerHour != 40 {
+		t.Fatalf("unexpected burn rate: %v", forecast.BurnRatePerHour)
+	}
+	if want := "2026-03-01T13:30:00.000Z"; *forecast.ExhaustsAt != want {
+		t.Fatalf("unexpected exhaustion time: got %s want %s", *forecast.ExhaustsAt, want)
+	}
+
+	// Using history: the sample from before the period is ignored, and 5%
+	// burned over the last half hour is 10%/h, so the limit outlasts the
+	// window.
+	samples := []UsageSample{
+		{Time: now.Add(-30 * time.Hour), Used: 0},
+		{Time: now.Add(-30 * time.Minute), Used: 35},
+	}
+	forecast = ComputeForecast(line, now, samples)
+	if forecast.Basis != ForecastBasisHistory {
+		t.Fatalf("expected history basis, got %s", forecast.Basis)
+	}
+	if forecast.BurnRatePerHour != 10 {
+		t.Fatalf("unexpected history burn rate: %v", forecast.BurnRatePerHour)
+	}
+	if forecast.ExhaustsAt != nil {
+		t.Fatalf("did not expect exhaustion before reset, got %s", *forecast.ExhaustsAt)
+	}
+}
+
+func TestComputeForecastRequiresPeriodData(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	if f := ComputeForecast(NewProgressLine("No reset", 1, 10, CountFormat("x"), ProgressLineOptions{}), now, nil); f != nil {
+		t.Fatalf("expected nil forecast without period data, got %+v", f)
+	}
+
+	expired := NewProgressLine("Expired", 42, 100, PercentFormat(), ProgressLineOptions{
+		ResetsAt:         now.Add(-time.Minute).UTC().Format(time.RFC3339),
+		PeriodDurationMs: int64((30 * 24 * time.Hour) / time.Millisecond),
+	})
+	if f := ComputeForecast(expired, now, nil); f != nil {
+		t.Fatalf("expected nil forecast after reset, got %+v", f)
+	}
+
+	if f := ComputeForecast(NewTextLine("Status", "ok", TextLineOptions{}), now, nil); f != nil {
+		t.Fatalf("expected nil forecast for text line, got %+v", f)
+	}
+}
+
+type countingHistory struct {
+	calls   int
+	since   time.Time
+	samples map[string][]UsageSample
+}
+
+func (h *countingHistory) UsageSamples(_ string, since time.Time) (map[string][]UsageSample, error) {
+	h.calls++
+	h.since = since
+	return h.samples, nil
+}
+
+func TestManagerLoadsHistoryOncePerQuery(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	history := &countingHistory{samples: map[string][]UsageSample{
+		"Session": {{Time: now.Add(-30 * time.Minute), Used: 35}},
+	}}
+	manager, err := NewManager(Options{DataDir: t.TempDir(), History: history}, []Plugin{stubPlugin{
+		id: "alpha",
+		fn: func(context.Context, *pluginruntime.Env) (QueryResult, error) {
+			return QueryResult{Lines: []MetricLine{
+				NewProgressLine("Session", 40, 100, PercentFormat(), ProgressLineOptions{
+					ResetsAt:         now.Add(4 * time.Hour).Format(time.RFC3339),
+					PeriodDurationMs: (5 * time.Hour).Milliseconds(),
+				}),
+				NewProgressLine("Weekly", 10, 100, PercentFormat(), ProgressLineOptions{
+					ResetsAt:         now.Add(72 * time.Hour).Format(time.RFC3339),
+					PeriodDurationMs: (7 * 24 * time.Hour).Milliseconds(),
+				}),
+			}}, nil
+		},
+	}})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	out, err := manager.QueryOne(context.Background(), "alpha")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	if history.calls != 1 {
+		t.Fatalf("expected one history lookup, got %d", history.calls)
+	}
+	if week := now.Add(-7 * 24 * time.Hour); history.since.After(week.Add(time.Minute)) {
+		t.Fatalf("expected history back to the weekly period, got %s", history.since)
+	}
+	if len(out.Lines) != 2 || out.Lines[0].Forecast.Basis != ForecastBasisHistory || out.Lines[1].Forecast.Basis != ForecastBasisPeriod {
+		t.Fatalf("unexpected forecasts: %+v", out.Lines)
+	}
+}
@@ -0,0 +1,481 @@
+// Package history persists progress-line snapshots so usage can be queried as
+// a time series. Samples are appended to one JSON-lines segment per UTC day
+// under the store directory; old segments are downsampled and eventually
+// removed according to the retention settings.
+package history
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+)
+
+const (
+	DefaultRetention    = 90 * 24 * time.Hour
+	DefaultCompactAfter = 7 * 24 * time.Hour
+	DefaultCompactStep  = 15 * time.Minute
+
+	segmentDateLayout = "2006-01-02"
+	rawSuffix         = ".jsonl"
+	compactedSuffix   = ".c.jsonl"
+)
+
+type Options struct {
+	// Retention is how long samples are kept. Zero uses DefaultRetention.
+	Retention time.Duration
+	// CompactAfter is the age after which a day segment is downsampled.
+	// Zero uses DefaultCompactAfter.
+	CompactAfter time.Duration
+	// CompactStep is the resolution compacted segments are reduced to.
+	// Zero uses DefaultCompactStep.
+	CompactStep time.Duration
+}
+
+// Sample is one recorded progress line.
+type Sample struct {
+	Time       time.Time `json:"time"`
+	ProviderID string    `json:"providerId"`
+	Label      string    `json:"label"`
+	Used       float64   `json:"used"`
+	Limit      float64   `json:"limit"`
+	Format     string    `json:"format,omitempty"`
+	ResetsAt   string    `json:"resetsAt,omitempty"`
+}
+
+// Point and Series are the wire types Query returns; they live in openusage
+// so API clients need not import the store.
+type Point = openusage.HistoryPoint
+
+type Series = openusage.HistorySeries
+
+type Query struct {
+	// ProviderIDs restricts the result to these providers. Empty means all.
+	ProviderIDs []string
+	// Label restricts the result to one line label. Empty means all.
+	Label string
+	From  time.Time
+	To    time.Time
+	// Step buckets points; the last sample in each bucket is returned.
+	// Zero returns every recorded sample.
+	Step time.Duration
+}
+
+type Store struct {
+	dir          string
+	retention    time.Duration
+	compactAfter time.Duration
+	compactStep  time.Duration
+	now          func() time.Time
+
+	mu sync.Mutex
+}
+
+// Open creates (if needed) and opens a history store rooted at dir.
+func Open(dir string, opts Options) (*Store, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("history dir is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create history dir: %w", err)
+	}
+
+	retention := opts.Retention
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	compactAfter := opts.CompactAfter
+	if compactAfter <= 0 {
+		compactAfter = DefaultCompactAfter
+	}
+	compactStep := opts.CompactStep
+	if compactStep <= 0 {
+		compactStep = DefaultCompactStep
+	}
+
+	return &Store{
+		dir:          dir,
+		retention:    retention,
+		compactAfter: compactAfter,
+		compactStep:  compactStep,
+		now:          time.Now,
+	}, nil
+}
+
+// Record appends every progress line of output as a sample. Outputs served
+// from cache are ignored since they were recorded when first fetched.
+func (s *Store) Record(output openusage.PluginOutput) error {
+	if output.Cached {
+		return nil
+	}
+
+	at := s.now()
+	if fetchedAt, err := time.Parse(time.RFC3339Nano, output.FetchedAt); err == nil {
+		at = fetchedAt
+	}
+
+	samples := make([]Sample, 0, len(output.Lines))
+	for _, line := range output.Lines {
+		if line.Type != openusage.LineTypeProgress || line.Used == nil || line.Limit == nil {
+			continue
+		}
+		sample := Sample{
+			Time:       at.UTC(),
+			ProviderID: output.ProviderID,
+			Label:      line.Label,
+			Used:       *line.Used,
+			Limit:      *line.Limit,
+		}
+		if line.Format != nil {
+			sample.Format = line.Format.Kind
+		}
+		if line.ResetsAt != nil {
+			sample.ResetsAt = *line.ResetsAt
+		}
+		samples = append(samples, sample)
+	}
+	return s.Append(samples)
+}
+
+// Append writes samples to the segments for their days.
+func (s *Store) Append(samples []Sample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	byDay := make(map[string][]Sample)
+	for _, sample := range samples {
+		day := sample.Time.UTC().Format(segmentDateLayout)
+		byDay[day] = append(byDay[day], sample)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for day, daySamples := range byDay {
+		if err := s.appendSegment(day, daySamples); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) appendSegment(day string, samples []Sample) error {
+	path := filepath.Join(s.dir, day+rawSuffix)
+	if _, err := os.Stat(filepath.Join(s.dir, day+compactedSuffix)); err == nil {
+		path = filepath.Join(s.dir, day+compactedSuffix)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open history segment: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, sample := range samples {
+		if err := enc.Encode(sample); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("encode history sample: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write history segment: %w", err)
+	}
+	return f.Close()
+}
+
+// Query returns the matching series, sorted by provider and label.
+func (s *Store) Query(q Query) ([]Series, error) {
+	to := q.To
+	if to.IsZero() {
+		to = s.now()
+	}
+	from := q.From
+	if from.IsZero() {
+		from = to.Add(-24 * time.Hour)
+	}
+	if from.After(to) {
+		return nil, fmt.Errorf("history range start is after its end")
+	}
+
+	providers := make(map[string]bool, len(q.ProviderIDs))
+	for _, id := range q.ProviderIDs {
+		providers[id] = true
+	}
+
+	s.mu.Lock()
+	segments, err := s.segments()
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	type seriesKey struct{ provider, label string }
+	grouped := make(map[seriesKey]*Series)
+	firstDay := from.UTC().Truncate(24 * time.Hour)
+	for _, seg := range segments {
+		if seg.day.Before(firstDay) || seg.day.After(to) {
+			continue
+		}
+		err := readSegment(seg.path, func(sample Sample) {
+			if sample.Time.Before(from) || sample.Time.After(to) {
+				return
+			}
+			if len(providers) > 0 && !providers[sample.ProviderID] {
+				return
+			}
+			if q.Label != "" && sample.Label != q.Label {
+				return
+			}
+			key := seriesKey{provider: sample.ProviderID, label: sample.Label}
+			series, ok := grouped[key]
+			if !ok {
+				series = &Series{ProviderID: sample.ProviderID, Label: sample.Label}
+				grouped[key] = series
+			}
+			if sample.Format != "" {
+				series.Format = sample.Format
+			}
+			series.Points = append(series.Points, Point{
+				Time:     sample.Time,
+				Used:     sample.Used,
+				Limit:    sample.Limit,
+				ResetsAt: sample.ResetsAt,
+			})
+		})
+		if err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+	s.mu.Unlock()
+
+	out := make([]Series, 0, len(grouped))
+	for _, series := range grouped {
+		sort.SliceStable(series.Points, func(i, j int) bool {
+			return series.Points[i].Time.Before(series.Points[j].Time)
+		})
+		if q.Step > 0 {
+			series.Points = downsample(series.Points, q.Step)
+		}
+		out = append(out, *series)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ProviderID != out[j].ProviderID {
+			return out[i].ProviderID < out[j].ProviderID
+		}
+		return out[i].Label < out[j].Label
+	})
+	return out, nil
+}
+
+// UsageSamples implements openusage.UsageHistory.
+func (s *Store) UsageSamples(providerID string, since time.Time) (map[string][]openusage.UsageSample, error) {
+	series, err := s.Query(Query{
+		ProviderIDs: []string{providerID},
+		From:        since,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make(map[string][]openusage.UsageSample, len(series))
+	for _, ser := range series {
+		for _, point := range ser.Points {
+			samples[ser.Label] = append(samples[ser.Label], openusage.UsageSample{Time: point.Time, Used: point.Used})
+		}
+	}
+	return samples, nil
+}
+
+// Compact removes segments past retention and downsamples segments older
+// than CompactAfter to CompactStep resolution.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segments, err := s.segments()
+	if err != nil {
+		return err
+	}
+
+	now := s.now().UTC()
+	dropBefore := now.Add(-s.retention).Truncate(24 * time.Hour)
+	compactBefore := now.Add(-s.compactAfter).Truncate(24 * time.Hour)
+
+	for _, seg := range segments {
+		switch {
+		case seg.day.Before(dropBefore):
+			if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove history segment: %w", err)
+			}
+		case seg.day.Before(compactBefore) && !seg.compacted:
+			if err := s.compactSegment(seg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Maintain runs Compact every interval until ctx is done.
+func (s *Store) Maintain(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Compact(); err != nil {
+			log.Printf("history: compact: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Store) compactSegment(seg segment) error {
+	type seriesKey struct{ provider, label string }
+	grouped := make(map[seriesKey][]Sample)
+	order := make([]seriesKey, 0)
+	err := readSegment(seg.path, func(sample Sample) {
+		key := seriesKey{provider: sample.ProviderID, label: sample.Label}
+		if _, ok := grouped[key]; !ok {
+			order = append(order, key)
+		}
+		grouped[key] = append(grouped[key], sample)
+	})
+	if err != nil {
+		return err
+	}
+
+	compacted := make([]Sample, 0)
+	for _, key := range order {
+		samples := grouped[key]
+		sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+		for i, sample := range samples {
+			last := i == len(samples)-1
+			if last || !samples[i+1].Time.Truncate(s.compactStep).Equal(sample.Time.Truncate(s.compactStep)) {
+				compacted = append(compacted, sample)
+			}
+		}
+	}
+
+	target := filepath.Join(s.dir, seg.day.Format(segmentDateLayout)+compactedSuffix)
+	tmp, err := os.CreateTemp(s.dir, ".compact-*")
+	if err != nil {
+		return fmt.Errorf("create compacted segment: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, sample := range compacted {
+		if err := enc.Encode(sample); err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+			return fmt.Errorf("encode compacted sample: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write compacted segment: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("close compacted segment: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("replace compacted segment: %w", err)
+	}
+	if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove raw segment: %w", err)
+	}
+	return nil
+}
+
+type segment struct {
+	day       time.Time
+	path      string
+	compacted bool
+}
+
+func (s *Store) segments() ([]segment, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read history dir: %w", err)
+	}
+
+	out := make([]segment, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, rawSuffix) || len(name) < len(segmentDateLayout) {
+			continue
+		}
+		day, err := time.Parse(segmentDateLayout, name[:len(segmentDateLayout)])
+		if err != nil {
+			continue
+		}
+		out = append(out, segment{
+			day:       day,
+			path:      filepath.Join(s.dir, name),
+			compacted: strings.HasSuffix(name, compactedSuffix),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].day.Before(out[j].day) })
+	return out, nil
+}
+
+func readSegment(path string, fn func(Sample)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("open history segment: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			log.Printf("history: close segment: %v", closeErr)
+		}
+	}()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var sample Sample
+		// A crash can leave a torn final line; skip anything unparseable.
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+			continue
+		}
+		fn(sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read history segment: %w", err)
+	}
+	return nil
+}
+
+// downsample keeps the last point of each step-sized bucket.
+func downsample(points []Point, step time.Duration) []Point {
+	out := make([]Point, 0, len(points))
+	for i, point := range points {
+		last := i == len(points)-1
+		if last || !points[i+1].Time.Truncate(step).Equal(point.Time.Truncate(step)) {
+			out = append(out, point)
+		}
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+)
+
+func TestRecordAndQuery(t *testing.T) {
+	t.Parallel()
+
+	store, err := Open(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		output := openusage.PluginOutput{
+			ProviderID: "claude",
+			FetchedAt:  base.Add(time.Duration(i) * 10 * time.Minute).Format("2006-01-02T15:04:05.000Z"),
+			Lines: []openusage.MetricLine{
+				openusage.NewProgressLine("Weekly", float64(10+i), 100, openusage.PercentFormat(), openusage.ProgressLineOptions{}),
+				openusage.NewTextLine("Status", "ok", openusage.TextLineOptions{}),
+			},
+		}
+		if err := store.Record(output); err != nil {
+			t.Fatalf("Record error: %v", err)
+		}
+	}
+	if err := store.Record(openusage.PluginOutput{
+		ProviderID: "claude",
+		Cached:     true,
+		Lines:      []openusage.MetricLine{openusage.NewProgressLine("Weekly", 99, 100, openusage.PercentFormat(), openusage.ProgressLineOptions{})},
+	}); err != nil {
+		t.Fatalf("Record cached error: %v", err)
+	}
+
+	series, err := store.Query(Query{From: base.Add(-time.Hour), To: base.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if len(series) != 1 {
+		t.Fatalf("expected one series, got %d", len(series))
+	}
+	if series[0].Label != "Weekly" || series[0].Format != openusage.FormatKindPercent {
+		t.Fatalf("unexpected series: %+v", series[0])
+	}
+	if len(series[0].Points) != 4 {
+		t.Fatalf("expected 4 raw points, got %d", len(series[0].Points))
+	}
+
+	stepped, err := store.Query(Query{From: base.Add(-time.Hour), To: base.Add(time.Hour), Step: 30 * time.Minute})
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	points := stepped[0].Points
+	if len(points) != 2 || points[0].Used != 12 || points[1].Used != 13 {
+		t.Fatalf("unexpected stepped points: %+v", points)
+	}
+
+	filtered, err := store.Query(Query{ProviderIDs: []string{"codex"}, From: base.Add(-time.Hour), To: base.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if len(filtered) != 0 {
+		t.Fatalf("expected provider filter to exclude claude, got %+v", filtered)
+	}
+}
+
+func TestCompactDownsamplesAndDropsExpiredSegments(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	store, err := Open(dir, Options{
+		Retention:    30 * 24 * time.Hour,
+		CompactAfter: 7 * 24 * time.Hour,
+		CompactStep:  time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	expired := now.Add(-40 * 24 * time.Hour)
+	old := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Hour)
+
+	samples := []Sample{{Time: expired, ProviderID: "claude", Label: "Weekly", Used: 1, Limit: 100}}
+	for i := 0; i < 12; i++ {
+		samples = append(samples, Sample{Time: old.Add(time.Duration(i) * 10 * time.Minute), ProviderID: "claude", Label: "Weekly", Used: float64(i), Limit: 100})
+	}
+	samples = append(samples, Sample{Time: recent, ProviderID: "claude", Label: "Weekly", Used: 50, Limit: 100})
+	if err := store.Append(samples); err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+
+	if err := store.Compact(); err != nil {
+		t.Fatalf("Compact error: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, expired.Format(segmentDateLayout)+rawSuffix)); !os.IsNotExist(err) {
+		t.Fatalf("expected expired segment to be removed, stat err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2026-03-10"+compactedSuffix)); err != nil {
+		t.Fatalf("expected compacted segment: %v", err)
+	}
+
+	series, err := store.Query(Query{From: old.Add(-time.Hour), To: old.Add(24 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if len(series) != 1 {
+		t.Fatalf("expected one series, got %d", len(series))
+	}
+	points := series[0].Points
+	if len(points) != 2 || points[0].Used != 5 || points[1].Used != 11 {
+		t.Fatalf("unexpected compacted points: %+v", points)
+	}
+
+	recentSeries, err := store.Query(Query{From: now.Add(-2 * time.Hour), To: now})
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if len(recentSeries) != 1 || len(recentSeries[0].Points) != 1 {
+		t.Fatalf("recent samples must be untouched: %+v", recentSeries)
+	}
+}
@@ -0,0 +1,426 @@
+package jsplugin
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+// jsHost backs the ctx object passed to a JavaScript plugin's probe.
+type jsHost struct {
+	ctx context.Context
+	vm  *goja.Runtime
+	env *pluginruntime.Env
+}
+
+type jsFunc = func(goja.FunctionCall) goja.Value
+
+// newJSContext builds the OpenUsage plugin context: ctx.host (log, fs, env,
+// keychain, sqlite, http, ls), ctx.line, ctx.fmt, ctx.util, ctx.base64,
+// ctx.jwt, ctx.app and ctx.nowIso.
+func newJSContext(h *jsHost) *goja.Object {
+	platform := runtime.GOOS
+	if platform == "darwin" {
+		platform = "macos"
+	}
+
+	return h.object(map[string]any{
+		"nowIso": time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+		"app": h.object(map[string]any{
+			"version":         buildVersion(),
+			"platform":        platform,
+			"appDataDir":      h.env.DataDir,
+			"pluginDataDir":   h.env.PluginDataDir,
+			"credentialsPath": h.env.CredentialsPathOr(""),
+			"account":         h.env.Account,
+		}),
+		"host": h.object(map[string]any{
+			"log": h.object(map[string]any{
+				"info":  h.logFunc("info"),
+				"warn":  h.logFunc("warn"),
+				"error": h.logFunc("error"),
+			}),
+			"fs": h.object(map[string]any{
+				"exists": func(path string) bool { return pluginruntime.FileExists(path) },
+				"readText": func(path string) string {
+					text, err := pluginruntime.ReadText(path)
+					h.check(err)
+					return text
+				},
+				"writeText": func(path, text string) { h.check(pluginruntime.WriteText(path, text)) },
+			}),
+			"env": h.object(map[string]any{
+				"get": func(name string) goja.Value {
+					if value, ok := os.LookupEnv(name); ok {
+						return h.vm.ToValue(value)
+					}
+					return goja.Null()
+				},
+			}),
+			"keychain": h.keychain(),
+			"sqlite": h.object(map[string]any{
+				"query": func(dbPath, sql string) string {
+					rows, err := pluginruntime.SQLiteQuery(dbPath, sql)
+					h.check(err)
+					return rows
+				},
+				"exec": func(dbPath, sql string) { h.check(pluginruntime.SQLiteExec(dbPath, sql)) },
+			}),
+			"http": h.object(map[string]any{"request": h.request}),
+			"ls":   h.object(map[string]any{"discover": h.discoverLS}),
+		}),
+		"line": h.object(map[string]any{
+			"text":     h.textLine,
+			"progress": h.progressLine,
+			"badge":    h.badgeLine,
+		}),
+		"fmt": h.object(map[string]any{
+			"planLabel": pluginruntime.PlanLabel,
+			"dollars":   pluginruntime.Dollars,
+			"resetIn":   formatResetIn,
+			"date": func(ms int64) string {
+				return time.UnixMilli(ms).Local().Format("Jan 2")
+			},
+		}),
+		"util": h.object(map[string]any{
+			"tryParseJson":         h.tryParseJSON,
+			"safeJsonParse":        h.safeJSONParse,
+			"request":              h.request,
+			"requestJson":          h.requestJSON,
+			"isAuthStatus":         pluginruntime.IsAuthStatus,
+			"retryOnceOnAuth":      h.retryOnceOnAuth,
+			"parseDateMs":          h.parseDateMs,
+			"toIso":                h.toISO,
+			"needsRefreshByExpiry": h.needsRefreshByExpiry,
+		}),
+		"base64": h.object(map[string]any{
+			"encode": func(text string) string { return base64.StdEncoding.EncodeToString([]byte(text)) },
+			"decode": func(text string) string {
+				decoded, err := pluginruntime.DecodeBase64(text)
+				h.check(err)
+				return decoded
+			},
+		}),
+		"jwt": h.object(map[string]any{
+			"decodePayload": func(token string) goja.Value {
+				payload, ok := pluginruntime.DecodeJWTPayload(token)
+				if !ok {
+					return goja.Null()
+				}
+				return h.value(payload)
+			},
+		}),
+	})
+}
+
+// errKeychainAccount is thrown by ctx.host.keychain for account instances,
+// which must only use their configured credentials.
+var errKeychainAccount = errors.New("keychain is not available to accounts")
+
+// keychain returns ctx.host.keychain, whose functions throw for account
+// instances.
+func (h *jsHost) keychain() *goja.Object {
+	if h.env.Account != "" {
+		return h.object(map[string]any{
+			"readGenericPassword":  func(string) string { h.check(errKeychainAccount); return "" },
+			"writeGenericPassword": func(string, string) { h.check(errKeychainAccount) },
+		})
+	}
+	return h.object(map[string]any{
+		"readGenericPassword": func(service string) string {
+			value, err := h.env.ReadKeychainGenericPassword(service)
+			h.check(err)
+			return value
+		},
+		"writeGenericPassword": func(service, value string) {
+			h.check(h.env.WriteKeychainGenericPassword(service, value))
+		},
+	})
+}
+
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+func (h *jsHost) object(props map[string]any) *goja.Object {
+	obj := h.vm.NewObject()
+	for name, value := range props {
+		_ = obj.Set(name, value)
+	}
+	return obj
+}
+
+// check throws err into the script, where plugins may catch it.
+func (h *jsHost) check(err error) {
+	if err != nil {
+		panic(h.vm.NewGoError(err))
+	}
+}
+
+// value converts v to plain JavaScript objects through JSON.
+func (h *jsHost) value(v any) goja.Value {
+	data, err := json.Marshal(v)
+	h.check(err)
+	parsed, err := h.parseJSON(string(data))
+	h.check(err)
+	return parsed
+}
+
+func (h *jsHost) parseJSON(text string) (goja.Value, error) {
+	parse, _ := goja.AssertFunction(h.vm.Get("JSON").ToObject(h.vm).Get("parse"))
+	return parse(goja.Undefined(), h.vm.ToValue(text))
+}
+
+// decode converts a script value to out through JSON.
+func (h *jsHost) decode(v goja.Value, out any) error {
+	if v == nil || goja.IsUndefined(v) {
+		return fmt.Errorf("value is undefined")
+	}
+	stringify, _ := goja.AssertFunction(h.vm.Get("JSON").ToObject(h.vm).Get("stringify"))
+	text, err := stringify(goja.Undefined(), v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(text.String()), out)
+}
+
+func (h *jsHost) mustDecode(v goja.Value, out any) {
+	if err := h.decode(v, out); err != nil {
+		h.check(fmt.Errorf("invalid argument: %w", err))
+	}
+}
+
+func (h *jsHost) logFunc(level string) jsFunc {
+	return func(call goja.FunctionCall) goja.Value {
+		if h.env.Logger == nil {
+			return goja.Undefined()
+		}
+		args := make([]any, 0, len(call.Arguments))
+		for _, arg := range call.Arguments {
+			args = append(args, arg.String())
+		}
+		message := fmt.Sprintln(args...)
+		if level == "info" {
+			h.env.Logger.Print(message)
+		} else {
+			h.env.Logger.Printf("%s: %s", level, message)
+		}
+		return goja.Undefined()
+	}
+}
+
+func (h *jsHost) request(call goja.FunctionCall) goja.Value {
+	var opts struct {
+		Method               string            `json:"method"`
+		URL                  string            `json:"url"`
+		Headers              map[string]string `json:"headers"`
+		BodyText             string            `json:"bodyText"`
+		TimeoutMs            int64             `json:"timeoutMs"`
+		DangerouslyIgnoreTLS bool              `json:"dangerouslyIgnoreTls"`
+	}
+	h.mustDecode(call.Argument(0), &opts)
+
+	resp, err := h.env.DoHTTPRequest(h.ctx, pluginruntime.HTTPRequest{
+		Method:               opts.Method,
+		URL:                  opts.URL,
+		Headers:              opts.Headers,
+		BodyText:             opts.BodyText,
+		Timeout:              time.Duration(opts.TimeoutMs) * time.Millisecond,
+		DangerouslyIgnoreTLS: opts.DangerouslyIgnoreTLS,
+	})
+	h.check(err)
+	return h.value(resp)
+}
+
+// requestJSON returns {resp, json}; json is null when the body is not JSON.
+func (h *jsHost) requestJSON(call goja.FunctionCall) goja.Value {
+	resp := h.request(call).ToObject(h.vm)
+	parsed, err := h.parseJSON(resp.Get("bodyText").String())
+	if err != nil {
+		parsed = goja.Null()
+	}
+	return h.object(map[string]any{"resp": resp, "json": parsed})
+}
+
+// retryOnceOnAuth calls opts.request(), and when the status is 401 or 403
+// calls opts.refresh() and retries once with the returned token.
+func (h *jsHost) retryOnceOnAuth(call goja.FunctionCall) goja.Value {
+	opts := call.Argument(0).ToObject(h.vm)
+	request, ok := goja.AssertFunction(opts.Get("request"))
+	if !ok {
+		h.check(fmt.Errorf("retryOnceOnAuth requires a request function"))
+	}
+	refresh, ok := goja.AssertFunction(opts.Get("refresh"))
+	if !ok {
+		h.check(fmt.Errorf("retryOnceOnAuth requires a refresh function"))
+	}
+
+	resp := h.call(request)
+	status := resp.ToObject(h.vm).Get("status")
+	if status == nil || !pluginruntime.IsAuthStatus(int(status.ToInteger())) {
+		return resp
+	}
+	token := h.call(refresh)
+	if goja.IsUndefined(token) || goja.IsNull(token) || token.String() == "" {
+		return resp
+	}
+	return h.call(request, token)
+}
+
+// call invokes a script callback, rethrowing its exception unchanged.
+func (h *jsHost) call(fn goja.Callable, args ...goja.Value) goja.Value {
+	value, err := fn(goja.Undefined(), args...)
+	if err != nil {
+		if exception, ok := err.(*goja.Exception); ok {
+			panic(exception)
+		}
+		h.check(err)
+	}
+	return value
+}
+
+func (h *jsHost) tryParseJSON(text string) goja.Value {
+	parsed, err := h.parseJSON(text)
+	if err != nil {
+		return goja.Null()
+	}
+	return parsed
+}
+
+func (h *jsHost) safeJSONParse(text string) goja.Value {
+	parsed, err := h.parseJSON(text)
+	if err != nil {
+		return h.object(map[string]any{"ok": false})
+	}
+	return h.object(map[string]any{"ok": true, "value": parsed})
+}
+
+func (h *jsHost) parseDateMs(value goja.Value) goja.Value {
+	if ms, ok := pluginruntime.ParseDateMs(value.Export()); ok {
+		return h.vm.ToValue(ms)
+	}
+	return goja.Null()
+}
+
+func (h *jsHost) toISO(value goja.Value) goja.Value {
+	if iso := pluginruntime.ToISO(value.Export()); iso != "" {
+		return h.vm.ToValue(iso)
+	}
+	return goja.Null()
+}
+
+func (h *jsHost) needsRefreshByExpiry(call goja.FunctionCall) goja.Value {
+	var opts struct {
+		NowMs       int64  `json:"nowMs"`
+		ExpiresAtMs *int64 `json:"expiresAtMs"`
+		BufferMs    int64  `json:"bufferMs"`
+	}
+	h.mustDecode(call.Argument(0), &opts)
+	var expiresAt int64
+	if opts.ExpiresAtMs != nil {
+		expiresAt = *opts.ExpiresAtMs
+	}
+	return h.vm.ToValue(pluginruntime.NeedsRefreshByExpiry(opts.NowMs, expiresAt, opts.BufferMs, opts.ExpiresAtMs != nil))
+}
+
+func (h *jsHost) discoverLS(call goja.FunctionCall) goja.Value {
+	var opts struct {
+		ProcessName string   `json:"processName"`
+		Markers     []string `json:"markers"`
+		CSRFFlag    string   `json:"csrfFlag"`
+		PortFlag    string   `json:"portFlag"`
+		ExtraFlags  []string `json:"extraFlags"`
+	}
+	h.mustDecode(call.Argument(0), &opts)
+
+	result, err := pluginruntime.DiscoverLS(pluginruntime.LSDiscoverOptions{
+		ProcessName: opts.ProcessName,
+		Markers:     opts.Markers,
+		CSRFFlag:    opts.CSRFFlag,
+		PortFlag:    opts.PortFlag,
+		ExtraFlags:  opts.ExtraFlags,
+	})
+	h.check(err)
+	if result == nil {
+		return goja.Null()
+	}
+	return h.value(result)
+}
+
+type jsLineOptions struct {
+	Label            string                    `json:"label"`
+	Value            string                    `json:"value"`
+	Text             string                    `json:"text"`
+	Used             float64                   `json:"used"`
+	Limit            float64                   `json:"limit"`
+	Format           *openusage.ProgressFormat `json:"format"`
+	ResetsAt         string                    `json:"resetsAt"`
+	PeriodDurationMs int64                     `json:"periodDurationMs"`
+	Color            string                    `json:"color"`
+	Subtitle         string                    `json:"subtitle"`
+}
+
+func (h *jsHost) lineOptions(call goja.FunctionCall) jsLineOptions {
+	var opts jsLineOptions
+	h.mustDecode(call.Argument(0), &opts)
+	return opts
+}
+
+func (h *jsHost) textLine(call goja.FunctionCall) goja.Value {
+	opts := h.lineOptions(call)
+	return h.value(openusage.NewTextLine(opts.Label, opts.Value, openusage.TextLineOptions{Color: opts.Color, Subtitle: opts.Subtitle}))
+}
+
+func (h *jsHost) badgeLine(call goja.FunctionCall) goja.Value {
+	opts := h.lineOptions(call)
+	return h.value(openusage.NewBadgeLine(opts.Label, opts.Text, openusage.TextLineOptions{Color: opts.Color, Subtitle: opts.Subtitle}))
+}
+
+func (h *jsHost) progressLine(call goja.FunctionCall) goja.Value {
+	opts := h.lineOptions(call)
+	format := openusage.PercentFormat()
+	if opts.Format != nil && opts.Format.Kind != "" {
+		format = *opts.Format
+	}
+	return h.value(openusage.NewProgressLine(opts.Label, opts.Used, opts.Limit, format, openusage.ProgressLineOptions{
+		ResetsAt:         opts.ResetsAt,
+		PeriodDurationMs: opts.PeriodDurationMs,
+		Color:            opts.Color,
+		Subtitle:         opts.Subtitle,
+	}))
+}
+
+// formatResetIn formats seconds until a reset: "2d 4h", "3h 12m", "5m" or
+// "<1m".
+func formatResetIn(seconds float64) string {
+	if !(seconds > 0) {
+		return "now"
+	}
+	d := time.Duration(seconds) * time.Second
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return "<1m"
+}
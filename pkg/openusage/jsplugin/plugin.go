@@ -0,0 +1,184 @@
+// Package jsplugin runs OpenUsage JavaScript plugins: manifest entries
+// ending in ".js".
+package jsplugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dop251/goja"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+// Plugin runs an OpenUsage JavaScript plugin. The entry script registers
+// globalThis.__openusage_plugin = {id, probe}, and probe(ctx) returns
+// {plan, lines} built with the host API on ctx (see newJSContext).
+//
+// Each query runs in a fresh runtime. Like upstream OpenUsage, the script is
+// trusted: host calls are not limited by manifest permissions.
+type Plugin struct {
+	id      string
+	program *goja.Program
+}
+
+// New compiles a manifest's JavaScript entry.
+func New(loaded openusage.LoadedManifest) (*Plugin, error) {
+	manifest := loaded.Manifest
+	if !filepath.IsLocal(manifest.Entry) {
+		return nil, fmt.Errorf("plugin %s entry %q is outside the plugin dir", manifest.ID, manifest.Entry)
+	}
+	entry := filepath.Join(loaded.PluginDir, manifest.Entry)
+	source, err := os.ReadFile(entry)
+	if err != nil {
+		return nil, fmt.Errorf("read plugin %s entry: %w", manifest.ID, err)
+	}
+	program, err := goja.Compile(entry, string(source), false)
+	if err != nil {
+		return nil, fmt.Errorf("compile plugin %s: %w", manifest.ID, err)
+	}
+	return &Plugin{id: manifest.ID, program: program}, nil
+}
+
+// Factory is New as an openusage.PluginFactory.
+func Factory(loaded openusage.LoadedManifest) (openusage.Plugin, error) {
+	plugin, err := New(loaded)
+	if err != nil {
+		return nil, err
+	}
+	return plugin, nil
+}
+
+func (p *Plugin) ID() string {
+	return p.id
+}
+
+func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.QueryResult, error) {
+	vm := goja.New()
+	stop := context.AfterFunc(ctx, func() { vm.Interrupt(ctx.Err()) })
+	defer stop()
+
+	if _, err := vm.RunProgram(p.program); err != nil {
+		return openusage.QueryResult{}, jsError(ctx, err)
+	}
+	registered := vm.Get("__openusage_plugin")
+	if registered == nil || goja.IsUndefined(registered) || goja.IsNull(registered) {
+		return openusage.QueryResult{}, errors.New("plugin did not register __openusage_plugin")
+	}
+	probe, ok := goja.AssertFunction(registered.ToObject(vm).Get("probe"))
+	if !ok {
+		return openusage.QueryResult{}, errors.New("plugin has no probe function")
+	}
+
+	host := &jsHost{ctx: ctx, vm: vm, env: env}
+	value, err := probe(registered, newJSContext(host))
+	if err != nil {
+		return openusage.QueryResult{}, jsError(ctx, err)
+	}
+	if promise, ok := value.Export().(*goja.Promise); ok {
+		switch promise.State() {
+		case goja.PromiseStateFulfilled:
+			value = promise.Result()
+		case goja.PromiseStateRejected:
+			return openusage.QueryResult{}, jsThrown(promise.Result())
+		default:
+			return openusage.QueryResult{}, errors.New("plugin probe did not settle")
+		}
+	}
+
+	var payload probeResult
+	if err := host.decode(value, &payload); err != nil {
+		return openusage.QueryResult{}, fmt.Errorf("invalid probe result: %w", err)
+	}
+	return openusage.QueryResult{Plan: payload.Plan, Lines: payload.Lines, Source: payload.Source}, nil
+}
+
+type probeResult struct {
+	Plan   string                 `json:"plan"`
+	Lines  []openusage.MetricLine `json:"lines"`
+	Source string                 `json:"source"`
+}
+
+// jsError turns a goja error into the plugin's error. Upstream plugins throw
+// plain strings for user-facing failures.
+func jsError(ctx context.Context, err error) error {
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		return jsThrown(exception.Value())
+	}
+	return err
+}
+
+// jsThrown converts a thrown value. Objects may carry code, hint and
+// retryable like external plugin errors; plain strings from upstream plugins
+// are classified by their wording.
+func jsThrown(value goja.Value) error {
+	message := jsMessage(value)
+	var code openusage.ErrorCode
+	var hint string
+	var retryable *bool
+	if obj, ok := value.(*goja.Object); ok {
+		if v := obj.Get("code"); v != nil && !goja.IsUndefined(v) {
+			code = openusage.ErrorCode(v.String())
+		}
+		if v := obj.Get("hint"); v != nil && !goja.IsUndefined(v) {
+			hint = v.String()
+		}
+		if v := obj.Get("retryable"); v != nil && !goja.IsUndefined(v) {
+			flag := v.ToBoolean()
+			retryable = &flag
+		}
+	} else {
+		code = classifyMessage(message)
+	}
+	if !code.Valid() {
+		return errors.New(message)
+	}
+	err := openusage.NewPluginError(code, message, hint)
+	if retryable != nil {
+		err.Retryable = *retryable
+	}
+	return err
+}
+
+// classifyMessage guesses the code of an upstream plugin's error string,
+// such as "Not logged in. Run `claude` to authenticate.".
+func classifyMessage(message string) openusage.ErrorCode {
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "not logged in"), strings.Contains(lower, "not signed in"):
+		return openusage.ErrorAuthRequired
+	case strings.Contains(lower, "expired"), strings.Contains(lower, "log in again"), strings.Contains(lower, "sign in again"):
+		return openusage.ErrorAuthExpired
+	case strings.Contains(lower, "(http "):
+		return openusage.ErrorUpstreamHTTP
+	case strings.Contains(lower, "check your connection"), strings.Contains(lower, "network"):
+		return openusage.ErrorNetwork
+	case strings.Contains(lower, "response invalid"), strings.Contains(lower, "invalid response"):
+		return openusage.ErrorParse
+	case strings.Contains(lower, "not running"), strings.HasPrefix(lower, "start "):
+		return openusage.ErrorNotRunning
+	}
+	return ""
+}
+
+func jsMessage(value goja.Value) string {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return "plugin failed"
+	}
+	if obj, ok := value.(*goja.Object); ok {
+		if message := obj.Get("message"); message != nil && !goja.IsUndefined(message) {
+			return message.String()
+		}
+	}
+	return value.String()
+}
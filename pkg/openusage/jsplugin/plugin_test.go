@@ -0,0 +1,241 @@
+package jsplugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+)
+
+func writeJSPlugin(t *testing.T, pluginsDir, id, source string) {
+	t.Helper()
+
+	pluginDir := filepath.Join(pluginsDir, id)
+	if err := os.MkdirAll(pluginDir, 0o755); err != nil {
+		t.Fatalf("mkdir plugin dir: %v", err)
+	}
+	manifest := `{"schemaVersion":1,"id":"` + id + `","name":"Scripted","entry":"plugin.js"}`
+	if err := os.WriteFile(filepath.Join(pluginDir, "plugin.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(pluginDir, "plugin.js"), []byte(source), 0o644); err != nil {
+		t.Fatalf("write entry: %v", err)
+	}
+}
+
+func newManager(t *testing.T, opts openusage.Options) *openusage.Manager {
+	t.Helper()
+
+	opts.PluginFactories = openusage.PluginFactories{".js": Factory}
+	manager, err := openusage.NewManager(opts, nil)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	return manager
+}
+
+const usagePluginJS = `
+(function () {
+  function probe(ctx) {
+    const path = ctx.app.pluginDataDir + "/calls.txt";
+    const calls = ctx.host.fs.exists(path) ? Number(ctx.host.fs.readText(path)) : 0;
+    ctx.host.fs.writeText(path, String(calls + 1));
+
+    const resp = ctx.util.retryOnceOnAuth({
+      request: (token) => ctx.host.http.request({
+        method: "GET",
+        url: ctx.host.env.get("JS_PLUGIN_TEST_URL"),
+        headers: { Authorization: "Bearer " + (token || "stale") },
+      }),
+      refresh: () => "fresh",
+    });
+    if (resp.status !== 200) throw "Usage request failed (HTTP " + resp.status + ")";
+    const data = ctx.util.tryParseJson(resp.bodyText);
+
+    return {
+      plan: ctx.fmt.planLabel(data.plan),
+      lines: [
+        ctx.line.progress({ label: "Session", used: data.used, limit: 100, format: { kind: "percent" }, resetsAt: ctx.util.toIso(data.resetsAt) }),
+        ctx.line.text({ label: "Spend", value: "$" + ctx.fmt.dollars(data.cents) }),
+        ctx.line.badge({ label: "Calls", text: String(calls + 1) }),
+      ],
+    };
+  }
+  globalThis.__openusage_plugin = { id: "scripted", probe };
+})();
+`
+
+func TestJSPluginRunsProbeWithHostAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"plan":"team plus","used":42,"cents":1234,"resetsAt":1767225600}`))
+	}))
+	defer server.Close()
+	t.Setenv("JS_PLUGIN_TEST_URL", server.URL)
+
+	pluginsDir := t.TempDir()
+	writeJSPlugin(t, pluginsDir, "scripted", usagePluginJS)
+
+	manager := newManager(t, openusage.Options{PluginsDir: pluginsDir, DataDir: t.TempDir(), CacheTTL: -1})
+
+	out, err := manager.QueryOne(context.Background(), "scripted")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	if out.Error != "" {
+		t.Fatalf("unexpected error: %s", out.Error)
+	}
+	if out.Plan != "Team Plus" || len(out.Lines) != 3 {
+		t.Fatalf("unexpected output: %+v", out)
+	}
+	session := out.Lines[0]
+	if session.Type != openusage.LineTypeProgress || *session.Used != 42 || session.Format.Kind != openusage.FormatKindPercent {
+		t.Fatalf("unexpected progress line: %+v", session)
+	}
+	if session.ResetsAt == nil || *session.ResetsAt != "2026-01-01T00:00:00.000Z" {
+		t.Fatalf("unexpected resetsAt: %v", session.ResetsAt)
+	}
+	if got := *out.Lines[1].Value; got != "$12.34" {
+		t.Fatalf("unexpected spend: %s", got)
+	}
+
+	out, err = manager.QueryOne(context.Background(), "scripted")
+	if err != nil {
+		t.Fatalf("second QueryOne error: %v", err)
+	}
+	if got := *out.Lines[2].Text; got != "2" {
+		t.Fatalf("expected state to persist between queries, got %s", got)
+	}
+}
+
+func TestJSPluginReportsThrownErrors(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writeJSPlugin(t, pluginsDir, "scripted", `
+globalThis.__openusage_plugin = {
+  id: "scripted",
+  probe(ctx) {
+    try {
+      ctx.host.fs.readText("/nonexistent/auth.json");
+    } catch (e) {
+      throw "Not logged in. Run the app first.";
+    }
+  },
+};`)
+
+	manager := newManager(t, openusage.Options{PluginsDir: pluginsDir, DataDir: t.TempDir()})
+	out, err := manager.QueryOne(context.Background(), "scripted")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	if out.Error != "Not logged in. Run the app first." {
+		t.Fatalf("unexpected error: %q", out.Error)
+	}
+	if out.ErrorDetail == nil || out.ErrorDetail.Code != openusage.ErrorAuthRequired {
+		t.Fatalf("expected auth_required, got %+v", out.ErrorDetail)
+	}
+}
+
+func TestJSPluginThrowsClassifiedErrors(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writeJSPlugin(t, pluginsDir, "scripted", `
+globalThis.__openusage_plugin = {
+  id: "scripted",
+  probe() {
+    const err = new Error("usage API changed");
+    err.code = "parse";
+    err.hint = "update the plugin";
+    throw err;
+  },
+};`)
+
+	manager := newManager(t, openusage.Options{PluginsDir: pluginsDir, DataDir: t.TempDir()})
+	out, err := manager.QueryOne(context.Background(), "scripted")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	if out.Error != "usage API changed; update the plugin" || out.ErrorDetail == nil || out.ErrorDetail.Code != openusage.ErrorParse {
+		t.Fatalf("unexpected error: %q %+v", out.Error, out.ErrorDetail)
+	}
+}
+
+func TestJSPluginInterruptedOnTimeout(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writeJSPlugin(t, pluginsDir, "scripted", `globalThis.__openusage_plugin = { id: "scripted", probe() { for (;;) {} } };`)
+
+	manager := newManager(t, openusage.Options{
+		PluginsDir:    pluginsDir,
+		DataDir:       t.TempDir(),
+		PluginTimeout: 200 * time.Millisecond,
+	})
+	out, err := manager.QueryOne(context.Background(), "scripted")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	if !strings.Contains(out.Error, "timed out") {
+		t.Fatalf("expected timeout error, got %q", out.Error)
+	}
+}
+
+func TestNewJSPluginReportsSyntaxErrors(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writeJSPlugin(t, pluginsDir, "broken", `globalThis.__openusage_plugin = {`)
+
+	_, err := New(openusage.LoadedManifest{
+		Manifest:  openusage.PluginManifest{ID: "broken", Entry: "plugin.js"},
+		PluginDir: filepath.Join(pluginsDir, "broken"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "compile plugin broken") {
+		t.Fatalf("expected compile error, got %v", err)
+	}
+}
+
+func TestJSPluginAccountsCannotUseKeychain(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writeJSPlugin(t, pluginsDir, "scripted", `
+globalThis.__openusage_plugin = {
+  id: "scripted",
+  probe(ctx) {
+    let status;
+    try {
+      ctx.host.keychain.readGenericPassword("Scripted-credentials");
+      status = "read";
+    } catch (e) {
+      status = String(e.message || e);
+    }
+    return { lines: [ctx.line.text({ label: "Keychain", value: status })] };
+  },
+};`)
+
+	manager := newManager(t, openusage.Options{
+		PluginsDir:        pluginsDir,
+		DataDir:           t.TempDir(),
+		PluginCredentials: map[string]string{"scripted@work": "/srv/work.json"},
+		Accounts:          []openusage.Account{{ID: "scripted@work"}},
+	})
+	out, err := manager.QueryOne(context.Background(), "scripted@work")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	if out.Error != "" || len(out.Lines) != 1 || out.Lines[0].Value == nil || !strings.Contains(*out.Lines[0].Value, "not available to accounts") {
+		t.Fatalf("expected the keychain to be unavailable, got %+v (%s)", out.Lines, out.Error)
+	}
+}
@@ -0,0 +1,91 @@
+package openusage
+
+import (
+	"cmp"
+	"slices"
+)
+
+// shapeLines orders lines as declared in the manifest and copies each
+// declaration's scope and primaryOrder onto the lines with its label. Lines
+// the manifest does not declare keep their order after the declared ones and
+// their labels are returned. Without declarations lines are left as emitted.
+func shapeLines(declared []ManifestLine, lines []MetricLine) ([]MetricLine, []string) {
+	if len(declared) == 0 {
+		return lines, nil
+	}
+
+	shaped := make([]MetricLine, 0, len(lines))
+	used := make([]bool, len(lines))
+	for _, decl := range declared {
+		for i, line := range lines {
+			if used[i] || line.Label != decl.Label {
+				continue
+			}
+			line.Scope = decl.Scope
+			line.PrimaryOrder = decl.PrimaryOrder
+			shaped = append(shaped, line)
+			used[i] = true
+		}
+	}
+
+	var undeclared []string
+	for i, line := range lines {
+		if !used[i] {
+			shaped = append(shaped, line)
+			undeclared = append(undeclared, line.Label)
+		}
+	}
+	return shaped, undeclared
+}
+
+// ValidScope reports whether scope is accepted by FilterScope.
+func ValidScope(scope string) bool {
+	switch scope {
+	case "", ScopeOverview, ScopeDetail, ScopePrimary:
+		return true
+	}
+	return false
+}
+
+// FilterScope keeps the lines of output in scope: ScopeOverview or
+// ScopeDetail select lines declared with that scope, ScopePrimary selects
+// lines with a primaryOrder sorted by it, and "" keeps every line. Lines
+// without a declared scope, such as those of plugins without a manifest,
+// count as ScopeOverview; when no line has a primaryOrder, ScopePrimary
+// selects the first of them. Outputs with an error are returned unchanged so
+// the error stays visible.
+func FilterScope(output PluginOutput, scope string) PluginOutput {
+	if scope == "" || output.Error != "" {
+		return output
+	}
+
+	lines := make([]MetricLine, 0, len(output.Lines))
+	for _, line := range output.Lines {
+		switch {
+		case scope == ScopePrimary && line.PrimaryOrder != nil:
+		case scope != ScopePrimary && lineScope(line) == scope:
+		default:
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if scope == ScopePrimary {
+		slices.SortStableFunc(lines, func(a, b MetricLine) int {
+			return cmp.Compare(*a.PrimaryOrder, *b.PrimaryOrder)
+		})
+		if len(lines) == 0 {
+			if i := slices.IndexFunc(output.Lines, func(line MetricLine) bool { return lineScope(line) == ScopeOverview }); i >= 0 {
+				lines = append(lines, output.Lines[i])
+			}
+		}
+	}
+	output.Lines = lines
+	return output
+}
+
+func lineScope(line MetricLine) string {
+	if line.Scope == "" {
+		return ScopeOverview
+	}
+	return line.Scope
+}
@@ -0,0 +1,138 @@
+package openusage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+func lineLabels(lines []MetricLine) []string {
+	labels := make([]string, len(lines))
+	for i, line := range lines {
+		labels[i] = line.Label
+	}
+	return labels
+}
+
+func TestManagerShapesLinesFromManifest(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writeManifest(t, pluginsDir, "shaped", `{"schemaVersion":1,"id":"shaped","name":"Shaped","lines":[
+		{"type":"progress","label":"Session","scope":"overview","primaryOrder":2},
+		{"type":"progress","label":"Weekly","scope":"overview","primaryOrder":1},
+		{"type":"text","label":"Spend","scope":"detail"}]}`)
+	manager, err := NewManager(Options{PluginsDir: pluginsDir, DataDir: t.TempDir()}, []Plugin{stubPlugin{
+		id: "shaped",
+		fn: func(context.Context, *pluginruntime.Env) (QueryResult, error) {
+			return QueryResult{Lines: []MetricLine{
+				NewTextLine("Extra", "x", TextLineOptions{}),
+				NewProgressLine("Weekly", 10, 100, PercentFormat(), ProgressLineOptions{}),
+				NewTextLine("Spend", "$1", TextLineOptions{}),
+				NewProgressLine("Session", 50, 100, PercentFormat(), ProgressLineOptions{}),
+			}}, nil
+		},
+	}})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	out, err := manager.QueryOne(context.Background(), "shaped")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+
+	if got := lineLabels(out.Lines); !slices.Equal(got, []string{"Session", "Weekly", "Spend", "Extra"}) {
+		t.Fatalf("unexpected order: %v", got)
+	}
+	if out.Lines[0].Scope != ScopeOverview || *out.Lines[0].PrimaryOrder != 2 || out.Lines[3].Scope != "" {
+		t.Fatalf("unexpected declarations: %+v", out.Lines)
+	}
+
+	for scope, want := range map[string][]string{
+		"":            {"Session", "Weekly", "Spend", "Extra"},
+		ScopeOverview: {"Session", "Weekly", "Extra"},
+		ScopeDetail:   {"Spend"},
+		ScopePrimary:  {"Weekly", "Session"},
+	} {
+		if got := lineLabels(FilterScope(out, scope).Lines); !slices.Equal(got, want) {
+			t.Fatalf("scope %q: expected %v, got %v", scope, want, got)
+		}
+	}
+	if got := lineLabels(out.Lines); !slices.Equal(got, []string{"Session", "Weekly", "Spend", "Extra"}) {
+		t.Fatalf("FilterScope modified the output: %v", got)
+	}
+}
+
+func TestManagerBoundsUndeclaredLineWarnings(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writeManifest(t, pluginsDir, "daily", `{"schemaVersion":1,"id":"daily","name":"Daily","lines":[{"type":"text","label":"Total"}]}`)
+	manager, err := NewManager(Options{PluginsDir: pluginsDir, DataDir: t.TempDir(), CacheTTL: -1}, []Plugin{stubPlugin{
+		id: "daily",
+		fn: func(context.Context, *pluginruntime.Env) (QueryResult, error) {
+			lines := []MetricLine{NewTextLine("Total", "1", TextLineOptions{})}
+			for day := range 3 * maxUndeclaredWarnings {
+				lines = append(lines, NewTextLine(fmt.Sprintf("Day %d", day), "1", TextLineOptions{}))
+			}
+			return QueryResult{Lines: lines}, nil
+		},
+	}})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	for range 2 {
+		if _, err := manager.QueryOne(context.Background(), "daily"); err != nil {
+			t.Fatalf("QueryOne error: %v", err)
+		}
+	}
+	if got := len(manager.undeclared["daily"]); got != maxUndeclaredWarnings {
+		t.Fatalf("expected %d remembered labels, got %d", maxUndeclaredWarnings, got)
+	}
+}
+
+func TestFilterScopeWithoutManifest(t *testing.T) {
+	t.Parallel()
+
+	manager, err := NewManager(Options{DataDir: t.TempDir()}, []Plugin{stubPlugin{
+		id: "builtin",
+		fn: func(context.Context, *pluginruntime.Env) (QueryResult, error) {
+			return QueryResult{Lines: []MetricLine{
+				NewProgressLine("Session", 50, 100, PercentFormat(), ProgressLineOptions{}),
+				NewProgressLine("Weekly", 10, 100, PercentFormat(), ProgressLineOptions{}),
+			}}, nil
+		},
+	}})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	out, err := manager.QueryOne(context.Background(), "builtin")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+
+	for scope, want := range map[string][]string{
+		ScopeOverview: {"Session", "Weekly"},
+		ScopeDetail:   {},
+		ScopePrimary:  {"Session"},
+	} {
+		if got := lineLabels(FilterScope(out, scope).Lines); !slices.Equal(got, want) {
+			t.Fatalf("scope %q: expected %v, got %v", scope, want, got)
+		}
+	}
+}
+
+func TestFilterScopeKeepsErrors(t *testing.T) {
+	t.Parallel()
+
+	out := PluginOutput{Error: "boom", Lines: ErrorLines("boom")}
+	if got := FilterScope(out, ScopePrimary); len(got.Lines) != 1 {
+		t.Fatalf("expected error line to be kept, got %+v", got.Lines)
+	}
+	if ValidScope("summary") {
+		t.Fatal("expected unknown scope to be invalid")
+	}
+}
@@ -4,9 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
 )
@@ -16,23 +21,124 @@ type Plugin interface {
 	Query(ctx context.Context, env *pluginruntime.Env) (QueryResult, error)
 }
 
+// PluginFactory loads the plugin for a manifest entry.
+type PluginFactory func(LoadedManifest) (Plugin, error)
+
+// PluginFactories maps entry file extensions, such as ".js", to the factory
+// loading them. Entries without a factory run as external executables.
+type PluginFactories map[string]PluginFactory
+
+// New returns the plugin for a manifest entry.
+func (f PluginFactories) New(loaded LoadedManifest) (Plugin, error) {
+	if factory, ok := f[strings.ToLower(filepath.Ext(loaded.Manifest.Entry))]; ok {
+		return factory(loaded)
+	}
+	plugin, err := NewExternalPlugin(loaded)
+	if err != nil {
+		return nil, err
+	}
+	return plugin, nil
+}
+
+// DefaultPluginsDir is where manifests are looked for when Options.PluginsDir
+// is empty; it may be missing.
+var DefaultPluginsDir = filepath.Join("openusage", "plugins")
+
+const (
+	DefaultConcurrency   = 4
+	DefaultPluginTimeout = 12 * time.Second
+	DefaultCacheTTL      = time.Minute
+)
+
 type Options struct {
 	PluginsDir string
 	DataDir    string
+	// Concurrency caps how many plugins QueryAll runs at once. Zero uses DefaultConcurrency.
+	Concurrency int
+	// PluginTimeout bounds a single plugin query. Zero uses DefaultPluginTimeout.
+	PluginTimeout time.Duration
+	// PluginTimeouts overrides PluginTimeout per plugin ID.
+	PluginTimeouts map[string]time.Duration
+	// CacheTTL is how long a plugin result is served without refreshing it.
+	// Zero uses DefaultCacheTTL; a negative value disables caching.
+	CacheTTL time.Duration
+	// PluginCacheTTLs overrides CacheTTL per plugin ID.
+	PluginCacheTTLs map[string]time.Duration
+	// PluginCredentials sets pluginruntime.Env.CredentialsPath per plugin ID.
+	PluginCredentials map[string]string
+	// PluginLogsDirs sets pluginruntime.Env.LogsDir per plugin ID.
+	PluginLogsDirs map[string]string
+	// EnabledPlugins, when non-empty, limits the manager to these plugin IDs.
+	EnabledPlugins []string
+	// DisabledPlugins removes plugin IDs; it wins over EnabledPlugins.
+	DisabledPlugins []string
+	// History, when set, supplies recorded samples for burn-rate forecasts.
+	History UsageHistory
+	// Metrics, when set, observes upstream queries and cache lookups.
+	Metrics QueryMetrics
+	// Transport, when set, carries the HTTP requests of every plugin, e.g. a
+	// pluginruntime.Recorder.
+	Transport http.RoundTripper
+	// Accounts adds instances of plugins for further accounts. Their IDs are
+	// subject to EnabledPlugins and DisabledPlugins; enabling a plugin also
+	// enables its accounts.
+	Accounts []Account
+	// Logger receives warnings such as plugins that failed to load. Nil
+	// logs to stderr.
+	Logger *log.Logger
+	// PluginFactories loads manifest entries by extension, e.g. the
+	// JavaScript and WebAssembly runtimes from builtin.PluginFactories.
+	PluginFactories PluginFactories
+	// StrictManifests makes NewManager fail with ManifestErrors when any
+	// plugin.json in PluginsDir is invalid, instead of logging the problems
+	// and skipping it.
+	StrictManifests bool
+}
+
+// QueryMetrics receives instrumentation from the manager.
+type QueryMetrics interface {
+	// ObserveQuery reports one upstream plugin query; failed is true when
+	// the output carries an error.
+	ObserveQuery(id string, duration time.Duration, failed bool)
+	// ObserveCacheLookup reports whether a read was served from the cache.
+	ObserveCacheLookup(id string, hit bool)
 }
 
 type Manager struct {
-	plugins   map[string]Plugin
-	manifests map[string]LoadedManifest
-	order     []string
-	dataDir   string
+	plugins       map[string]Plugin
+	manifests     map[string]LoadedManifest
+	order         []string
+	disabled      map[string]PluginInfo
+	accountNames  map[string]string
+	loadErrors    map[string]string
+	dataDir       string
+	pluginTimeout time.Duration
+	timeouts      map[string]time.Duration
+	credentials   map[string]string
+	logsDirs      map[string]string
+	transport     http.RoundTripper
+	cacheTTL      time.Duration
+	cacheTTLs     map[string]time.Duration
+	history       UsageHistory
+	metrics       QueryMetrics
+	cache         *resultCache
+	flights       *flightGroup
+	sem           chan struct{}
+	now           func() time.Time
+
+	mu        sync.RWMutex
+	polled    map[string]bool
+	observers []func(PluginOutput)
+	// undeclared holds, per plugin ID, the labels of lines already warned
+	// about, up to maxUndeclaredWarnings.
+	undeclared map[string]map[string]bool
 }
 
 func NewManager(opts Options, plugins []Plugin) (*Manager, error) {
 	pluginsDir := opts.PluginsDir
 	pluginsDirExplicit := pluginsDir != ""
 	if pluginsDir == "" {
-		pluginsDir = filepath.Join("openusage", "plugins")
+		pluginsDir = DefaultPluginsDir
 	}
 
 	dataDir := opts.DataDir
@@ -40,11 +146,45 @@ func NewManager(opts Options, plugins []Plugin) (*Manager, error) {
 		dataDir = pluginruntime.DefaultDataDir()
 	}
 
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	pluginTimeout := opts.PluginTimeout
+	if pluginTimeout <= 0 {
+		pluginTimeout = DefaultPluginTimeout
+	}
+
+	cacheTTL := opts.CacheTTL
+	if cacheTTL == 0 {
+		cacheTTL = DefaultCacheTTL
+	}
+	cacheTTLs := copyMap(opts.PluginCacheTTLs)
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.New(os.Stderr, "[openusage] ", log.LstdFlags)
+	}
+
+	problems, err := LintManifests(pluginsDir, opts.PluginFactories)
+	if err != nil && (pluginsDirExplicit || !errors.Is(err, os.ErrNotExist)) {
+		return nil, fmt.Errorf("lint manifests in %s: %w", pluginsDir, err)
+	}
+	if opts.StrictManifests && len(problems) > 0 {
+		return nil, problems
+	}
+	// Without StrictManifests invalid manifests are skipped or loaded as far
+	// as possible; say why, so a missing plugin can be explained.
+	for _, problem := range problems {
+		logger.Printf("invalid plugin manifest: %v", problem)
+	}
+
 	manifestMap := make(map[string]LoadedManifest)
 	manifestOrder := make([]string, 0)
 	loadedManifests, loadedOrder, err := LoadManifests(pluginsDir)
 	if err != nil {
-		// Manifests are optional when using defaults. Plugin implementations are Go-native.
+		// Manifests are optional when using defaults; they add names, icons and external plugins.
 		if pluginsDirExplicit || !errors.Is(err, os.ErrNotExist) {
 			return nil, fmt.Errorf("load manifests from %s: %w", pluginsDir, err)
 		}
@@ -52,42 +192,243 @@ func NewManager(opts Options, plugins []Plugin) (*Manager, error) {
 		manifestMap = loadedManifests
 		manifestOrder = loadedOrder
 	}
+	providerManifests := copyMap(manifestMap)
+
+	allowed := pluginFilter(opts.EnabledPlugins, opts.DisabledPlugins)
+
+	// Manifest entries are loaded at most once, also when accounts share them.
+	loadErrors := make(map[string]string)
+	manifestPlugins := make(map[string]Plugin)
+	loadManifestPlugin := func(id string, loaded LoadedManifest) (Plugin, error) {
+		if plugin, ok := manifestPlugins[id]; ok {
+			return plugin, nil
+		}
+		if msg, failed := loadErrors[id]; failed {
+			return nil, errors.New(msg)
+		}
+		plugin, err := opts.PluginFactories.New(loaded)
+		if err != nil {
+			logger.Printf("plugin %s unavailable: %v", id, err)
+			loadErrors[id] = err.Error()
+			return nil, err
+		}
+		manifestPlugins[id] = plugin
+		return plugin, nil
+	}
 
 	pluginMap := make(map[string]Plugin, len(plugins))
+	goPlugins := make(map[string]Plugin, len(plugins))
+	disabled := make(map[string]PluginInfo)
 	for _, p := range plugins {
-		pluginMap[p.ID()] = p
+		goPlugins[p.ID()] = p
+		if allowed(p.ID()) {
+			pluginMap[p.ID()] = p
+		} else {
+			disabled[p.ID()] = newPluginInfo(p.ID(), manifestMap[p.ID()], true, false)
+		}
+	}
+	for id, loaded := range manifestMap {
+		if !allowed(id) {
+			if _, ok := disabled[id]; !ok {
+				disabled[id] = newPluginInfo(id, loaded, loaded.Manifest.Entry != "", false)
+			}
+			delete(manifestMap, id)
+			continue
+		}
+		// Go plugins win over a manifest entry with the same ID.
+		if _, ok := pluginMap[id]; ok || loaded.Manifest.Entry == "" {
+			continue
+		}
+		if plugin, err := loadManifestPlugin(id, loaded); err == nil {
+			pluginMap[id] = plugin
+		}
+	}
+
+	accountNames := make(map[string]string)
+	accountsByProvider := make(map[string][]string)
+	for _, account := range opts.Accounts {
+		provider, name := SplitAccountID(account.ID)
+		if name == "" || !pluginIDPattern.MatchString(provider) || strings.ContainsAny(name, `@/\`) {
+			return nil, fmt.Errorf("invalid account %q: want <plugin>@<name>", account.ID)
+		}
+		if _, dup := accountNames[account.ID]; dup {
+			return nil, fmt.Errorf("account %s is configured twice", account.ID)
+		}
+		accountNames[account.ID] = account.DisplayName
+
+		loaded, hasManifest := providerManifests[provider]
+		impl := goPlugins[provider]
+		if impl == nil && hasManifest && loaded.Manifest.Entry != "" {
+			var err error
+			if impl, err = loadManifestPlugin(provider, loaded); err != nil {
+				loadErrors[account.ID] = err.Error()
+			}
+		}
+		if impl == nil && !hasManifest {
+			return nil, fmt.Errorf("account %s: unknown plugin %s", account.ID, provider)
+		}
+
+		if !allowed(account.ID) {
+			info := newPluginInfo(account.ID, loaded, impl != nil, false)
+			if account.DisplayName != "" {
+				info.DisplayName = account.DisplayName
+			}
+			disabled[account.ID] = info
+			continue
+		}
+		if impl != nil {
+			pluginMap[account.ID] = impl
+		}
+		if hasManifest {
+			manifestMap[account.ID] = loaded
+		}
+		accountsByProvider[provider] = append(accountsByProvider[provider], account.ID)
 	}
 
 	order := make([]string, 0, len(manifestOrder)+len(pluginMap))
 	seen := make(map[string]struct{}, len(manifestOrder)+len(pluginMap))
+	add := func(id string) {
+		if allowed(id) {
+			order = append(order, id)
+			seen[id] = struct{}{}
+		}
+		// Accounts follow their plugin, or take its place when it is disabled.
+		accounts := accountsByProvider[id]
+		sort.Strings(accounts)
+		for _, account := range accounts {
+			order = append(order, account)
+			seen[account] = struct{}{}
+		}
+		delete(accountsByProvider, id)
+	}
 	for _, id := range manifestOrder {
-		order = append(order, id)
-		seen[id] = struct{}{}
+		add(id)
 	}
 
 	extra := make([]string, 0)
 	for id := range pluginMap {
-		if _, ok := seen[id]; !ok {
+		if _, ok := seen[id]; !ok && !strings.Contains(id, "@") {
 			extra = append(extra, id)
 		}
 	}
+	for provider := range accountsByProvider {
+		if _, ok := seen[provider]; !ok && pluginMap[provider] == nil {
+			extra = append(extra, provider)
+		}
+	}
 	sort.Strings(extra)
-	order = append(order, extra...)
+	for _, id := range extra {
+		add(id)
+	}
 
 	return &Manager{
-		plugins:   pluginMap,
-		manifests: manifestMap,
-		order:     order,
-		dataDir:   dataDir,
+		plugins:       pluginMap,
+		manifests:     manifestMap,
+		order:         order,
+		disabled:      disabled,
+		accountNames:  accountNames,
+		loadErrors:    loadErrors,
+		dataDir:       dataDir,
+		pluginTimeout: pluginTimeout,
+		timeouts:      copyMap(opts.PluginTimeouts),
+		credentials:   copyMap(opts.PluginCredentials),
+		logsDirs:      copyMap(opts.PluginLogsDirs),
+		transport:     opts.Transport,
+		cacheTTL:      cacheTTL,
+		cacheTTLs:     cacheTTLs,
+		history:       opts.History,
+		metrics:       opts.Metrics,
+		cache:         newResultCache(),
+		flights:       newFlightGroup(),
+		sem:           make(chan struct{}, concurrency),
+		now:           time.Now,
+		polled:        make(map[string]bool),
+		undeclared:    make(map[string]map[string]bool),
 	}, nil
 }
 
+// pluginFilter reports whether an ID passes the enabled/disabled lists. An
+// account instance is enabled along with its plugin but disabled on its own.
+func pluginFilter(enabled, disabled []string) func(string) bool {
+	enabledSet := make(map[string]bool, len(enabled))
+	for _, id := range enabled {
+		enabledSet[id] = true
+	}
+	disabledSet := make(map[string]bool, len(disabled))
+	for _, id := range disabled {
+		disabledSet[id] = true
+	}
+	return func(id string) bool {
+		if disabledSet[id] {
+			return false
+		}
+		provider, _ := SplitAccountID(id)
+		return len(enabledSet) == 0 || enabledSet[id] || enabledSet[provider]
+	}
+}
+
+func copyMap[V any](in map[string]V) map[string]V {
+	out := make(map[string]V, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
 func (m *Manager) PluginIDs() []string {
 	ids := make([]string, len(m.order))
 	copy(ids, m.order)
 	return ids
 }
 
+// DataDir returns the resolved state directory.
+func (m *Manager) DataDir() string {
+	return m.dataDir
+}
+
+// HasImplementation reports whether a Go or external plugin is registered for id.
+func (m *Manager) HasImplementation(id string) bool {
+	_, ok := m.plugins[id]
+	return ok
+}
+
+// OnResult registers fn to be called with every fresh plugin output, whether
+// it was fetched on demand or by a Scheduler. Cache hits are not reported.
+// fn runs on the fetching goroutine and must not block for long.
+func (m *Manager) OnResult(fn func(PluginOutput)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observers = append(m.observers, fn)
+}
+
+func (m *Manager) notify(output PluginOutput) {
+	m.mu.RLock()
+	observers := m.observers
+	m.mu.RUnlock()
+
+	for _, fn := range observers {
+		fn(output)
+	}
+}
+
+func (m *Manager) setPolled(id string, polled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if polled {
+		m.polled[id] = true
+	} else {
+		delete(m.polled, id)
+	}
+}
+
+// isPolled reports whether a scheduler keeps id fresh, in which case reads
+// serve the latest snapshot without triggering their own refresh.
+func (m *Manager) isPolled(id string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.polled[id]
+}
+
 func (m *Manager) HasPlugin(id string) bool {
 	if _, ok := m.plugins[id]; ok {
 		return true
@@ -98,42 +439,146 @@ func (m *Manager) HasPlugin(id string) bool {
 	return false
 }
 
+// QueryAll queries the given plugins (or all of them when ids is empty)
+// concurrently; upstream queries are bounded by the configured concurrency.
+// Results keep the order of ids, or of PluginIDs when ids is empty.
 func (m *Manager) QueryAll(ctx context.Context, ids []string) ([]PluginOutput, error) {
 	targetIDs := ids
 	if len(targetIDs) == 0 {
 		targetIDs = m.PluginIDs()
 	}
 
-	out := make([]PluginOutput, 0, len(targetIDs))
-	for _, id := range targetIDs {
-		result, err := m.QueryOne(ctx, id)
+	out := make([]PluginOutput, len(targetIDs))
+	errs := make([]error, len(targetIDs))
+
+	var wg sync.WaitGroup
+	for i, id := range targetIDs {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			out[i], errs[i] = m.QueryOne(ctx, id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
 		if err != nil {
 			return nil, err
 		}
-		out = append(out, result)
 	}
 	return out, nil
 }
 
+// QueryOne returns the output for one plugin. Fresh cached results are served
+// directly; stale ones are served while a single background refresh runs.
+// Concurrent cache misses for the same plugin share one upstream query.
 func (m *Manager) QueryOne(ctx context.Context, id string) (PluginOutput, error) {
+	ttl := m.cacheTTLFor(id)
+	if ttl < 0 {
+		m.observeCacheLookup(id, false)
+		return m.Refresh(ctx, id)
+	}
+
+	entry, ok := m.cache.get(id)
+	m.observeCacheLookup(id, ok)
+	if ok {
+		if m.now().Sub(entry.fetchedAt) >= ttl && !m.isPolled(id) {
+			m.startFetch(ctx, id)
+		}
+		output := entry.output
+		output.Cached = true
+		return output, nil
+	}
+
+	return m.Refresh(ctx, id)
+}
+
+// Refresh queries a plugin upstream, bypassing any cached result, and stores
+// the new output. It joins a query for the same plugin that is already running.
+func (m *Manager) Refresh(ctx context.Context, id string) (PluginOutput, error) {
+	call := m.startFetch(ctx, id)
+	select {
+	case <-call.done:
+		return call.output, call.err
+	case <-ctx.Done():
+		return PluginOutput{}, ctx.Err()
+	}
+}
+
+func (m *Manager) timeoutFor(id string) time.Duration {
+	if timeout, ok := m.timeouts[id]; ok && timeout > 0 {
+		return timeout
+	}
+	return m.pluginTimeout
+}
+
+func (m *Manager) cacheTTLFor(id string) time.Duration {
+	if ttl, ok := m.cacheTTLs[id]; ok && ttl != 0 {
+		return ttl
+	}
+	return m.cacheTTL
+}
+
+// startFetch starts (or joins) the shared upstream query for id and stores a
+// successful result in the cache. The query is detached from ctx cancellation
+// so one impatient caller cannot abort a fetch other callers are waiting on;
+// the per-plugin deadline still bounds it.
+func (m *Manager) startFetch(ctx context.Context, id string) *flightCall {
+	fetchCtx := context.WithoutCancel(ctx)
+	return m.flights.start(id, func() (PluginOutput, error) {
+		m.sem <- struct{}{}
+		defer func() { <-m.sem }()
+
+		started := time.Now()
+		output, err := m.queryFresh(fetchCtx, id)
+		if m.metrics != nil {
+			m.metrics.ObserveQuery(id, time.Since(started), err != nil || output.Error != "")
+		}
+		if err == nil {
+			m.cache.put(id, output, m.now())
+			m.notify(output)
+		}
+		return output, err
+	})
+}
+
+func (m *Manager) observeCacheLookup(id string, hit bool) {
+	if m.metrics != nil {
+		m.metrics.ObserveCacheLookup(id, hit)
+	}
+}
+
+func (m *Manager) queryFresh(ctx context.Context, id string) (PluginOutput, error) {
 	manifest, hasManifest := m.manifests[id]
+	fetchedAt := m.now()
 
 	output := PluginOutput{
 		ProviderID:  id,
 		DisplayName: id,
 		Lines:       ErrorLines("No data"),
+		FetchedAt:   fetchedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
 	}
 
+	output.Provider, output.Account = SplitAccountID(id)
 	if hasManifest {
 		if manifest.Manifest.Name != "" {
 			output.DisplayName = manifest.Manifest.Name
 		}
 		output.IconURL = manifest.IconDataURL
 	}
+	if output.Account != "" {
+		output.DisplayName = fmt.Sprintf("%s (%s)", output.DisplayName, output.Account)
+		if name := m.accountNames[id]; name != "" {
+			output.DisplayName = name
+		}
+	}
 
 	plugin, ok := m.plugins[id]
 	if !ok {
 		errMsg := "Plugin implementation unavailable"
+		if loadErr := m.loadErrors[id]; loadErr != "" {
+			errMsg += ": " + loadErr
+		}
 		output.Error = errMsg
 		output.Lines = ErrorLines(errMsg)
 		return output, nil
@@ -143,16 +588,37 @@ func (m *Manager) QueryOne(ctx context.Context, id string) (PluginOutput, error)
 	if err != nil {
 		return PluginOutput{}, fmt.Errorf("init env for %s: %w", id, err)
 	}
+	env.CredentialsPath = m.credentials[id]
+	env.LogsDir = m.logsDirs[id]
+	env.Account = output.Account
+	env.Transport = m.transport
+	if env.Account != "" && env.CredentialsPath == "" && env.LogsDir == "" {
+		errMsg := "No credentials configured for " + id
+		output.Error = errMsg
+		output.ErrorDetail = NewPluginError(ErrorAuthRequired, errMsg, fmt.Sprintf(`set plugins."%s".credentials`, id))
+		output.Lines = ErrorLines(errMsg)
+		return output, nil
+	}
 
-	result, err := plugin.Query(ctx, env)
+	timeout := m.timeoutFor(id)
+	result, err := m.runPlugin(ctx, plugin, env, timeout)
+	output.Source = result.Source
+	if errors.Is(err, errPluginTimeout) {
+		errMsg := fmt.Sprintf("Plugin timed out after %s", timeout)
+		output.Error = errMsg
+		output.ErrorDetail = NewPluginError(ErrorTimeout, errMsg, fmt.Sprintf(`raise plugins."%s".timeout`, id))
+		output.Lines = ErrorLines(errMsg)
+		return output, nil
+	}
 	if err != nil {
 		output.Error = err.Error()
+		output.ErrorDetail = ClassifyError(err)
 		output.Lines = ErrorLines(err.Error())
 		if result.Plan != "" {
 			output.Plan = result.Plan
 		}
 		if len(result.Lines) > 0 {
-			output.Lines = result.Lines
+			output.Lines = m.withForecasts(id, m.declaredLines(env, result.Lines), fetchedAt)
 		}
 		return output, nil
 	}
@@ -161,8 +627,102 @@ func (m *Manager) QueryOne(ctx context.Context, id string) (PluginOutput, error)
 	if len(result.Lines) == 0 {
 		output.Lines = ErrorLines("No usage data")
 	} else {
-		output.Lines = result.Lines
+		output.Lines = m.withForecasts(id, m.declaredLines(env, result.Lines), fetchedAt)
 	}
 
 	return output, nil
 }
+
+// maxUndeclaredWarnings bounds how many undeclared labels are remembered per
+// plugin; plugins such as claude-logs emit a line per day or model.
+const maxUndeclaredWarnings = 32
+
+// declaredLines applies the manifest's line declarations and warns once per
+// label a plugin emits without declaring it.
+func (m *Manager) declaredLines(env *pluginruntime.Env, lines []MetricLine) []MetricLine {
+	shaped, undeclared := shapeLines(m.manifests[env.PluginID].Manifest.Lines, lines)
+	for _, label := range undeclared {
+		m.mu.Lock()
+		warned := m.undeclared[env.PluginID]
+		if warned == nil {
+			warned = make(map[string]bool)
+			m.undeclared[env.PluginID] = warned
+		}
+		warn := len(warned) < maxUndeclaredWarnings && !warned[label]
+		if warn {
+			warned[label] = true
+		}
+		last := warn && len(warned) == maxUndeclaredWarnings
+		m.mu.Unlock()
+		if !warn || env.Logger == nil {
+			continue
+		}
+		env.Logger.Printf("line %q is not declared in plugin.json", label)
+		if last {
+			env.Logger.Print("further undeclared lines are not reported")
+		}
+	}
+	return shaped
+}
+
+// withForecasts attaches a Forecast to every progress line that has enough
+// period data, using recorded history for the burn rate when available.
+func (m *Manager) withForecasts(id string, lines []MetricLine, now time.Time) []MetricLine {
+	// Load the history once, back to the start of the longest period.
+	var recorded map[string][]UsageSample
+	if m.history != nil {
+		var longest time.Duration
+		for _, line := range lines {
+			if line.Type == LineTypeProgress && line.PeriodDurationMs != nil {
+				longest = max(longest, time.Duration(*line.PeriodDurationMs)*time.Millisecond)
+			}
+		}
+		if longest > 0 {
+			if samples, err := m.history.UsageSamples(id, now.Add(-longest)); err == nil {
+				recorded = samples
+			}
+		}
+	}
+
+	for i, line := range lines {
+		if line.Type != LineTypeProgress {
+			continue
+		}
+		lines[i].Forecast = ComputeForecast(line, now, recorded[line.Label])
+	}
+	return lines
+}
+
+var errPluginTimeout = errors.New("plugin timed out")
+
+type pluginRun struct {
+	result QueryResult
+	err    error
+}
+
+// runPlugin runs plugin.Query under the per-plugin deadline. The query runs in
+// its own goroutine so a plugin that ignores its context cannot hold up the
+// caller past the deadline; its late result is discarded.
+func (m *Manager) runPlugin(ctx context.Context, plugin Plugin, env *pluginruntime.Env, timeout time.Duration) (QueryResult, error) {
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan pluginRun, 1)
+	go func() {
+		result, err := plugin.Query(runCtx, env)
+		done <- pluginRun{result: result, err: err}
+	}()
+
+	select {
+	case run := <-done:
+		if run.err != nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return QueryResult{}, errPluginTimeout
+		}
+		return run.result, run.err
+	case <-runCtx.Done():
+		if ctx.Err() != nil {
+			return QueryResult{}, ctx.Err()
+		}
+		return QueryResult{}, errPluginTimeout
+	}
+}
@@ -3,11 +3,14 @@ package openusage
 import (
 	"context"
 	"errors"
+	"log"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
 )
@@ -209,6 +212,43 @@ func TestManagerQueryOneWithoutImplementationReturnsStructuredError(t *testing.T
 	}
 }
 
+func TestManagerReportsPluginLoadErrors(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writeStubPlugin(t, pluginsDir, "broken")
+	var logs strings.Builder
+
+	manager, err := NewManager(Options{
+		PluginsDir:        pluginsDir,
+		DataDir:           t.TempDir(),
+		Logger:            log.New(&logs, "", 0),
+		Accounts:          []Account{{ID: "broken@work"}},
+		PluginCredentials: map[string]string{"broken@work": "/srv/work.json"},
+		PluginFactories:   brokenFactories,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	if strings.Count(logs.String(), "plugin broken unavailable:") != 1 {
+		t.Fatalf("expected the load error to be logged once, got %q", logs.String())
+	}
+
+	for _, id := range []string{"broken", "broken@work"} {
+		out, err := manager.QueryOne(context.Background(), id)
+		if err != nil {
+			t.Fatalf("QueryOne error: %v", err)
+		}
+		if !strings.HasPrefix(out.Error, "Plugin implementation unavailable: ") || len(out.Error) == len("Plugin implementation unavailable: ") {
+			t.Fatalf("expected load error in output, got %q", out.Error)
+		}
+		info, _ := manager.Plugin(id)
+		if info.Implemented || info.LoadError == "" || !strings.HasSuffix(out.Error, info.LoadError) {
+			t.Fatalf("expected load error in plugin info, got %+v", info)
+		}
+	}
+}
+
 func TestManagerQueryOnePluginErrorUsesResultPayload(t *testing.T) {
 	t.Parallel()
 
@@ -290,3 +330,276 @@ func TestManagerQueryAllWithSelectedIDs(t *testing.T) {
 		t.Fatalf("unexpected provider: %s", out[0].ProviderID)
 	}
 }
+
+func TestManagerQueryAllRunsConcurrentlyAndKeepsOrder(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writePluginManifest(t, pluginsDir, "a", "A")
+	writePluginManifest(t, pluginsDir, "b", "B")
+	writePluginManifest(t, pluginsDir, "c", "C")
+
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	blocking := func(plan string) func(context.Context, *pluginruntime.Env) (QueryResult, error) {
+		return func(_ context.Context, _ *pluginruntime.Env) (QueryResult, error) {
+			started <- struct{}{}
+			<-release
+			return QueryResult{Plan: plan, Lines: []MetricLine{NewTextLine(plan, "ok", TextLineOptions{})}}, nil
+		}
+	}
+
+	manager, err := NewManager(Options{
+		PluginsDir:  pluginsDir,
+		DataDir:     t.TempDir(),
+		Concurrency: 3,
+	}, []Plugin{
+		stubPlugin{id: "a", fn: blocking("A")},
+		stubPlugin{id: "b", fn: blocking("B")},
+		stubPlugin{id: "c", fn: blocking("C")},
+	})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	go func() {
+		for i := 0; i < 3; i++ {
+			<-started
+		}
+		close(release)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	out, err := manager.QueryAll(ctx, nil)
+	if err != nil {
+		t.Fatalf("QueryAll error: %v", err)
+	}
+
+	got := make([]string, 0, len(out))
+	for _, o := range out {
+		got = append(got, o.Plan)
+	}
+	if want := []string{"A", "B", "C"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected output order: got %v want %v", got, want)
+	}
+}
+
+func TestManagerQueryAllTimesOutSlowPlugin(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writePluginManifest(t, pluginsDir, "fast", "Fast")
+	writePluginManifest(t, pluginsDir, "slow", "Slow")
+
+	hang := make(chan struct{})
+	t.Cleanup(func() { close(hang) })
+
+	manager, err := NewManager(Options{
+		PluginsDir:    pluginsDir,
+		DataDir:       t.TempDir(),
+		PluginTimeout: 50 * time.Millisecond,
+	}, []Plugin{
+		stubPlugin{
+			id: "fast",
+			fn: func(_ context.Context, _ *pluginruntime.Env) (QueryResult, error) {
+				return QueryResult{Plan: "Fast", Lines: []MetricLine{NewTextLine("Status", "ok", TextLineOptions{})}}, nil
+			},
+		},
+		stubPlugin{
+			id: "slow",
+			fn: func(_ context.Context, _ *pluginruntime.Env) (QueryResult, error) {
+				// Ignores its context on purpose.
+				<-hang
+				return QueryResult{}, nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	start := time.Now()
+	out, err := manager.QueryAll(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("QueryAll error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("slow plugin held up QueryAll for %s", elapsed)
+	}
+
+	if len(out) != 2 {
+		t.Fatalf("unexpected output length: %d", len(out))
+	}
+	if out[0].ProviderID != "fast" || out[0].Error != "" {
+		t.Fatalf("unexpected fast output: %+v", out[0])
+	}
+	if out[1].ProviderID != "slow" || !strings.Contains(out[1].Error, "timed out") {
+		t.Fatalf("expected timeout output for slow plugin, got %+v", out[1])
+	}
+	if len(out[1].Lines) != 1 || out[1].Lines[0].Label != "Error" {
+		t.Fatalf("unexpected timeout lines: %+v", out[1].Lines)
+	}
+}
+
+func TestManagerOnResultReportsFreshOutputsOnly(t *testing.T) {
+	t.Parallel()
+
+	manager, err := NewManager(Options{DataDir: t.TempDir()}, []Plugin{
+		stubPlugin{
+			id: "alpha",
+			fn: func(_ context.Context, _ *pluginruntime.Env) (QueryResult, error) {
+				return QueryResult{Lines: []MetricLine{NewTextLine("Status", "ok", TextLineOptions{})}}, nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	var seen []PluginOutput
+	manager.OnResult(func(out PluginOutput) { seen = append(seen, out) })
+
+	for i := 0; i < 2; i++ {
+		if _, err := manager.QueryOne(context.Background(), "alpha"); err != nil {
+			t.Fatalf("QueryOne error: %v", err)
+		}
+	}
+
+	if len(seen) != 1 || seen[0].ProviderID != "alpha" || seen[0].Cached {
+		t.Fatalf("unexpected observed outputs: %+v", seen)
+	}
+}
+
+type recordingMetrics struct {
+	mu      sync.Mutex
+	queries []bool
+	lookups []bool
+}
+
+func (m *recordingMetrics) ObserveQuery(_ string, _ time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queries = append(m.queries, failed)
+}
+
+func (m *recordingMetrics) ObserveCacheLookup(_ string, hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lookups = append(m.lookups, hit)
+}
+
+func TestManagerReportsQueryMetrics(t *testing.T) {
+	t.Parallel()
+
+	metrics := &recordingMetrics{}
+	fail := false
+	manager, err := NewManager(Options{DataDir: t.TempDir(), Metrics: metrics}, []Plugin{
+		stubPlugin{
+			id: "alpha",
+			fn: func(_ context.Context, _ *pluginruntime.Env) (QueryResult, error) {
+				if fail {
+					return QueryResult{}, errors.New("boom")
+				}
+				return QueryResult{Lines: []MetricLine{NewTextLine("Status", "ok", TextLineOptions{})}}, nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := manager.QueryOne(context.Background(), "alpha"); err != nil {
+			t.Fatalf("QueryOne error: %v", err)
+		}
+	}
+	fail = true
+	if _, err := manager.Refresh(context.Background(), "alpha"); err != nil {
+		t.Fatalf("Refresh error: %v", err)
+	}
+
+	if !reflect.DeepEqual(metrics.lookups, []bool{false, true}) {
+		t.Fatalf("unexpected cache lookups: %v", metrics.lookups)
+	}
+	if !reflect.DeepEqual(metrics.queries, []bool{false, true}) {
+		t.Fatalf("unexpected query outcomes: %v", metrics.queries)
+	}
+}
+
+func TestManagerPluginFiltersAndOverrides(t *testing.T) {
+	t.Parallel()
+
+	var gotCredentials, gotLogsDir string
+	var gotDeadline time.Duration
+	manager, err := NewManager(Options{
+		DataDir:           t.TempDir(),
+		PluginTimeout:     time.Second,
+		PluginTimeouts:    map[string]time.Duration{"alpha": time.Hour},
+		PluginCredentials: map[string]string{"alpha": "/srv/alpha.json"},
+		PluginLogsDirs:    map[string]string{"alpha": "/srv/alpha-logs"},
+		EnabledPlugins:    []string{"alpha", "beta"},
+		DisabledPlugins:   []string{"beta"},
+	}, []Plugin{
+		stubPlugin{
+			id: "alpha",
+			fn: func(ctx context.Context, env *pluginruntime.Env) (QueryResult, error) {
+				gotCredentials = env.CredentialsPath
+				gotLogsDir = env.LogsDir
+				deadline, _ := ctx.Deadline()
+				gotDeadline = time.Until(deadline)
+				return QueryResult{Lines: []MetricLine{NewTextLine("Status", "ok", TextLineOptions{})}}, nil
+			},
+		},
+		stubPlugin{id: "beta"},
+		stubPlugin{id: "gamma"},
+	})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	if ids := manager.PluginIDs(); !reflect.DeepEqual(ids, []string{"alpha"}) {
+		t.Fatalf("unexpected plugin IDs: %v", ids)
+	}
+	if manager.HasPlugin("gamma") {
+		t.Fatalf("expected gamma to be filtered out")
+	}
+
+	if _, err := manager.QueryOne(context.Background(), "alpha"); err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	if gotCredentials != "/srv/alpha.json" {
+		t.Fatalf("unexpected credentials path: %q", gotCredentials)
+	}
+	if gotLogsDir != "/srv/alpha-logs" {
+		t.Fatalf("unexpected logs dir: %q", gotLogsDir)
+	}
+	if gotDeadline < time.Minute {
+		t.Fatalf("expected per-plugin timeout, got deadline in %s", gotDeadline)
+	}
+}
+
+func TestManagerReportsSourceOnError(t *testing.T) {
+	t.Parallel()
+
+	manager, err := NewManager(Options{DataDir: t.TempDir()}, []Plugin{
+		stubPlugin{
+			id: "alpha",
+			fn: func(context.Context, *pluginruntime.Env) (QueryResult, error) {
+				return QueryResult{Source: "/home/me/.config/Cursor/User/globalStorage/state.vscdb"}, errors.New("not logged in")
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	output, err := manager.QueryOne(context.Background(), "alpha")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	if output.Error != "not logged in" || output.Source != "/home/me/.config/Cursor/User/globalStorage/state.vscdb" {
+		t.Fatalf("unexpected output: %+v", output)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
 )
 
 type ManifestLine struct {
@@ -25,6 +27,19 @@ type PluginManifest struct {
 	Icon          string         `json:"icon"`
 	BrandColor    string         `json:"brandColor,omitempty"`
 	Lines         []ManifestLine `json:"lines"`
+	// Permissions scope the host API for plugins that do not run as Go code.
+	Permissions pluginruntime.Permissions `json:"permissions,omitempty"`
+	// Limits bound WebAssembly plugins; zero values use the defaults.
+	Limits PluginLimits `json:"limits,omitempty"`
+}
+
+// MaxWASMMemoryMB caps PluginLimits.MemoryMB: the 4 GiB a 32-bit linear
+// memory can address.
+const MaxWASMMemoryMB = 4096
+
+type PluginLimits struct {
+	MemoryMB int   `json:"memoryMb,omitempty"`
+	CPUMs    int64 `json:"cpuMs,omitempty"`
 }
 
 type LoadedManifest struct {
@@ -0,0 +1,269 @@
+// Package metrics exports plugin usage and daemon health in the Prometheus
+// text exposition format.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DurationBuckets are the upper bounds, in seconds, of the query duration
+// histogram.
+var DurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 15, 30}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+type cacheCounts struct {
+	hits   uint64
+	misses uint64
+}
+
+// Collector implements openusage.QueryMetrics and keeps the latest
+// successful output per provider for the usage gauges.
+type Collector struct {
+	mu        sync.Mutex
+	outputs   map[string]openusage.PluginOutput
+	durations map[string]*histogram
+	errors    map[string]uint64
+	cache     map[string]*cacheCounts
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		outputs:   make(map[string]openusage.PluginOutput),
+		durations: make(map[string]*histogram),
+		errors:    make(map[string]uint64),
+		cache:     make(map[string]*cacheCounts),
+	}
+}
+
+// Record stores output for the usage gauges. Failed outputs keep the last
+// good values so dashboards do not drop to zero on transient errors.
+func (c *Collector) Record(output openusage.PluginOutput) {
+	if output.Error != "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.outputs[output.ProviderID] = output
+}
+
+func (c *Collector) ObserveQuery(id string, duration time.Duration, failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	h, ok := c.durations[id]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(DurationBuckets))}
+		c.durations[id] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range DurationBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+
+	if failed {
+		c.errors[id]++
+	} else if _, ok := c.errors[id]; !ok {
+		c.errors[id] = 0
+	}
+}
+
+func (c *Collector) ObserveCacheLookup(id string, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts, ok := c.cache[id]
+	if !ok {
+		counts = &cacheCounts{}
+		c.cache[id] = counts
+	}
+	if hit {
+		counts.hits++
+	} else {
+		counts.misses++
+	}
+}
+
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var buf bytes.Buffer
+	c.Write(&buf)
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// Write writes every metric family in text exposition format.
+func (c *Collector) Write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeUsage(w)
+	c.writeDurations(w)
+	c.writeErrors(w)
+	c.writeCache(w)
+}
+
+func (c *Collector) writeUsage(w io.Writer) {
+	type sample struct {
+		labels string
+		value  float64
+	}
+	var used, limit, resets []sample
+
+	for _, id := range sortedKeys(c.outputs) {
+		for _, line := range c.outputs[id].Lines {
+			if line.Type != openusage.LineTypeProgress || line.Used == nil || line.Limit == nil {
+				continue
+			}
+			format := ""
+			if line.Format != nil {
+				format = line.Format.Kind
+			}
+			labels := formatLabels("provider", id, "label", line.Label, "format", format)
+			used = append(used, sample{labels, *line.Used})
+			limit = append(limit, sample{labels, *line.Limit})
+
+			if line.ResetsAt != nil {
+				if t, err := time.Parse(time.RFC3339, *line.ResetsAt); err == nil {
+					resets = append(resets, sample{formatLabels("provider", id, "label", line.Label), float64(t.Unix())})
+				}
+			}
+		}
+	}
+
+	writeHeader(w, "openusage_used", "gauge", "Amount used on a progress line, in the line's format units.")
+	for _, s := range used {
+		writeSample(w, "openusage_used", s.labels, s.value)
+	}
+	writeHeader(w, "openusage_limit", "gauge", "Limit of a progress line, in the line's format units.")
+	for _, s := range limit {
+		writeSample(w, "openusage_limit", s.labels, s.value)
+	}
+	writeHeader(w, "openusage_resets_at_seconds", "gauge", "Unix time at which a progress line resets.")
+	for _, s := range resets {
+		writeSample(w, "openusage_resets_at_seconds", s.labels, s.value)
+	}
+}
+
+func (c *Collector) writeDurations(w io.Writer) {
+	const name = "openusage_plugin_query_duration_seconds"
+	writeHeader(w, name, "histogram", "Duration of upstream plugin queries.")
+	for _, id := range sortedKeys(c.durations) {
+		h := c.durations[id]
+		var cumulative uint64
+		for i, bound := range DurationBuckets {
+			cumulative += h.counts[i]
+			writeSample(w, name+"_bucket", formatLabels("provider", id, "le", formatFloat(bound)), float64(cumulative))
+		}
+		writeSample(w, name+"_bucket", formatLabels("provider", id, "le", "+Inf"), float64(h.count))
+		writeSample(w, name+"_sum", formatLabels("provider", id), h.sum)
+		writeSample(w, name+"_count", formatLabels("provider", id), float64(h.count))
+	}
+}
+
+func (c *Collector) writeErrors(w io.Writer) {
+	const name = "openusage_plugin_errors_total"
+	writeHeader(w, name, "counter", "Upstream plugin queries that returned an error.")
+	for _, id := range sortedKeys(c.errors) {
+		writeSample(w, name, formatLabels("provider", id), float64(c.errors[id]))
+	}
+}
+
+func (c *Collector) writeCache(w io.Writer) {
+	ids := sortedKeys(c.cache)
+
+	writeHeader(w, "openusage_cache_hits_total", "counter", "Reads served from the result cache.")
+	for _, id := range ids {
+		writeSample(w, "openusage_cache_hits_total", formatLabels("provider", id), float64(c.cache[id].hits))
+	}
+	writeHeader(w, "openusage_cache_misses_total", "counter", "Reads that had to wait for an upstream query.")
+	for _, id := range ids {
+		writeSample(w, "openusage_cache_misses_total", formatLabels("provider", id), float64(c.cache[id].misses))
+	}
+	writeHeader(w, "openusage_cache_hit_ratio", "gauge", "Share of reads served from the result cache since start.")
+	for _, id := range ids {
+		counts := c.cache[id]
+		ratio := float64(counts.hits) / float64(counts.hits+counts.misses)
+		writeSample(w, "openusage_cache_hit_ratio", formatLabels("provider", id), ratio)
+	}
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeSample(w io.Writer, name, labels string, value float64) {
+	_, _ = fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(value))
+}
+
+// formatLabels renders alternating name/value pairs as {name="value",...}.
+func formatLabels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+)
+
+func TestCollectorExposesUsageGauges(t *testing.T) {
+	t.Parallel()
+
+	c := NewCollector()
+	c.Record(openusage.PluginOutput{
+		ProviderID: "claude",
+		Lines: []openusage.MetricLine{
+			openusage.NewProgressLine("Session", 42, 100, openusage.PercentFormat(), openusage.ProgressLineOptions{
+				ResetsAt: "2026-03-01T10:00:00.000Z",
+			}),
+			openusage.NewProgressLine(`Extra "usage"`, 12.5, 50, openusage.DollarsFormat(), openusage.ProgressLineOptions{}),
+			openusage.NewTextLine("Plan", "Pro", openusage.TextLineOptions{}),
+		},
+	})
+	// Failed outputs keep the previous values.
+	c.Record(openusage.PluginOutput{ProviderID: "claude", Error: "boom", Lines: openusage.ErrorLines("boom")})
+
+	body := scrape(t, c)
+	for _, want := range []string{
+		"# TYPE openusage_used gauge",
+		`openusage_used{provider="claude",label="Session",format="percent"} 42`,
+		`openusage_limit{provider="claude",label="Session",format="percent"} 100`,
+		`openusage_used{provider="claude",label="Extra \"usage\"",format="dollars"} 12.5`,
+		`openusage_resets_at_seconds{provider="claude",label="Session"} 1.7723592e+09`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in output:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `label="Plan"`) {
+		t.Fatalf("text lines must not be exported:\n%s", body)
+	}
+}
+
+func TestCollectorExposesQueryAndCacheMetrics(t *testing.T) {
+	t.Parallel()
+
+	c := NewCollector()
+	c.ObserveQuery("codex", 80*time.Millisecond, false)
+	c.ObserveQuery("codex", 3*time.Second, true)
+	c.ObserveQuery("codex", time.Minute, false)
+	c.ObserveCacheLookup("codex", false)
+	c.ObserveCacheLookup("codex", true)
+	c.ObserveCacheLookup("codex", true)
+	c.ObserveCacheLookup("codex", true)
+
+	body := scrape(t, c)
+	for _, want := range []string{
+		"# TYPE openusage_plugin_query_duration_seconds histogram",
+		`openusage_plugin_query_duration_seconds_bucket{provider="codex",le="0.05"} 0`,
+		`openusage_plugin_query_duration_seconds_bucket{provider="codex",le="0.1"} 1`,
+		`openusage_plugin_query_duration_seconds_bucket{provider="codex",le="5"} 2`,
+		`openusage_plugin_query_duration_seconds_bucket{provider="codex",le="30"} 2`,
+		`openusage_plugin_query_duration_seconds_bucket{provider="codex",le="+Inf"} 3`,
+		`openusage_plugin_query_duration_seconds_count{provider="codex"} 3`,
+		`openusage_plugin_errors_total{provider="codex"} 1`,
+		`openusage_cache_hits_total{provider="codex"} 3`,
+		`openusage_cache_misses_total{provider="codex"} 1`,
+		`openusage_cache_hit_ratio{provider="codex"} 0.75`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in output:\n%s", want, body)
+		}
+	}
+}
+
+func TestCollectorRejectsPost(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	NewCollector().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+}
+
+func scrape(t *testing.T, c *Collector) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected content type: %q", ct)
+	}
+	return rec.Body.String()
+}
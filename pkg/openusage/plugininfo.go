@@ -0,0 +1,107 @@
+package openusage
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PluginInfo describes a plugin without querying it.
+type PluginInfo struct {
+	ID string `json:"id"`
+	// Provider and Account split ID as in PluginOutput.
+	Provider    string `json:"provider"`
+	Account     string `json:"account,omitempty"`
+	DisplayName string `json:"displayName"`
+	Version     string `json:"version,omitempty"`
+	IconURL     string `json:"iconUrl,omitempty"`
+	BrandColor  string `json:"brandColor,omitempty"`
+	// Lines are the manifest's line declarations.
+	Lines []ManifestLine `json:"lines"`
+	// Implemented is false for manifests without a Go plugin or a usable
+	// entry; querying them reports "Plugin implementation unavailable".
+	Implemented bool `json:"implemented"`
+	// LoadError says why a manifest entry could not be loaded.
+	LoadError string `json:"loadError,omitempty"`
+	// Enabled is false for plugins excluded by the enabled/disabled lists.
+	Enabled bool `json:"enabled"`
+	// LastQuery is the latest fresh result, nil before the first query.
+	LastQuery *PluginStatus `json:"lastQuery,omitempty"`
+}
+
+// PluginStatus summarizes a plugin's latest fresh result.
+type PluginStatus struct {
+	FetchedAt string `json:"fetchedAt"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+}
+
+func newPluginInfo(id string, loaded LoadedManifest, implemented, enabled bool) PluginInfo {
+	manifest := loaded.Manifest
+	provider, account := SplitAccountID(id)
+	info := PluginInfo{
+		ID:          id,
+		Provider:    provider,
+		Account:     account,
+		DisplayName: id,
+		Version:     manifest.Version,
+		IconURL:     loaded.IconDataURL,
+		BrandColor:  manifest.BrandColor,
+		Lines:       manifest.Lines,
+		Implemented: implemented,
+		Enabled:     enabled,
+	}
+	if manifest.Name != "" {
+		info.DisplayName = manifest.Name
+	}
+	if account != "" {
+		info.DisplayName = fmt.Sprintf("%s (%s)", info.DisplayName, account)
+	}
+	if info.Lines == nil {
+		info.Lines = []ManifestLine{}
+	}
+	return info
+}
+
+// Plugins describes every known plugin: enabled ones in PluginIDs order,
+// followed by disabled ones sorted by ID.
+func (m *Manager) Plugins() []PluginInfo {
+	infos := make([]PluginInfo, 0, len(m.order)+len(m.disabled))
+	for _, id := range m.order {
+		info, _ := m.Plugin(id)
+		infos = append(infos, info)
+	}
+
+	disabled := make([]string, 0, len(m.disabled))
+	for id := range m.disabled {
+		disabled = append(disabled, id)
+	}
+	sort.Strings(disabled)
+	for _, id := range disabled {
+		infos = append(infos, m.disabled[id])
+	}
+	return infos
+}
+
+// Plugin describes one plugin, including disabled ones.
+func (m *Manager) Plugin(id string) (PluginInfo, bool) {
+	if info, ok := m.disabled[id]; ok {
+		return info, true
+	}
+	if !m.HasPlugin(id) {
+		return PluginInfo{}, false
+	}
+
+	info := newPluginInfo(id, m.manifests[id], m.HasImplementation(id), true)
+	info.LoadError = m.loadErrors[id]
+	if name := m.accountNames[id]; name != "" {
+		info.DisplayName = name
+	}
+	if entry, ok := m.cache.get(id); ok {
+		info.LastQuery = &PluginStatus{
+			FetchedAt: entry.output.FetchedAt,
+			OK:        entry.output.Error == "",
+			Error:     entry.output.Error,
+		}
+	}
+	return info, true
+}
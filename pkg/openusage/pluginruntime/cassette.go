@@ -0,0 +1,373 @@
+package pluginruntime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secrets in recorded traffic.
+const Redacted = "REDACTED"
+
+// redactedFields are JSON keys and URL query parameters whose string values
+// are redacted, compared lower-cased without '_' and '-'.
+var redactedFields = map[string]bool{
+	"accesstoken":  true,
+	"refreshtoken": true,
+	"idtoken":      true,
+	"token":        true,
+	"apikey":       true,
+	"clientsecret": true,
+	"secret":       true,
+	"password":     true,
+	"csrf":         true,
+	"csrftoken":    true,
+}
+
+// Cassette is a sequence of recorded HTTP interactions.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one redacted request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// LoadCassette reads a cassette written by Cassette.Save.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette as indented JSON.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create cassette dir: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Recorder is an http.RoundTripper that sends requests through Transport
+// (a default transport honoring HTTPRequest.DangerouslyIgnoreTLS when nil)
+// and records every exchange with secrets redacted: credential headers,
+// token-like JSON fields and query parameters.
+type Recorder struct {
+	Transport http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := drainBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("record request body: %w", err)
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+		if ignoresTLS(req) {
+			transport = newTransport(true)
+		}
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := drainBody(&resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("record response body: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     redactURL(req.URL),
+			Headers: redactHeaders(req.Header),
+			Body:    redactBody(body),
+		},
+		Response: RecordedResponse{
+			Status:  resp.StatusCode,
+			Headers: redactHeaders(resp.Header),
+			Body:    redactBody(respBody),
+		},
+	})
+	return resp, nil
+}
+
+// Cassette returns the interactions recorded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}
+}
+
+// Replayer is an http.RoundTripper that answers from a cassette. A request
+// matches an interaction with the same method, URL and body after
+// redaction; each interaction answers once, in recorded order. Requests
+// without a match fail like an unreachable host.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+func NewReplayer(c *Cassette) *Replayer {
+	return &Replayer{
+		interactions: c.Interactions,
+		used:         make([]bool, len(c.Interactions)),
+	}
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := drainBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("replay request body: %w", err)
+	}
+	target := RecordedRequest{Method: req.Method, URL: redactURL(req.URL), Body: redactBody(body)}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.interactions {
+		if r.used[i] || !sameRequest(interaction.Request, target) {
+			continue
+		}
+		r.used[i] = true
+		return replayResponse(req, interaction.Response), nil
+	}
+	return nil, fmt.Errorf("cassette has no response for %s %s", target.Method, target.URL)
+}
+
+// Unused returns the interactions no request has matched.
+func (r *Replayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Interaction
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+func sameRequest(recorded, req RecordedRequest) bool {
+	if !strings.EqualFold(recorded.Method, req.Method) || recorded.URL != req.URL {
+		return false
+	}
+	return canonicalBody(recorded.Body) == canonicalBody(req.Body)
+}
+
+// canonicalBody re-encodes JSON and form bodies so key order and spacing do
+// not matter.
+func canonicalBody(body string) string {
+	body = redactBody([]byte(body))
+	if form, ok := parseForm(body); ok {
+		return encodeQuery(form)
+	}
+	var value any
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return strings.TrimSpace(body)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return strings.TrimSpace(body)
+	}
+	return string(data)
+}
+
+func replayResponse(req *http.Request, recorded RecordedResponse) *http.Response {
+	header := make(http.Header, len(recorded.Headers))
+	for k, v := range recorded.Headers {
+		header.Set(k, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}
+}
+
+// drainBody reads *body and replaces it with an unread copy.
+func drainBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	closeErr := (*body).Close()
+	*body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return data, closeErr
+}
+
+func isRedactedField(name string) bool {
+	name = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+	return redactedFields[name]
+}
+
+func redactHeaders(header http.Header) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(header))
+	for k, values := range header {
+		if len(values) == 0 {
+			continue
+		}
+		name := strings.ToLower(k)
+		value := values[0]
+		switch {
+		case name == "authorization", name == "proxy-authorization", name == "cookie", name == "set-cookie",
+			strings.Contains(name, "token"), strings.Contains(name, "api-key"), strings.Contains(name, "secret"):
+			value = Redacted
+		}
+		out[name] = value
+	}
+	return out
+}
+
+func redactURL(u *url.URL) string {
+	redacted := *u
+	query := redacted.Query()
+	if redactQuery(query) {
+		redacted.RawQuery = encodeQuery(query)
+	}
+	return redacted.String()
+}
+
+// redactQuery redacts in place and reports whether anything changed.
+func redactQuery(query url.Values) bool {
+	changed := false
+	for key, values := range query {
+		if isRedactedField(key) {
+			for i := range values {
+				values[i] = Redacted
+			}
+			changed = true
+		}
+	}
+	return changed
+}
+
+// parseForm parses an application/x-www-form-urlencoded body.
+func parseForm(body string) (url.Values, bool) {
+	if !strings.Contains(body, "=") || strings.ContainsAny(body, " \t\r\n{}[]\"") {
+		return nil, false
+	}
+	form, err := url.ParseQuery(body)
+	return form, err == nil
+}
+
+// encodeQuery is url.Values.Encode without escaping the redaction marker.
+func encodeQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// redactBody redacts token-like fields of a JSON or form body. Other bodies
+// are kept.
+func redactBody(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return string(body)
+	}
+	if form, ok := parseForm(string(body)); ok {
+		if redactQuery(form) {
+			return encodeQuery(form)
+		}
+		return string(body)
+	}
+	var value any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return string(body)
+	}
+	if !redactValue(value) {
+		return string(body)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return string(body)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// redactValue redacts in place and reports whether anything changed.
+func redactValue(value any) bool {
+	changed := false
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if s, ok := field.(string); ok && isRedactedField(key) && s != Redacted {
+				v[key] = Redacted
+				changed = true
+				continue
+			}
+			changed = redactValue(field) || changed
+		}
+	case []any:
+		for _, item := range v {
+			changed = redactValue(item) || changed
+		}
+	}
+	return changed
+}
@@ -0,0 +1,144 @@
+package pluginruntime
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorderRedactsSecrets(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), "rt-secret") {
+			t.Errorf("upstream did not receive the real body: %s", body)
+		}
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Header().Set("X-Usage", "42")
+		_, _ = w.Write([]byte(`{"access_token":"at-secret","expires_in":3600,"usage":{"total_tokens":12}}`))
+	}))
+	defer server.Close()
+
+	recorder := &Recorder{}
+	env := &Env{Transport: recorder}
+	resp, err := env.DoHTTPRequest(context.Background(), HTTPRequest{
+		Method:   "POST",
+		URL:      server.URL + "/oauth?api_key=k-secret&page=2",
+		Headers:  map[string]string{"Authorization": "Bearer at-old", "Content-Type": "application/json"},
+		BodyText: `{"grant_type":"refresh_token","refresh_token":"rt-secret"}`,
+	})
+	if err != nil {
+		t.Fatalf("DoHTTPRequest error: %v", err)
+	}
+	if !strings.Contains(resp.Body, "at-secret") {
+		t.Fatalf("plugin should see the real response, got %s", resp.Body)
+	}
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := recorder.Cassette().Save(path); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	text, err := ReadText(path)
+	if err != nil {
+		t.Fatalf("ReadText error: %v", err)
+	}
+	for _, secret := range []string{"rt-secret", "at-secret", "at-old", "k-secret", "session=abc"} {
+		if strings.Contains(text, secret) {
+			t.Fatalf("cassette leaks %q:\n%s", secret, text)
+		}
+	}
+	for _, kept := range []string{"page=2", `\"expires_in\":3600`, `\"total_tokens\":12`, `"x-usage": "42"`} {
+		if !strings.Contains(text, kept) {
+			t.Fatalf("cassette lost %q:\n%s", kept, text)
+		}
+	}
+}
+
+func TestRecorderHonorsIgnoreTLS(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	recorder := &Recorder{}
+	env := &Env{Transport: recorder}
+	if _, err := env.DoHTTPRequest(context.Background(), HTTPRequest{URL: server.URL}); err == nil {
+		t.Fatal("expected the self-signed certificate to be rejected")
+	}
+	resp, err := env.DoHTTPRequest(context.Background(), HTTPRequest{URL: server.URL, DangerouslyIgnoreTLS: true})
+	if err != nil {
+		t.Fatalf("DoHTTPRequest error: %v", err)
+	}
+	if resp.Status != http.StatusOK || len(recorder.Cassette().Interactions) != 1 {
+		t.Fatalf("unexpected response %d with %d recorded", resp.Status, len(recorder.Cassette().Interactions))
+	}
+}
+
+func TestReplayerServesRecordedResponses(t *testing.T) {
+	t.Parallel()
+
+	replayer := NewReplayer(&Cassette{Interactions: []Interaction{
+		{
+			Request:  RecordedRequest{Method: "GET", URL: "https://api.example.com/usage"},
+			Response: RecordedResponse{Status: 401},
+		},
+		{
+			Request:  RecordedRequest{Method: "POST", URL: "https://api.example.com/token", Body: `{"refresh_token":"REDACTED","grant_type":"refresh_token"}`},
+			Response: RecordedResponse{Status: 200, Body: `{"access_token":"REDACTED"}`},
+		},
+		{
+			Request:  RecordedRequest{Method: "GET", URL: "https://api.example.com/usage"},
+			Response: RecordedResponse{Status: 200, Headers: map[string]string{"x-used": "7"}, Body: `{"ok":true}`},
+		},
+		{
+			Request:  RecordedRequest{Method: "POST", URL: "https://auth.example.com/token", Body: "client_id=app&grant_type=refresh_token&refresh_token=REDACTED"},
+			Response: RecordedResponse{Status: 400, Body: `{"error":"invalid_grant"}`},
+		},
+		{
+			Request:  RecordedRequest{Method: "GET", URL: "https://api.example.com/unused"},
+			Response: RecordedResponse{Status: 200},
+		},
+	}})
+	env := &Env{Transport: replayer}
+	ctx := context.Background()
+
+	first, err := env.DoHTTPRequest(ctx, HTTPRequest{URL: "https://api.example.com/usage"})
+	if err != nil || first.Status != 401 {
+		t.Fatalf("expected recorded 401, got %+v, %v", first, err)
+	}
+	refresh, err := env.DoHTTPRequest(ctx, HTTPRequest{
+		Method:   "POST",
+		URL:      "https://api.example.com/token",
+		BodyText: `{"grant_type": "refresh_token", "refresh_token": "rt-live"}`,
+	})
+	if err != nil || refresh.Status != 200 {
+		t.Fatalf("expected body to match after redaction, got %+v, %v", refresh, err)
+	}
+	second, err := env.DoHTTPRequest(ctx, HTTPRequest{URL: "https://api.example.com/usage"})
+	if err != nil || second.Status != 200 || second.Headers["x-used"] != "7" || second.Body != `{"ok":true}` {
+		t.Fatalf("expected second recorded response, got %+v, %v", second, err)
+	}
+
+	form, err := env.DoHTTPRequest(ctx, HTTPRequest{
+		Method:   "POST",
+		URL:      "https://auth.example.com/token",
+		BodyText: "grant_type=refresh_token&refresh_token=rt-live&client_id=app",
+	})
+	if err != nil || form.Status != 400 {
+		t.Fatalf("expected form body to match after redaction, got %+v, %v", form, err)
+	}
+
+	if _, err := env.DoHTTPRequest(ctx, HTTPRequest{URL: "https://api.example.com/usage"}); err == nil || !strings.Contains(err.Error(), "cassette has no response for GET https://api.example.com/usage") {
+		t.Fatalf("expected exhausted cassette error, got %v", err)
+	}
+	if unused := replayer.Unused(); len(unused) != 1 || unused[0].Request.URL != "https://api.example.com/unused" {
+		t.Fatalf("unexpected unused interactions: %+v", unused)
+	}
+}
@@ -0,0 +1,209 @@
+package pluginruntime
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	credentialsFileName = "credentials.enc"
+	credentialsKeyName  = "credentials.key"
+	credentialsKeySize  = 32
+)
+
+// FileCredentialStore keeps credentials for machines without a Secret
+// Service. The file is AES-256-GCM encrypted, but the key is generated on
+// first write and stored next to it with owner-only permissions, so this is
+// obfuscated plaintext: anyone who can read the data dir can read the
+// credentials. Write logs a warning the first time it is used.
+type FileCredentialStore struct {
+	dir    string
+	mu     sync.Mutex
+	warned sync.Once
+}
+
+func NewFileCredentialStore(dir string) *FileCredentialStore {
+	return &FileCredentialStore{dir: ExpandPath(dir)}
+}
+
+func (s *FileCredentialStore) Read(service string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items, err := s.load(false)
+	if err != nil {
+		return "", err
+	}
+	value, ok := items[service]
+	if !ok {
+		return "", fmt.Errorf("credential file item %s: %w", service, ErrCredentialNotFound)
+	}
+	return value, nil
+}
+
+func (s *FileCredentialStore) Write(service, value string) error {
+	s.warned.Do(func() {
+		log.Printf("pluginruntime: no secure credential store available; keeping credentials in %s, readable by anyone who can read %s",
+			filepath.Join(s.dir, credentialsFileName), s.dir)
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items, err := s.load(true)
+	if err != nil {
+		return err
+	}
+	items[service] = value
+	return s.save(items)
+}
+
+func (s *FileCredentialStore) Delete(service string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items, err := s.load(false)
+	if err != nil {
+		return err
+	}
+	if _, ok := items[service]; !ok {
+		return fmt.Errorf("credential file item %s: %w", service, ErrCredentialNotFound)
+	}
+	delete(items, service)
+	return s.save(items)
+}
+
+// load decrypts the store. A missing file is an empty store; a missing key
+// is created only when create is set.
+func (s *FileCredentialStore) load(create bool) (map[string]string, error) {
+	items := make(map[string]string)
+
+	data, err := os.ReadFile(filepath.Join(s.dir, credentialsFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		if create {
+			if _, err := s.key(true); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read credential file: %w", err)
+	}
+
+	aead, err := s.cipher(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("credential file is truncated")
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt credential file: %w", err)
+	}
+	if err := json.Unmarshal(plain, &items); err != nil {
+		return nil, fmt.Errorf("decode credential file: %w", err)
+	}
+	return items, nil
+}
+
+func (s *FileCredentialStore) save(items map[string]string) error {
+	plain, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	aead, err := s.cipher(true)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	// Write to a temp file and rename so readers never see a partial file.
+	tmp, err := os.CreateTemp(s.dir, credentialsFileName+".*")
+	if err != nil {
+		return fmt.Errorf("write credential file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(aead.Seal(nonce, nonce, plain, nil)); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write credential file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write credential file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, credentialsFileName)); err != nil {
+		return fmt.Errorf("write credential file: %w", err)
+	}
+	return nil
+}
+
+func (s *FileCredentialStore) cipher(create bool) (cipher.AEAD, error) {
+	key, err := s.key(create)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *FileCredentialStore) key(create bool) ([]byte, error) {
+	path := filepath.Join(s.dir, credentialsKeyName)
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) != credentialsKeySize {
+			return nil, fmt.Errorf("credential key %s has invalid size", path)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) || !create {
+		return nil, fmt.Errorf("read credential key: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create credential dir: %w", err)
+	}
+	key = make([]byte, credentialsKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	// Write to a temp file and move it into place so readers never see a
+	// partial key. Link, unlike Rename, fails instead of replacing a key
+	// another process published first.
+	tmp, err := os.CreateTemp(s.dir, credentialsKeyName+".*")
+	if err != nil {
+		return nil, fmt.Errorf("create credential key: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(key); err != nil {
+		_ = tmp.Close()
+		return nil, fmt.Errorf("write credential key: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("write credential key: %w", err)
+	}
+	if err := os.Link(tmp.Name(), path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			// Another process created the key first.
+			return s.key(false)
+		}
+		return nil, fmt.Errorf("create credential key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,108 @@
+package pluginruntime
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestFileCredentialStoreRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "data")
+	store := NewFileCredentialStore(dir)
+
+	if _, err := store.Read("svc"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("expected not found on empty store, got %v", err)
+	}
+	if err := store.Write("svc", "secret-token"); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	if err := store.Write("other", "x"); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+
+	// A fresh store reads what the first one wrote.
+	got, err := NewFileCredentialStore(dir).Read("svc")
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+	if got != "secret-token" {
+		t.Fatalf("unexpected value: %s", got)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, credentialsFileName))
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if bytes.Contains(data, []byte("secret-token")) {
+		t.Fatal("credential file is not encrypted")
+	}
+	info, err := os.Stat(filepath.Join(dir, credentialsKeyName))
+	if err != nil {
+		t.Fatalf("Stat key error: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("unexpected key permissions: %v", info.Mode().Perm())
+	}
+
+	if err := store.Delete("svc"); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if _, err := store.Read("svc"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+	if got, err := store.Read("other"); err != nil || got != "x" {
+		t.Fatalf("unexpected other value: %q, %v", got, err)
+	}
+}
+
+func TestFileCredentialStoreRejectsWrongKey(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := NewFileCredentialStore(dir).Write("svc", "value"); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, credentialsKeyName), bytes.Repeat([]byte{1}, credentialsKeySize), 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	if _, err := NewFileCredentialStore(dir).Read("svc"); err == nil || errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("expected decrypt error, got %v", err)
+	}
+}
+
+func TestFileCredentialStoreCreatesOneKey(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	keys := make([][]byte, 8)
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i := range keys {
+		wg.Go(func() {
+			keys[i], errs[i] = NewFileCredentialStore(dir).key(true)
+		})
+	}
+	wg.Wait()
+
+	for i, key := range keys {
+		if errs[i] != nil {
+			t.Fatalf("key error: %v", errs[i])
+		}
+		if !bytes.Equal(key, keys[0]) {
+			t.Fatal("expected every store to share one key")
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != credentialsKeyName {
+		t.Fatalf("expected only the key file, got %v", entries)
+	}
+}
@@ -0,0 +1,72 @@
+package pluginruntime
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// EditorApp describes where a VS Code based editor keeps its user data.
+type EditorApp struct {
+	// Name is the user data directory name, e.g. "Cursor".
+	Name string
+	// FlatpakID and SnapName locate sandboxed installs on Linux.
+	FlatpakID string
+	SnapName  string
+}
+
+// StateDBCandidates lists the possible global state.vscdb locations for the
+// current platform, most likely first.
+func (a EditorApp) StateDBCandidates() []string {
+	home, _ := os.UserHomeDir()
+	return a.stateDBCandidates(runtime.GOOS, home, os.Getenv("XDG_CONFIG_HOME"), os.Getenv("APPDATA"))
+}
+
+func (a EditorApp) stateDBCandidates(goos, home, xdgConfigHome, appData string) []string {
+	var configDirs []string
+	switch goos {
+	case "darwin":
+		configDirs = append(configDirs, filepath.Join(home, "Library", "Application Support"))
+	case "windows":
+		if appData != "" {
+			configDirs = append(configDirs, appData)
+		}
+	default:
+		if xdgConfigHome != "" && filepath.IsAbs(xdgConfigHome) {
+			configDirs = append(configDirs, xdgConfigHome)
+		}
+		if defaultDir := filepath.Join(home, ".config"); defaultDir != filepath.Clean(xdgConfigHome) {
+			configDirs = append(configDirs, defaultDir)
+		}
+		if a.FlatpakID != "" {
+			configDirs = append(configDirs, filepath.Join(home, ".var", "app", a.FlatpakID, "config"))
+		}
+		if a.SnapName != "" {
+			configDirs = append(configDirs, filepath.Join(home, "snap", a.SnapName, "current", ".config"))
+		}
+	}
+
+	candidates := make([]string, 0, len(configDirs))
+	for _, dir := range configDirs {
+		candidates = append(candidates, filepath.Join(dir, a.Name, "User", "globalStorage", "state.vscdb"))
+	}
+	return candidates
+}
+
+// FindStateDB returns the first existing state database of app. When none
+// exists it returns the most likely location and false.
+func (a EditorApp) FindStateDB() (string, bool) {
+	return firstExisting(a.StateDBCandidates())
+}
+
+func firstExisting(paths []string) (string, bool) {
+	for _, path := range paths {
+		if FileExists(path) {
+			return path, true
+		}
+	}
+	if len(paths) == 0 {
+		return "", false
+	}
+	return paths[0], false
+}
@@ -0,0 +1,86 @@
+package pluginruntime
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEditorAppStateDBCandidates(t *testing.T) {
+	t.Parallel()
+
+	app := EditorApp{Name: "Windsurf", FlatpakID: "com.codeium.windsurf", SnapName: "windsurf"}
+	suffix := filepath.Join("Windsurf", "User", "globalStorage", "state.vscdb")
+
+	tests := []struct {
+		name          string
+		goos          string
+		xdgConfigHome string
+		want          []string
+	}{
+		{
+			name: "linux default",
+			goos: "linux",
+			want: []string{
+				filepath.Join("/home/me/.config", suffix),
+				filepath.Join("/home/me/.var/app/com.codeium.windsurf/config", suffix),
+				filepath.Join("/home/me/snap/windsurf/current/.config", suffix),
+			},
+		},
+		{
+			name:          "linux xdg",
+			goos:          "linux",
+			xdgConfigHome: "/data/config",
+			want: []string{
+				filepath.Join("/data/config", suffix),
+				filepath.Join("/home/me/.config", suffix),
+				filepath.Join("/home/me/.var/app/com.codeium.windsurf/config", suffix),
+				filepath.Join("/home/me/snap/windsurf/current/.config", suffix),
+			},
+		},
+		{
+			name:          "linux relative xdg is ignored",
+			goos:          "linux",
+			xdgConfigHome: "config",
+			want: []string{
+				filepath.Join("/home/me/.config", suffix),
+				filepath.Join("/home/me/.var/app/com.codeium.windsurf/config", suffix),
+				filepath.Join("/home/me/snap/windsurf/current/.config", suffix),
+			},
+		},
+		{
+			name: "darwin",
+			goos: "darwin",
+			want: []string{filepath.Join("/home/me/Library/Application Support", suffix)},
+		},
+	}
+
+	for _, tt := range tests {
+		got := app.stateDBCandidates(tt.goos, "/home/me", tt.xdgConfigHome, "")
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFirstExistingPrefersExistingPath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "a", "state.vscdb")
+	present := filepath.Join(dir, "b", "state.vscdb")
+	if err := os.MkdirAll(filepath.Dir(present), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(present, nil, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if got, ok := firstExisting([]string{missing, present}); !ok || got != present {
+		t.Fatalf("got %s ok=%v, want %s", got, ok, present)
+	}
+	if got, ok := firstExisting([]string{missing}); ok || got != missing {
+		t.Fatalf("got %s ok=%v, want fallback %s", got, ok, missing)
+	}
+}
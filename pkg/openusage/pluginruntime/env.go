@@ -1,8 +1,10 @@
 package pluginruntime
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 )
@@ -11,7 +13,22 @@ type Env struct {
 	PluginID      string
 	DataDir       string
 	PluginDataDir string
-	Logger        *log.Logger
+	// CredentialsPath overrides where the plugin reads its credentials from
+	// (a credentials file or state database, depending on the plugin).
+	CredentialsPath string
+	// LogsDir overrides where plugins that read local logs, rather than
+	// calling an API, find them.
+	LogsDir string
+	// Account is set for account instances such as "copilot@work" (to
+	// "work"). Plugins must then only use CredentialsPath, LogsDir and their own
+	// PluginDataDir, never shared sources like the keychain or a CLI login.
+	Account string
+	Logger  *log.Logger
+	// Transport, when set, carries every request made through
+	// Env.DoHTTPRequest and Env.CallLS, e.g. a Recorder or Replayer.
+	Transport http.RoundTripper
+	// LSDiscover, when set, replaces DiscoverLS for Env.DiscoverLS.
+	LSDiscover func(LSDiscoverOptions) (*LSDiscoverResult, error)
 }
 
 func DefaultDataDir() string {
@@ -26,6 +43,40 @@ func DefaultDataDir() string {
 	return filepath.Join(cfgDir, "gopenusage")
 }
 
+// CredentialsPathOr returns the configured credentials path, or fallback when
+// none is set.
+func (e *Env) CredentialsPathOr(fallback string) string {
+	if e != nil && e.CredentialsPath != "" {
+		return ExpandPath(e.CredentialsPath)
+	}
+	return fallback
+}
+
+// LogsDirOr returns the configured logs dir, or fallback when none is set.
+func (e *Env) LogsDirOr(fallback string) string {
+	if e != nil && e.LogsDir != "" {
+		return ExpandPath(e.LogsDir)
+	}
+	return fallback
+}
+
+// DoHTTPRequest sends req through the env's Transport, if any.
+func (e *Env) DoHTTPRequest(ctx context.Context, req HTTPRequest) (HTTPResponse, error) {
+	if e != nil && req.Transport == nil {
+		req.Transport = e.Transport
+	}
+	return DoHTTPRequest(ctx, req)
+}
+
+// DiscoverLS finds a running language server through the env's LSDiscover,
+// if any.
+func (e *Env) DiscoverLS(opts LSDiscoverOptions) (*LSDiscoverResult, error) {
+	if e != nil && e.LSDiscover != nil {
+		return e.LSDiscover(opts)
+	}
+	return DiscoverLS(opts)
+}
+
 func NewEnv(pluginID, dataDir string) (*Env, error) {
 	if pluginID == "" {
 		return nil, fmt.Errorf("plugin id is required")
@@ -0,0 +1,256 @@
+package pluginruntime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrPermissionDenied is returned for host calls outside a plugin's
+	// declared permissions.
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrUnknownHostMethod is returned for host calls that do not exist.
+	ErrUnknownHostMethod = errors.New("unknown host method")
+)
+
+// Permissions scope what a sandboxed plugin may reach through a Host.
+type Permissions struct {
+	// HTTP lists hosts the plugin may request. "*.example.com" matches the
+	// domain and its subdomains; "*" matches any host.
+	HTTP []string `json:"http,omitempty"`
+	// Files lists paths the plugin may read, as filepath.Match patterns with
+	// ~ expanded. A pattern ending in "/" allows everything below it.
+	Files []string `json:"files,omitempty"`
+	// SQLite lists databases the plugin may query, as patterns like Files.
+	SQLite []string `json:"sqlite,omitempty"`
+}
+
+// Host exposes Env capabilities to plugins that run outside the Go process
+// (or inside an interpreter), gated by their Permissions. The plugin data
+// dir and the configured credentials path are always readable.
+type Host struct {
+	Env         *Env
+	Permissions Permissions
+}
+
+// Call dispatches one host method with JSON params and returns a value to be
+// encoded as the JSON result.
+func (h *Host) Call(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "http.request":
+		var p struct {
+			Method    string            `json:"method"`
+			URL       string            `json:"url"`
+			Headers   map[string]string `json:"headers"`
+			BodyText  string            `json:"bodyText"`
+			TimeoutMs int64             `json:"timeoutMs"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if err := h.checkURL(p.URL); err != nil {
+			return nil, err
+		}
+		return h.Env.DoHTTPRequest(ctx, HTTPRequest{
+			Method:   p.Method,
+			URL:      p.URL,
+			Headers:  p.Headers,
+			BodyText: p.BodyText,
+			Timeout:  time.Duration(p.TimeoutMs) * time.Millisecond,
+		})
+
+	case "fs.exists", "fs.readText":
+		var p struct {
+			Path string `json:"path"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		path, err := h.checkPath(p.Path, h.Permissions.Files)
+		if err != nil {
+			return nil, err
+		}
+		if method == "fs.exists" {
+			return FileExists(path), nil
+		}
+		return ReadText(path)
+
+	case "sqlite.query":
+		var p struct {
+			Path string `json:"path"`
+			SQL  string `json:"sql"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		path, err := h.checkPath(p.Path, h.Permissions.SQLite)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := SQLiteQuery(path, p.SQL)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(rows), nil
+
+	case "state.read", "state.write":
+		var p struct {
+			Name string `json:"name"`
+			Text string `json:"text"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if !filepath.IsLocal(p.Name) {
+			return nil, fmt.Errorf("%w: state name %q", ErrPermissionDenied, p.Name)
+		}
+		path := filepath.Join(h.Env.PluginDataDir, p.Name)
+		if method == "state.write" {
+			return nil, WriteText(path, p.Text)
+		}
+		text, err := ReadText(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return text, err
+
+	case "log":
+		var p struct {
+			Level   string `json:"level"`
+			Message string `json:"message"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if h.Env.Logger != nil {
+			if p.Level != "" && p.Level != "info" {
+				h.Env.Logger.Printf("%s: %s", p.Level, p.Message)
+			} else {
+				h.Env.Logger.Print(p.Message)
+			}
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownHostMethod, method)
+}
+
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	return nil
+}
+
+func (h *Host) checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q", raw)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range h.Permissions.HTTP {
+		allowed = strings.ToLower(allowed)
+		switch {
+		case allowed == "*", allowed == host:
+			return nil
+		case strings.HasPrefix(allowed, "*.") && (host == allowed[2:] || strings.HasSuffix(host, allowed[1:])):
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: http host %s", ErrPermissionDenied, host)
+}
+
+// checkPath expands path, resolves its symlinks and reports whether the
+// result lies within one of patterns, the plugin data dir or the credentials
+// path, each resolved the same way. Paths that cannot be resolved, such as
+// dangling symlinks, are denied.
+func (h *Host) checkPath(path string, patterns []string) (string, error) {
+	path = filepath.Clean(ExpandPath(path))
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("%w: path %q is not absolute", ErrPermissionDenied, path)
+	}
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: path %s: %w", ErrPermissionDenied, path, err)
+	}
+	if within(resolveRoot(h.Env.PluginDataDir), resolved) {
+		return resolved, nil
+	}
+	if creds := h.Env.CredentialsPathOr(""); creds != "" && within(resolveRoot(creds), resolved) {
+		return resolved, nil
+	}
+	for _, pattern := range patterns {
+		expanded := filepath.Clean(ExpandPath(pattern))
+		if strings.HasSuffix(pattern, "/") {
+			if within(resolveRoot(expanded), resolved) {
+				return resolved, nil
+			}
+			continue
+		}
+		if ok, _ := filepath.Match(resolvePattern(expanded), resolved); ok {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("%w: path %s", ErrPermissionDenied, path)
+}
+
+// resolvePath resolves the symlinks of path. A path that does not exist
+// resolves below its deepest existing parent, so it can still be checked.
+func resolvePath(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return resolved, err
+	}
+	if _, lerr := os.Lstat(path); !errors.Is(lerr, os.ErrNotExist) {
+		return "", err
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return "", err
+	}
+	dir, err := resolvePath(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(path)), nil
+}
+
+// resolveRoot resolves the symlinks of a permitted path. Roots that do not
+// exist yet are kept as they are; nothing below them exists either.
+func resolveRoot(path string) string {
+	if path == "" {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
+
+// resolvePattern resolves the directory of a filepath.Match pattern when it
+// holds no wildcards.
+func resolvePattern(pattern string) string {
+	dir, name := filepath.Split(pattern)
+	if strings.ContainsAny(dir, `*?[\`) {
+		return pattern
+	}
+	return filepath.Join(resolveRoot(dir), name)
+}
+
+// within reports whether path is dir or below it.
+func within(dir, path string) bool {
+	if dir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && filepath.IsLocal(rel)
+}
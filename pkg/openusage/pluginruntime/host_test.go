@@ -0,0 +1,192 @@
+package pluginruntime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHostScopesFileReads(t *testing.T) {
+	t.Parallel()
+
+	env, err := NewEnv("ext", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEnv error: %v", err)
+	}
+	shared := t.TempDir()
+	allowed := filepath.Join(shared, "app", "state.json")
+	if err := WriteText(allowed, `{"ok":true}`); err != nil {
+		t.Fatalf("WriteText error: %v", err)
+	}
+	secret := filepath.Join(shared, "secret.txt")
+	if err := os.WriteFile(secret, []byte("nope"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+
+	host := &Host{Env: env, Permissions: Permissions{Files: []string{filepath.Join(shared, "app") + "/"}}}
+	call := func(method string, params any) (any, error) {
+		raw, _ := json.Marshal(params)
+		return host.Call(context.Background(), method, raw)
+	}
+
+	if text, err := call("fs.readText", map[string]string{"path": allowed}); err != nil || text != `{"ok":true}` {
+		t.Fatalf("expected allowed read, got %v, %v", text, err)
+	}
+	for _, path := range []string{secret, filepath.Join(shared, "app", "..", "secret.txt"), "relative.txt"} {
+		if _, err := call("fs.readText", map[string]string{"path": path}); !errors.Is(err, ErrPermissionDenied) {
+			t.Fatalf("expected permission denied for %s, got %v", path, err)
+		}
+	}
+
+	if _, err := call("state.write", map[string]string{"name": "cursor.json", "text": "1"}); err != nil {
+		t.Fatalf("state.write error: %v", err)
+	}
+	if text, err := call("fs.readText", map[string]string{"path": filepath.Join(env.PluginDataDir, "cursor.json")}); err != nil || text != "1" {
+		t.Fatalf("expected plugin data dir to be readable, got %v, %v", text, err)
+	}
+	if _, err := call("state.write", map[string]string{"name": "../escape", "text": "1"}); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("expected permission denied for state escape, got %v", err)
+	}
+	if value, err := call("state.read", map[string]string{"name": "missing"}); err != nil || value != nil {
+		t.Fatalf("expected nil for missing state, got %v, %v", value, err)
+	}
+
+	if _, err := call("process.exec", nil); !errors.Is(err, ErrUnknownHostMethod) {
+		t.Fatalf("expected unknown method error, got %v", err)
+	}
+}
+
+func TestHostRejectsSymlinkEscapes(t *testing.T) {
+	t.Parallel()
+
+	env, err := NewEnv("ext", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEnv error: %v", err)
+	}
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(outside, []byte("nope"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	declared := t.TempDir()
+	realDir := filepath.Join(t.TempDir(), "realDir")
+	if err := WriteText(filepath.Join(realDir, "state.json"), "1"); err != nil {
+		t.Fatalf("WriteText error: %v", err)
+	}
+	links := map[string]string{
+		filepath.Join(declared, "escape"):           outside,
+		filepath.Join(declared, "dangling"):         filepath.Join(t.TempDir(), "later.txt"),
+		filepath.Join(env.PluginDataDir, "escape"):  outside,
+		filepath.Join(declared, "linked"):           realDir,
+		filepath.Join(declared, "linked-file.json"): filepath.Join(realDir, "state.json"),
+	}
+	if err := os.MkdirAll(env.PluginDataDir, 0o755); err != nil {
+		t.Fatalf("create data dir: %v", err)
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks unavailable: %v", err)
+		}
+	}
+
+	host := &Host{Env: env, Permissions: Permissions{
+		Files:  []string{declared + "/", realDir + "/"},
+		SQLite: []string{filepath.Join(declared, "*.json")},
+	}}
+	call := func(method, path string) (any, error) {
+		raw, _ := json.Marshal(map[string]string{"path": path})
+		return host.Call(context.Background(), method, raw)
+	}
+
+	for _, path := range []string{
+		filepath.Join(declared, "escape"),
+		filepath.Join(declared, "dangling"),
+		filepath.Join(env.PluginDataDir, "escape"),
+	} {
+		if _, err := call("fs.readText", path); !errors.Is(err, ErrPermissionDenied) {
+			t.Fatalf("expected permission denied for %s, got %v", path, err)
+		}
+		if _, err := call("fs.exists", path); !errors.Is(err, ErrPermissionDenied) {
+			t.Fatalf("expected permission denied for exists %s, got %v", path, err)
+		}
+	}
+	if _, err := call("sqlite.query", filepath.Join(declared, "linked-file.json")); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("expected pattern match to use the resolved path, got %v", err)
+	}
+
+	if text, err := call("fs.readText", filepath.Join(declared, "linked", "state.json")); err != nil || text != "1" {
+		t.Fatalf("expected link into another declared dir to be readable, got %v, %v", text, err)
+	}
+	if exists, err := call("fs.exists", filepath.Join(declared, "missing", "file.json")); err != nil || exists != false {
+		t.Fatalf("expected missing declared path to not exist, got %v, %v", exists, err)
+	}
+}
+
+func TestHostSQLiteCannotAttachOtherDatabases(t *testing.T) {
+	t.Parallel()
+
+	env, err := NewEnv("ext", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEnv error: %v", err)
+	}
+	newDB := func(path, value string) {
+		t.Helper()
+		if err := WriteText(path, ""); err != nil {
+			t.Fatalf("create db file: %v", err)
+		}
+		if err := SQLiteExec(path, "CREATE TABLE t (v TEXT); INSERT INTO t (v) VALUES ('"+value+"')"); err != nil {
+			t.Fatalf("seed db: %v", err)
+		}
+	}
+	allowed := filepath.Join(t.TempDir(), "allowed.db")
+	other := filepath.Join(t.TempDir(), "other.db")
+	newDB(allowed, "public")
+	newDB(other, "secret")
+
+	host := &Host{Env: env, Permissions: Permissions{SQLite: []string{allowed}}}
+	query := func(sql string) (any, error) {
+		raw, _ := json.Marshal(map[string]string{"path": allowed, "sql": sql})
+		return host.Call(context.Background(), "sqlite.query", raw)
+	}
+
+	if rows, err := query("SELECT v FROM t"); err != nil || !strings.Contains(fmt.Sprint(rows), "public") {
+		t.Fatalf("expected allowed query, got %v, %v", rows, err)
+	}
+	for _, sql := range []string{
+		"ATTACH DATABASE '" + other + "' AS x; SELECT v FROM x.t",
+		"SELECT v FROM t; ATTACH DATABASE '" + other + "' AS x",
+		"/* ; */ ATTACH DATABASE '" + other + "' AS x",
+		"PRAGMA table_info(t)",
+		"DETACH DATABASE main",
+	} {
+		rows, err := query(sql)
+		if err == nil || strings.Contains(fmt.Sprint(rows), "secret") {
+			t.Fatalf("expected %q to be rejected, got %v, %v", sql, rows, err)
+		}
+	}
+}
+
+func TestHostScopesHTTP(t *testing.T) {
+	t.Parallel()
+
+	host := &Host{Env: &Env{}, Permissions: Permissions{HTTP: []string{"api.example.com", "*.example.org"}}}
+	cases := map[string]bool{
+		"https://api.example.com/v1":   true,
+		"https://API.example.com:8443": true,
+		"https://example.org/":         true,
+		"https://a.b.example.org/":     true,
+		"https://example.com/":         false,
+		"https://evilexample.org/":     false,
+		"file:///etc/passwd":           false,
+	}
+	for url, want := range cases {
+		err := host.checkURL(url)
+		if (err == nil) != want {
+			t.Fatalf("checkURL(%s) = %v, want allowed=%v", url, err, want)
+		}
+	}
+}
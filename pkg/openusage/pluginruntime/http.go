@@ -19,6 +19,28 @@ type HTTPRequest struct {
 	BodyText             string
 	Timeout              time.Duration
 	DangerouslyIgnoreTLS bool
+	// Transport replaces the default transport, e.g. with a Replayer.
+	// DangerouslyIgnoreTLS only applies to the default and to a Recorder
+	// sending through it.
+	Transport http.RoundTripper
+}
+
+type ignoreTLSKey struct{}
+
+// newTransport returns a clone of http.DefaultTransport that skips
+// certificate verification when ignoreTLS is set.
+func newTransport(ignoreTLS bool) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if ignoreTLS {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
+	}
+	return transport
+}
+
+// ignoresTLS reports whether req was sent with DangerouslyIgnoreTLS.
+func ignoresTLS(req *http.Request) bool {
+	ignore, _ := req.Context().Value(ignoreTLSKey{}).(bool)
+	return ignore
 }
 
 type HTTPResponse struct {
@@ -38,9 +60,12 @@ func DoHTTPRequest(ctx context.Context, req HTTPRequest) (HTTPResponse, error) {
 		timeout = 10 * time.Second
 	}
 
-	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport := req.Transport
+	if transport == nil {
+		transport = newTransport(req.DangerouslyIgnoreTLS)
+	}
 	if req.DangerouslyIgnoreTLS {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
+		ctx = context.WithValue(ctx, ignoreTLSKey{}, true)
 	}
 
 	client := &http.Client{
@@ -1,37 +1,174 @@
 package pluginruntime
 
 import (
+	"errors"
 	"fmt"
 	"os/exec"
 	"regexp"
 	"runtime"
 	"strings"
+	"sync"
 )
 
-var acctPattern = regexp.MustCompile(`"acct"<blob>="([^"]+)"`)
+var (
+	// ErrCredentialNotFound is returned when a store has no item for a service.
+	ErrCredentialNotFound = errors.New("credential not found")
+	// ErrCredentialStoreUnavailable is returned when a store's backend cannot
+	// be reached, e.g. no Secret Service on the session bus.
+	ErrCredentialStoreUnavailable = errors.New("credential store unavailable")
+)
+
+// CredentialStore keeps secrets keyed by service name.
+type CredentialStore interface {
+	Read(service string) (string, error)
+	Write(service, value string) error
+	Delete(service string) error
+}
+
+var (
+	storeMu sync.Mutex
+	store   CredentialStore
+	// platformStores holds the platform store per data dir.
+	platformStores = make(map[string]CredentialStore)
+)
+
+// DefaultCredentialStore returns the store used by the keychain helpers: the
+// macOS keychain on darwin, elsewhere the Secret Service with an encrypted
+// file in DefaultDataDir as fallback.
+func DefaultCredentialStore() CredentialStore {
+	return credentialStoreFor(DefaultDataDir())
+}
+
+// SetCredentialStore replaces the store used by the keychain helpers; nil
+// restores the platform default.
+func SetCredentialStore(s CredentialStore) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	store = s
+}
+
+// CredentialStore returns the store used by the env's keychain helpers, like
+// DefaultCredentialStore but with the encrypted file fallback in DataDir.
+func (e *Env) CredentialStore() CredentialStore {
+	if e == nil || e.DataDir == "" {
+		return DefaultCredentialStore()
+	}
+	return credentialStoreFor(e.DataDir)
+}
+
+func credentialStoreFor(dataDir string) CredentialStore {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	if store != nil {
+		return store
+	}
+	dataDir = ExpandPath(dataDir)
+	s, ok := platformStores[dataDir]
+	if !ok {
+		s = newPlatformCredentialStore(dataDir)
+		platformStores[dataDir] = s
+	}
+	return s
+}
+
+func newPlatformCredentialStore(dataDir string) CredentialStore {
+	if runtime.GOOS == "darwin" {
+		return macKeychain{}
+	}
+	return &FallbackCredentialStore{
+		Primary:  NewSecretServiceStore(),
+		Fallback: NewFileCredentialStore(dataDir),
+	}
+}
 
 func ReadKeychainGenericPassword(service string) (string, error) {
-	if runtime.GOOS != "darwin" {
-		return "", fmt.Errorf("keychain API is only supported on macOS")
+	return (*Env)(nil).ReadKeychainGenericPassword(service)
+}
+
+func WriteKeychainGenericPassword(service, value string) error {
+	return (*Env)(nil).WriteKeychainGenericPassword(service, value)
+}
+
+func DeleteKeychainGenericPassword(service string) error {
+	return (*Env)(nil).DeleteKeychainGenericPassword(service)
+}
+
+// ReadKeychainGenericPassword is ReadKeychainGenericPassword reading from the
+// env's CredentialStore.
+func (e *Env) ReadKeychainGenericPassword(service string) (string, error) {
+	return e.CredentialStore().Read(service)
+}
+
+// WriteKeychainGenericPassword is WriteKeychainGenericPassword writing to the
+// env's CredentialStore.
+func (e *Env) WriteKeychainGenericPassword(service, value string) error {
+	return e.CredentialStore().Write(service, value)
+}
+
+// DeleteKeychainGenericPassword is DeleteKeychainGenericPassword deleting from
+// the env's CredentialStore.
+func (e *Env) DeleteKeychainGenericPassword(service string) error {
+	return e.CredentialStore().Delete(service)
+}
+
+// FallbackCredentialStore uses Primary and switches to Fallback while Primary
+// is unavailable. Reads also consult Fallback when Primary has no item, so
+// values written during an outage stay visible.
+type FallbackCredentialStore struct {
+	Primary  CredentialStore
+	Fallback CredentialStore
+}
+
+func (s *FallbackCredentialStore) Read(service string) (string, error) {
+	value, err := s.Primary.Read(service)
+	if err == nil {
+		return value, nil
+	}
+	if !errors.Is(err, ErrCredentialStoreUnavailable) && !errors.Is(err, ErrCredentialNotFound) {
+		return "", err
 	}
+	if value, fallbackErr := s.Fallback.Read(service); fallbackErr == nil {
+		return value, nil
+	}
+	return "", err
+}
+
+func (s *FallbackCredentialStore) Write(service, value string) error {
+	err := s.Primary.Write(service, value)
+	if errors.Is(err, ErrCredentialStoreUnavailable) {
+		return s.Fallback.Write(service, value)
+	}
+	return err
+}
+
+func (s *FallbackCredentialStore) Delete(service string) error {
+	primaryErr := s.Primary.Delete(service)
+	fallbackErr := s.Fallback.Delete(service)
+	switch {
+	case primaryErr == nil || fallbackErr == nil:
+		return nil
+	case errors.Is(primaryErr, ErrCredentialStoreUnavailable):
+		return fallbackErr
+	default:
+		return primaryErr
+	}
+}
 
+var acctPattern = regexp.MustCompile(`"acct"<blob>="([^"]+)"`)
+
+// macKeychain shells out to security(1).
+type macKeychain struct{}
+
+func (macKeychain) Read(service string) (string, error) {
 	output, err := exec.Command("security", "find-generic-password", "-s", service, "-w").CombinedOutput()
 	if err != nil {
-		line := strings.TrimSpace(firstLine(string(output)))
-		if line == "" {
-			line = err.Error()
-		}
-		return "", fmt.Errorf("keychain item not found: %s", line)
+		return "", fmt.Errorf("keychain item not found: %s: %w", commandError(output, err), ErrCredentialNotFound)
 	}
 
 	return strings.TrimSpace(string(output)), nil
 }
 
-func WriteKeychainGenericPassword(service, value string) error {
-	if runtime.GOOS != "darwin" {
-		return fmt.Errorf("keychain API is only supported on macOS")
-	}
-
+func (macKeychain) Write(service, value string) error {
 	account := ""
 	findOut, err := exec.Command("security", "find-generic-password", "-s", service).CombinedOutput()
 	if err == nil {
@@ -48,32 +185,28 @@ func WriteKeychainGenericPassword(service, value string) error {
 
 	output, err := exec.Command("security", args...).CombinedOutput()
 	if err != nil {
-		line := strings.TrimSpace(firstLine(string(output)))
-		if line == "" {
-			line = err.Error()
-		}
-		return fmt.Errorf("keychain write failed: %s", line)
+		return fmt.Errorf("keychain write failed: %s", commandError(output, err))
 	}
 
 	return nil
 }
 
-func DeleteKeychainGenericPassword(service string) error {
-	if runtime.GOOS != "darwin" {
-		return fmt.Errorf("keychain API is only supported on macOS")
-	}
-
+func (macKeychain) Delete(service string) error {
 	output, err := exec.Command("security", "delete-generic-password", "-s", service).CombinedOutput()
 	if err != nil {
-		line := strings.TrimSpace(firstLine(string(output)))
-		if line == "" {
-			line = err.Error()
-		}
-		return fmt.Errorf("keychain delete failed: %s", line)
+		return fmt.Errorf("keychain delete failed: %s", commandError(output, err))
 	}
 	return nil
 }
 
+func commandError(output []byte, err error) string {
+	line := strings.TrimSpace(firstLine(string(output)))
+	if line == "" {
+		line = err.Error()
+	}
+	return line
+}
+
 func firstLine(s string) string {
 	for _, line := range strings.Split(s, "\n") {
 		line = strings.TrimSpace(line)
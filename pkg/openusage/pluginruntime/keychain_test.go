@@ -0,0 +1,27 @@
+package pluginruntime
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestEnvCredentialStoreUsesDataDir(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "darwin" {
+		t.Skip("the macOS keychain has no file fallback")
+	}
+
+	dataDir := t.TempDir()
+	env := &Env{DataDir: dataDir}
+	store, ok := env.CredentialStore().(*FallbackCredentialStore)
+	if !ok {
+		t.Fatalf("unexpected store: %T", env.CredentialStore())
+	}
+	if file, ok := store.Fallback.(*FileCredentialStore); !ok || file.dir != dataDir {
+		t.Fatalf("expected the file fallback in %s, got %+v", dataDir, store.Fallback)
+	}
+	if env.CredentialStore() != CredentialStore(store) {
+		t.Fatal("expected the store to be reused for the same data dir")
+	}
+}
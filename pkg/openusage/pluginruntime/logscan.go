@@ -0,0 +1,303 @@
+package pluginruntime
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxLogLine bounds a single log line; longer lines are skipped.
+const maxLogLine = 16 << 20
+
+// ReadAppendedLines calls fn for every complete line written to path after
+// offset and returns the offset to resume from. A trailing line without a
+// newline is left for the next call, and a file that shrank below offset
+// (rotated or rewritten) is read from the start.
+func ReadAppendedLines(path string, offset int64, fn func(line []byte)) (int64, error) {
+	f, err := os.Open(ExpandPath(path))
+	if err != nil {
+		return offset, err
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return offset, err
+	}
+	if info.Size() < offset {
+		offset = 0
+	}
+	if info.Size() == offset {
+		return offset, nil
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+
+	reader := bufio.NewReaderSize(f, 64<<10)
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		switch {
+		case err == nil:
+			offset += int64(len(chunk))
+			if line := bytes.TrimSpace(chunk); len(line) > 0 {
+				fn(line)
+			}
+		case errors.Is(err, bufio.ErrBufferFull):
+			// Lines longer than the buffer are rare; assemble them unless
+			// they exceed maxLogLine.
+			line, rest, readErr := readLongLine(reader, chunk)
+			if readErr != nil {
+				return offset, nil
+			}
+			offset += rest
+			if line != nil {
+				if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+					fn(trimmed)
+				}
+			}
+		case errors.Is(err, io.EOF):
+			return offset, nil
+		default:
+			return offset, err
+		}
+	}
+}
+
+// readLongLine reads the remainder of a line whose first part is chunk. It
+// returns the number of bytes consumed and nil for lines over maxLogLine.
+func readLongLine(reader *bufio.Reader, chunk []byte) ([]byte, int64, error) {
+	line := append([]byte(nil), chunk...)
+	consumed := int64(len(chunk))
+	for {
+		more, err := reader.ReadSlice('\n')
+		consumed += int64(len(more))
+		if len(line) <= maxLogLine {
+			line = append(line, more...)
+		}
+		switch {
+		case err == nil:
+			if len(line) > maxLogLine {
+				return nil, consumed, nil
+			}
+			return line, consumed, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		default:
+			return nil, 0, err
+		}
+	}
+}
+
+// ReadJSONFile decodes path into v. A missing file leaves v unchanged and
+// returns os.ErrNotExist.
+func ReadJSONFile(path string, v any) error {
+	data, err := os.ReadFile(ExpandPath(path))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteJSONFile encodes v to path, replacing it atomically.
+func WriteJSONFile(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	expanded := ExpandPath(path)
+	tmp := expanded + ".tmp"
+	if err := WriteText(tmp, string(data)); err != nil {
+		return err
+	}
+	return os.Rename(tmp, expanded)
+}
+
+// LogUsage is a usage total that LogScan buckets add up.
+type LogUsage[U any] interface {
+	Plus(U) U
+}
+
+// LogBucket totals usage for one key, such as a model, within one hour.
+type LogBucket[K comparable, U any] struct {
+	Hour  int64 `json:"hour"` // unix seconds, truncated to the hour
+	Key   K     `json:"key"`
+	Usage U     `json:"usage"`
+}
+
+// LogFile is where scanning one log file stopped. Cursor holds whatever the
+// plugin needs to attribute the lines that follow.
+type LogFile[C any] struct {
+	Offset int64 `json:"offset"`
+	Cursor C     `json:"cursor"`
+}
+
+// LogScan is the state of an incremental scan of local log files. Plugins
+// persist it in their data dir between queries so only appended lines are
+// parsed; usage is totaled in hourly buckets, and everything before the
+// cutoff passed to Update is dropped.
+type LogScan[K comparable, U LogUsage[U], C any] struct {
+	Version int                    `json:"version"`
+	Files   map[string]*LogFile[C] `json:"files"`
+	Buckets []*LogBucket[K, U]     `json:"buckets"`
+	// Seen maps dedupe keys to the hour they were counted in.
+	Seen map[string]int64 `json:"seen,omitempty"`
+
+	index map[logBucketID[K]]*LogBucket[K, U]
+}
+
+type logBucketID[K comparable] struct {
+	hour int64
+	key  K
+}
+
+// Restore prepares a decoded or zero LogScan for use. State saved with
+// another version is discarded; Restore reports whether it was kept.
+func (s *LogScan[K, U, C]) Restore(version int) bool {
+	kept := s.Version == version
+	if !kept {
+		*s = LogScan[K, U, C]{Version: version}
+	}
+	if s.Files == nil {
+		s.Files = make(map[string]*LogFile[C])
+	}
+	if s.Seen == nil {
+		s.Seen = make(map[string]int64)
+	}
+	s.index = make(map[logBucketID[K]]*LogBucket[K, U], len(s.Buckets))
+	for _, b := range s.Buckets {
+		s.index[logBucketID[K]{b.Hour, b.Key}] = b
+	}
+	return kept
+}
+
+// Add counts u for key in the hour containing at.
+func (s *LogScan[K, U, C]) Add(at time.Time, key K, u U) {
+	id := logBucketID[K]{at.Truncate(time.Hour).Unix(), key}
+	b, ok := s.index[id]
+	if !ok {
+		b = &LogBucket[K, U]{Hour: id.hour, Key: key}
+		s.index[id] = b
+		s.Buckets = append(s.Buckets, b)
+	}
+	b.Usage = b.Usage.Plus(u)
+}
+
+// Counted reports whether key was recorded before and records it for the
+// hour containing at, e.g. to skip messages a resumed session repeats.
+func (s *LogScan[K, U, C]) Counted(key string, at time.Time) bool {
+	if _, ok := s.Seen[key]; ok {
+		return true
+	}
+	s.Seen[key] = at.Truncate(time.Hour).Unix()
+	return false
+}
+
+// SumSince totals the buckets from the hour containing start.
+func (s *LogScan[K, U, C]) SumSince(start time.Time) U {
+	var total U
+	limit := start.Truncate(time.Hour).Unix()
+	for _, b := range s.Buckets {
+		if b.Hour >= limit {
+			total = total.Plus(b.Usage)
+		}
+	}
+	return total
+}
+
+// Update calls fn for every line appended since the last call to the files
+// below root that match accepts, with the cursor of the line's file. Files
+// untouched since cutoff are not read until they change, then from where
+// reading stopped, so cursors still see every line. Files that shrank are
+// read again from the start with a zero cursor, and files that disappeared
+// are forgotten. Buckets and dedupe keys before cutoff are pruned.
+func (s *LogScan[K, U, C]) Update(ctx context.Context, root string, cutoff time.Time, accept func(path string) bool, fn func(path string, cursor *C, line []byte)) error {
+	present := make(map[string]struct{})
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrPermission) {
+				return nil
+			}
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if d.IsDir() || !accept(path) {
+			return nil
+		}
+		present[path] = struct{}{}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		file, ok := s.Files[path]
+		if !ok {
+			file = &LogFile[C]{}
+			s.Files[path] = file
+		}
+		if info.Size() == file.Offset {
+			return nil
+		}
+		if info.ModTime().Before(cutoff) {
+			// Nothing in a file untouched since the cutoff can count yet.
+			// Its offset stays put: lines appended later may depend on
+			// what the skipped lines set in the cursor.
+			return nil
+		}
+		if info.Size() < file.Offset {
+			*file = LogFile[C]{}
+		}
+
+		next, err := ReadAppendedLines(path, file.Offset, func(line []byte) {
+			fn(path, &file.Cursor, line)
+		})
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		file.Offset = next
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for path := range s.Files {
+		if _, ok := present[path]; !ok {
+			delete(s.Files, path)
+		}
+	}
+	s.prune(cutoff)
+	return nil
+}
+
+func (s *LogScan[K, U, C]) prune(cutoff time.Time) {
+	limit := cutoff.Truncate(time.Hour).Unix()
+	kept := s.Buckets[:0]
+	for _, b := range s.Buckets {
+		if b.Hour >= limit {
+			kept = append(kept, b)
+		} else {
+			delete(s.index, logBucketID[K]{b.Hour, b.Key})
+		}
+	}
+	s.Buckets = kept
+	for key, hour := range s.Seen {
+		if hour < limit {
+			delete(s.Seen, key)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package pluginruntime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadAppendedLines(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	if err := os.WriteFile(path, []byte("one\n\ntwo\nthr"), 0o644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	var got []string
+	collect := func(line []byte) { got = append(got, string(line)) }
+
+	offset, err := ReadAppendedLines(path, 0, collect)
+	if err != nil {
+		t.Fatalf("ReadAppendedLines error: %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{"one", "two"}) || offset != 9 {
+		t.Fatalf("unexpected first read: %v offset=%d", got, offset)
+	}
+
+	// The partial line is completed and a long line spans several buffers.
+	long := strings.Repeat("x", 200<<10)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile error: %v", err)
+	}
+	_, _ = f.WriteString("ee\n" + long + "\n")
+	_ = f.Close()
+
+	got = nil
+	offset, err = ReadAppendedLines(path, offset, collect)
+	if err != nil {
+		t.Fatalf("ReadAppendedLines error: %v", err)
+	}
+	if len(got) != 2 || got[0] != "three" || got[1] != long {
+		t.Fatalf("unexpected second read: %d lines", len(got))
+	}
+
+	// A rewritten, smaller file is read from the start.
+	if err := os.WriteFile(path, []byte("new\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	got = nil
+	if offset, err = ReadAppendedLines(path, offset, collect); err != nil || offset != 4 || !reflect.DeepEqual(got, []string{"new"}) {
+		t.Fatalf("unexpected read after truncation: %v offset=%d err=%v", got, offset, err)
+	}
+}
+
+type testUsage int64
+
+func (u testUsage) Plus(o testUsage) testUsage { return u + o }
+
+func TestLogScanUpdate(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	now := time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+	cutoff := now.Add(-24 * time.Hour)
+	write := func(name, text string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte(text), 0o644); err != nil {
+			t.Fatalf("WriteFile error: %v", err)
+		}
+	}
+	// Lines are "<hours ago> <key> <count>"; a key seen before is skipped.
+	update := func(s *LogScan[string, testUsage, int]) {
+		t.Helper()
+		isLog := func(path string) bool { return filepath.Ext(path) == ".log" }
+		err := s.Update(context.Background(), root, cutoff, isLog, func(_ string, lines *int, line []byte) {
+			*lines++
+			var ago, count int
+			var key string
+			if _, err := fmt.Sscanf(string(line), "%d %s %d", &ago, &key, &count); err != nil {
+				t.Fatalf("bad line %q: %v", line, err)
+			}
+			at := now.Add(-time.Duration(ago) * time.Hour)
+			if !s.Counted(fmt.Sprint(ago, key, count), at) {
+				s.Add(at, key, testUsage(count))
+			}
+		})
+		if err != nil {
+			t.Fatalf("Update error: %v", err)
+		}
+	}
+
+	write("a.log", "0 x 1\n1 y 2\n48 x 100\n")
+	write("b.log", "0 x 1\n0 x 4\n")
+	write("skip.txt", "0 x 1000\n")
+
+	s := &LogScan[string, testUsage, int]{}
+	if s.Restore(1) {
+		t.Fatal("expected a zero scan not to be kept")
+	}
+	update(s)
+	if got := s.SumSince(now); got != 5 {
+		t.Fatalf("unexpected sum this hour: %d", got)
+	}
+	if got := s.SumSince(cutoff); got != 7 {
+		t.Fatalf("unexpected sum since cutoff: %d", got)
+	}
+	if len(s.Buckets) != 2 {
+		t.Fatalf("expected buckets before the cutoff to be pruned, got %d", len(s.Buckets))
+	}
+
+	// State survives a save and restore; only appended lines are read.
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	restored := &LogScan[string, testUsage, int]{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if !restored.Restore(1) {
+		t.Fatal("expected the saved scan to be kept")
+	}
+	f, err := os.OpenFile(filepath.Join(root, "a.log"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile error: %v", err)
+	}
+	_, _ = f.WriteString("0 x 10\n")
+	_ = f.Close()
+	update(restored)
+	if got := restored.SumSince(now); got != 15 {
+		t.Fatalf("unexpected sum after append: %d", got)
+	}
+	if got := restored.Files[filepath.Join(root, "a.log")].Cursor; got != 4 {
+		t.Fatalf("expected the cursor to count 4 lines of a.log, got %d", got)
+	}
+
+	// A file that shrank is read again with a zero cursor; removed files are
+	// forgotten.
+	write("a.log", "0 z 3\n")
+	if err := os.Remove(filepath.Join(root, "b.log")); err != nil {
+		t.Fatalf("Remove error: %v", err)
+	}
+	update(restored)
+	if file := restored.Files[filepath.Join(root, "a.log")]; file.Cursor != 1 || file.Offset != 6 {
+		t.Fatalf("unexpected cursor after rewrite: %+v", file)
+	}
+	if _, ok := restored.Files[filepath.Join(root, "b.log")]; ok {
+		t.Fatal("expected the removed file to be forgotten")
+	}
+	if got := restored.SumSince(now); got != 18 {
+		t.Fatalf("unexpected sum after rewrite: %d", got)
+	}
+
+	// A file untouched since the cutoff is not read until it changes, and
+	// then from the start, so its cursor counts the earlier lines too.
+	old := filepath.Join(root, "old.log")
+	write("old.log", "72 w 5\n")
+	if err := os.Chtimes(old, cutoff.Add(-time.Hour), cutoff.Add(-time.Hour)); err != nil {
+		t.Fatalf("Chtimes error: %v", err)
+	}
+	update(restored)
+	if file := restored.Files[old]; file.Cursor != 0 || file.Offset != 0 {
+		t.Fatalf("expected the stale file to be skipped, got %+v", file)
+	}
+	f, err = os.OpenFile(old, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile error: %v", err)
+	}
+	_, _ = f.WriteString("0 w 7\n")
+	_ = f.Close()
+	update(restored)
+	if file := restored.Files[old]; file.Cursor != 2 || file.Offset != 13 {
+		t.Fatalf("unexpected cursor after appending to the stale file: %+v", file)
+	}
+	if got := restored.SumSince(now); got != 25 {
+		t.Fatalf("unexpected sum after appending to the stale file: %d", got)
+	}
+
+	// Another version starts over.
+	if restored.Restore(2) || len(restored.Buckets) != 0 || len(restored.Files) != 0 {
+		t.Fatal("expected state of another version to be discarded")
+	}
+}
@@ -2,15 +2,21 @@ package pluginruntime
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// ErrLSDiscoveryUnsupported is returned by DiscoverLS on Windows, which has
+// neither /proc nor ps and lsof to find processes and their ports with.
+var ErrLSDiscoveryUnsupported = errors.New("language server discovery is not supported on windows")
+
 type LSDiscoverOptions struct {
 	ProcessName string
 	Markers     []string
@@ -27,6 +33,16 @@ type LSDiscoverResult struct {
 	ExtensionPort *int              `json:"extensionPort,omitempty"`
 }
 
+// lsProcess is a candidate process with its argument vector.
+type lsProcess struct {
+	PID  int
+	Args []string
+}
+
+// DiscoverLS finds a running language server and its listening ports. On
+// Linux it reads /proc directly; elsewhere, or when /proc is unavailable, it
+// falls back to ps and lsof. On Windows it fails with
+// ErrLSDiscoveryUnsupported.
 func DiscoverLS(opts LSDiscoverOptions) (*LSDiscoverResult, error) {
 	if opts.ProcessName == "" {
 		return nil, fmt.Errorf("process name is required")
@@ -37,46 +53,88 @@ func DiscoverLS(opts LSDiscoverOptions) (*LSDiscoverResult, error) {
 	if opts.CSRFFlag == "" {
 		return nil, fmt.Errorf("csrf flag is required")
 	}
+	if runtime.GOOS == "windows" {
+		return nil, ErrLSDiscoveryUnsupported
+	}
 
-	psOutput, err := exec.Command("/bin/ps", "-ax", "-o", "pid=,command=").Output()
+	if runtime.GOOS == "linux" {
+		if processes, err := listProcProcesses(procRoot); err == nil {
+			return resolveLS(opts, processes, func(pid int) []int {
+				if ports := procListenPorts(procRoot, pid); len(ports) > 0 {
+					return ports
+				}
+				return parsePortsFromLsof(pid)
+			}), nil
+		}
+	}
+
+	processes, err := listPsProcesses()
 	if err != nil {
 		return nil, nil
 	}
+	return resolveLS(opts, processes, parsePortsFromLsof), nil
+}
 
-	processNameLower := strings.ToLower(opts.ProcessName)
-	markersLower := make([]string, len(opts.Markers))
-	for i, marker := range opts.Markers {
-		markersLower[i] = strings.ToLower(marker)
+// resolveLS picks the first process matching opts and reads its flags and
+// listening ports.
+func resolveLS(opts LSDiscoverOptions, processes []lsProcess, listenPorts func(pid int) []int) *LSDiscoverResult {
+	found := findLSProcess(opts, processes)
+	if found == nil {
+		return nil
 	}
 
-	var foundPID int
-	var foundCommand string
+	csrf := flagValue(found.Args, opts.CSRFFlag)
+	if csrf == "" {
+		return nil
+	}
 
-	for _, line := range strings.Split(string(psOutput), "\n") {
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" {
-			continue
+	var extensionPort *int
+	if opts.PortFlag != "" {
+		if raw := flagValue(found.Args, opts.PortFlag); raw != "" {
+			if port, err := strconv.Atoi(raw); err == nil {
+				extensionPort = &port
+			}
 		}
+	}
 
-		parts := strings.Fields(trimmed)
-		if len(parts) < 2 {
-			continue
+	extra := make(map[string]string, len(opts.ExtraFlags))
+	for _, flag := range opts.ExtraFlags {
+		if value := flagValue(found.Args, flag); value != "" {
+			key := strings.TrimLeft(flag, "-")
+			extra[key] = value
 		}
+	}
 
-		pidValue, err := strconv.Atoi(parts[0])
-		if err != nil {
-			continue
-		}
+	ports := listenPorts(found.PID)
+	if len(ports) == 0 && extensionPort == nil {
+		return nil
+	}
 
-		command := strings.TrimSpace(strings.TrimPrefix(trimmed, parts[0]))
-		commandLower := strings.ToLower(command)
+	return &LSDiscoverResult{
+		PID:           found.PID,
+		CSRF:          csrf,
+		Ports:         ports,
+		Extra:         extra,
+		ExtensionPort: extensionPort,
+	}
+}
 
+func findLSProcess(opts LSDiscoverOptions, processes []lsProcess) *lsProcess {
+	processNameLower := strings.ToLower(opts.ProcessName)
+	markersLower := make([]string, len(opts.Markers))
+	for i, marker := range opts.Markers {
+		markersLower[i] = strings.ToLower(marker)
+	}
+
+	for i := range processes {
+		process := &processes[i]
+		commandLower := strings.ToLower(strings.Join(process.Args, " "))
 		if !strings.Contains(commandLower, processNameLower) {
 			continue
 		}
 
-		ideName := strings.ToLower(extractFlag(command, "--ide_name"))
-		appDataDir := strings.ToLower(extractFlag(command, "--app_data_dir"))
+		ideName := strings.ToLower(flagValue(process.Args, "--ide_name"))
+		appDataDir := strings.ToLower(flagValue(process.Args, "--app_data_dir"))
 
 		hasMarker := false
 		for _, marker := range markersLower {
@@ -92,63 +150,53 @@ func DiscoverLS(opts LSDiscoverOptions) (*LSDiscoverResult, error) {
 				break
 			}
 		}
-		if !hasMarker {
-			continue
+		if hasMarker {
+			return process
 		}
-
-		foundPID = pidValue
-		foundCommand = command
-		break
-	}
-
-	if foundPID == 0 {
-		return nil, nil
 	}
+	return nil
+}
 
-	csrf := extractFlag(foundCommand, opts.CSRFFlag)
-	if csrf == "" {
-		return nil, nil
+// listPsProcesses lists processes via ps. Arguments are split on
+// whitespace, so values containing spaces are truncated.
+func listPsProcesses() ([]lsProcess, error) {
+	psOutput, err := exec.Command("/bin/ps", "-ax", "-o", "pid=,command=").Output()
+	if err != nil {
+		return nil, err
 	}
 
-	var extensionPort *int
-	if opts.PortFlag != "" {
-		if raw := extractFlag(foundCommand, opts.PortFlag); raw != "" {
-			if port, err := strconv.Atoi(raw); err == nil {
-				extensionPort = &port
-			}
+	var processes []lsProcess
+	for _, line := range strings.Split(string(psOutput), "\n") {
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			continue
 		}
-	}
-
-	extra := make(map[string]string, len(opts.ExtraFlags))
-	for _, flag := range opts.ExtraFlags {
-		if value := extractFlag(foundCommand, flag); value != "" {
-			key := strings.TrimLeft(flag, "-")
-			extra[key] = value
+		pid, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
 		}
+		processes = append(processes, lsProcess{PID: pid, Args: parts[1:]})
 	}
-
-	ports := parsePortsFromLsof(foundPID)
-	if len(ports) == 0 && extensionPort == nil {
-		return nil, nil
-	}
-
-	return &LSDiscoverResult{
-		PID:           foundPID,
-		CSRF:          csrf,
-		Ports:         ports,
-		Extra:         extra,
-		ExtensionPort: extensionPort,
-	}, nil
+	return processes, nil
 }
 
+// CallLS sends body as a Connect JSON request with the given HTTP method to
+// the path service, such as "pkg.Service/GetUserStatus", of the language
+// server on 127.0.0.1:port, with csrf as its CSRF token. The scheme defaults
+// to http, and https skips certificate checks; the timeout defaults to 10s.
 func CallLS(ctx context.Context, scheme string, port int, csrf, service, method string, body string, timeout time.Duration) (HTTPResponse, error) {
+	return (*Env)(nil).CallLS(ctx, scheme, port, csrf, service, method, body, timeout)
+}
+
+// CallLS is like CallLS but sends through the env's Transport, if any.
+func (e *Env) CallLS(ctx context.Context, scheme string, port int, csrf, service, method string, body string, timeout time.Duration) (HTTPResponse, error) {
 	if timeout <= 0 {
 		timeout = 10 * time.Second
 	}
 	if scheme == "" {
 		scheme = "http"
 	}
-	return DoHTTPRequest(ctx, HTTPRequest{
+	return e.DoHTTPRequest(ctx, HTTPRequest{
 		Method: method,
 		URL:    fmt.Sprintf("%s://127.0.0.1:%d/%s", scheme, port, service),
 		Headers: map[string]string{
@@ -162,17 +210,17 @@ func CallLS(ctx context.Context, scheme string, port int, csrf, service, method
 	})
 }
 
-func extractFlag(command, flag string) string {
-	parts := strings.Fields(command)
+// flagValue returns the value of flag given as "flag value" or "flag=value".
+func flagValue(args []string, flag string) string {
 	flagEq := flag + "="
-	for i, part := range parts {
-		if part == flag {
-			if i+1 < len(parts) {
-				return parts[i+1]
+	for i, arg := range args {
+		if arg == flag {
+			if i+1 < len(args) {
+				return args[i+1]
 			}
 		}
-		if strings.HasPrefix(part, flagEq) {
-			return strings.TrimPrefix(part, flagEq)
+		if strings.HasPrefix(arg, flagEq) {
+			return strings.TrimPrefix(arg, flagEq)
 		}
 	}
 	return ""
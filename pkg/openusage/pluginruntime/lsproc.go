@@ -0,0 +1,115 @@
+package pluginruntime
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	procRoot = "/proc"
+	// tcpListen is the TCP_LISTEN state in /proc/net/tcp.
+	tcpListen = "0A"
+)
+
+// listProcProcesses reads the exact argv of every process from
+// <root>/<pid>/cmdline. Processes that exit or cannot be read are skipped.
+func listProcProcesses(root string) ([]lsProcess, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var processes []lsProcess
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid <= 0 {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(root, entry.Name(), "cmdline"))
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		args := strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+		processes = append(processes, lsProcess{PID: pid, Args: args})
+	}
+	sort.Slice(processes, func(i, j int) bool { return processes[i].PID < processes[j].PID })
+	return processes, nil
+}
+
+// procListenPorts maps the socket inodes in <root>/<pid>/fd to LISTEN
+// entries in <root>/net/tcp and <root>/net/tcp6.
+func procListenPorts(root string, pid int) []int {
+	fdDir := filepath.Join(root, strconv.Itoa(pid), "fd")
+	entries, err := os.ReadDir(fdDir)
+	if err != nil {
+		return nil
+	}
+
+	inodes := make(map[string]struct{})
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join(fdDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if inode, ok := strings.CutPrefix(target, "socket:["); ok {
+			inodes[strings.TrimSuffix(inode, "]")] = struct{}{}
+		}
+	}
+	if len(inodes) == 0 {
+		return nil
+	}
+
+	set := make(map[int]struct{})
+	for _, name := range []string{"tcp", "tcp6"} {
+		for _, port := range parseProcNetTCP(filepath.Join(root, "net", name), inodes) {
+			set[port] = struct{}{}
+		}
+	}
+
+	ports := make([]int, 0, len(set))
+	for port := range set {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// parseProcNetTCP returns the local ports of LISTEN sockets whose inode is in
+// inodes. Lines look like:
+//
+//	sl  local_address rem_address   st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+//	 0: 0100007F:A411 00000000:0000 0A 00000000:00000000 00:00000000 00000000 1000 0 123456 ...
+func parseProcNetTCP(path string, inodes map[string]struct{}) []int {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	var ports []int
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpListen {
+			continue
+		}
+		if _, ok := inodes[fields[9]]; !ok {
+			continue
+		}
+		colon := strings.LastIndex(fields[1], ":")
+		if colon < 0 {
+			continue
+		}
+		port, err := strconv.ParseUint(fields[1][colon+1:], 16, 16)
+		if err != nil || port == 0 {
+			continue
+		}
+		ports = append(ports, int(port))
+	}
+	return ports
+}
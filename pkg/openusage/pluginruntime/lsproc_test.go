@@ -0,0 +1,132 @@
+package pluginruntime
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	testProcNetTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:A411 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:A412 0100007F:C350 01 00000000:00000000 00:00000000 00000000  1000        0 1002 1 0000000000000000 20 4 30 10 -1
+   2: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 9999 1 0000000000000000 100 0 0 10 0
+`
+	testProcNetTCP6 = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000001000000:A413 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 1003 1 0000000000000000 100 0 0 10 0
+`
+)
+
+// writeSyntheticProc builds a procfs tree with one Antigravity language
+// server (pid 4242), an unrelated process and a non-process entry.
+func writeSyntheticProc(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	writeProc := func(pid int, args []string, sockets ...string) {
+		dir := filepath.Join(root, strconv.Itoa(pid))
+		if err := os.MkdirAll(filepath.Join(dir, "fd"), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		cmdline := strings.Join(args, "\x00") + "\x00"
+		if err := os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0o644); err != nil {
+			t.Fatalf("write cmdline: %v", err)
+		}
+		for i, target := range sockets {
+			if err := os.Symlink(target, filepath.Join(dir, "fd", strconv.Itoa(i+3))); err != nil {
+				t.Fatalf("symlink fd: %v", err)
+			}
+		}
+	}
+
+	writeProc(17, []string{"/usr/sbin/sshd", "-D"}, "socket:[9999]")
+	writeProc(4242, []string{
+		"/opt/Antigravity/resources/bin/language_server_linux_x64",
+		"--csrf_token", "token-123",
+		"--extension_server_port=41000",
+		"--app_data_dir", "antigravity",
+		"--workspace_id", "My Project Folder",
+	}, "socket:[1001]", "socket:[1002]", "socket:[1003]", "pipe:[77]", "/dev/null")
+
+	if err := os.MkdirAll(filepath.Join(root, "self"), 0o755); err != nil {
+		t.Fatalf("mkdir self: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "net"), 0o755); err != nil {
+		t.Fatalf("mkdir net: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "net", "tcp"), []byte(testProcNetTCP), 0o644); err != nil {
+		t.Fatalf("write tcp: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "net", "tcp6"), []byte(testProcNetTCP6), 0o644); err != nil {
+		t.Fatalf("write tcp6: %v", err)
+	}
+	return root
+}
+
+func TestListProcProcessesKeepsExactArgs(t *testing.T) {
+	t.Parallel()
+
+	processes, err := listProcProcesses(writeSyntheticProc(t))
+	if err != nil {
+		t.Fatalf("listProcProcesses error: %v", err)
+	}
+	if len(processes) != 2 || processes[0].PID != 17 || processes[1].PID != 4242 {
+		t.Fatalf("unexpected processes: %+v", processes)
+	}
+	if got := flagValue(processes[1].Args, "--workspace_id"); got != "My Project Folder" {
+		t.Fatalf("unexpected workspace id: %q", got)
+	}
+}
+
+func TestProcListenPorts(t *testing.T) {
+	t.Parallel()
+
+	root := writeSyntheticProc(t)
+
+	// 1002 is an established connection and 9999 belongs to another process.
+	if got, want := procListenPorts(root, 4242), []int{42001, 42003}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("procListenPorts = %v, want %v", got, want)
+	}
+	if got := procListenPorts(root, 99); got != nil {
+		t.Fatalf("expected no ports for missing pid, got %v", got)
+	}
+}
+
+func TestResolveLSFromProc(t *testing.T) {
+	t.Parallel()
+
+	root := writeSyntheticProc(t)
+	processes, err := listProcProcesses(root)
+	if err != nil {
+		t.Fatalf("listProcProcesses error: %v", err)
+	}
+	opts := LSDiscoverOptions{
+		ProcessName: "language_server",
+		Markers:     []string{"antigravity"},
+		CSRFFlag:    "--csrf_token",
+		PortFlag:    "--extension_server_port",
+		ExtraFlags:  []string{"--workspace_id"},
+	}
+
+	got := resolveLS(opts, processes, func(pid int) []int { return procListenPorts(root, pid) })
+	if got == nil {
+		t.Fatal("expected language server to be found")
+	}
+	if got.PID != 4242 || got.CSRF != "token-123" || got.ExtensionPort == nil || *got.ExtensionPort != 41000 {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	if !reflect.DeepEqual(got.Ports, []int{42001, 42003}) {
+		t.Fatalf("unexpected ports: %v", got.Ports)
+	}
+	if got.Extra["workspace_id"] != "My Project Folder" {
+		t.Fatalf("unexpected extra: %v", got.Extra)
+	}
+
+	opts.Markers = []string{"windsurf"}
+	if got := resolveLS(opts, processes, func(int) []int { return nil }); got != nil {
+		t.Fatalf("expected no match for windsurf, got %+v", got)
+	}
+}
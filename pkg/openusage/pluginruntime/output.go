@@ -0,0 +1,75 @@
+package pluginruntime
+
+import (
+	"bytes"
+	"log"
+	"strings"
+)
+
+// OutputTailLimit is how much of a plugin's output an OutputTail keeps, and
+// the longest line it logs in full.
+const OutputTailLimit = 64 << 10
+
+// OutputTail logs a plugin process's stderr, or a sandbox's stdout and
+// stderr, line by line and keeps the last OutputTailLimit bytes. Lines longer
+// than that are logged truncated.
+type OutputTail struct {
+	logger  *log.Logger
+	buf     []byte
+	pending []byte
+	// skipping drops the rest of a line already logged truncated.
+	skipping bool
+}
+
+// NewOutputTail returns an OutputTail logging to logger, which may be nil.
+func NewOutputTail(logger *log.Logger) *OutputTail {
+	return &OutputTail{logger: logger}
+}
+
+func (t *OutputTail) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - OutputTailLimit; over > 0 {
+		t.buf = t.buf[over:]
+	}
+	t.pending = append(t.pending, p...)
+	for {
+		i := bytes.IndexByte(t.pending, '\n')
+		if i < 0 {
+			break
+		}
+		if !t.skipping {
+			t.log(t.pending[:i])
+		}
+		t.skipping = false
+		t.pending = t.pending[i+1:]
+	}
+	if len(t.pending) > OutputTailLimit {
+		if !t.skipping {
+			t.log(append(t.pending[:OutputTailLimit:OutputTailLimit], " (truncated)"...))
+			t.skipping = true
+		}
+		t.pending = nil
+	}
+	return len(p), nil
+}
+
+// Flush logs an unterminated last line.
+func (t *OutputTail) Flush() {
+	if len(t.pending) > 0 && !t.skipping {
+		t.log(t.pending)
+	}
+	t.pending = nil
+	t.skipping = false
+}
+
+func (t *OutputTail) log(line []byte) {
+	if text := strings.TrimSpace(string(line)); text != "" && t.logger != nil {
+		t.logger.Print(text)
+	}
+}
+
+// LastLine returns the last non-empty line kept, for error messages.
+func (t *OutputTail) LastLine() string {
+	lines := strings.Split(strings.TrimSpace(string(t.buf)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,31 @@
+package pluginruntime
+
+import (
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestOutputTailTruncatesLongLines(t *testing.T) {
+	t.Parallel()
+
+	var logged strings.Builder
+	tail := NewOutputTail(log.New(&logged, "", 0))
+	chunk := strings.Repeat("x", OutputTailLimit/4)
+	for range 16 {
+		_, _ = tail.Write([]byte(chunk))
+	}
+	_, _ = tail.Write([]byte("x\nlast\n"))
+	tail.Flush()
+
+	if len(tail.pending) != 0 {
+		t.Fatalf("expected no pending output, got %d bytes", len(tail.pending))
+	}
+	lines := strings.Split(strings.TrimSuffix(logged.String(), "\n"), "\n")
+	if len(lines) != 2 || len(lines[0]) != OutputTailLimit+len(" (truncated)") || lines[1] != "last" {
+		t.Fatalf("unexpected log lines: %d, %.40q", len(lines), lines)
+	}
+	if tail.LastLine() != "last" {
+		t.Fatalf("unexpected last line %q", tail.LastLine())
+	}
+}
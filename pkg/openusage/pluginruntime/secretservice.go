@@ -0,0 +1,178 @@
+package pluginruntime
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	secretsDest        = "org.freedesktop.secrets"
+	secretsPath        = dbus.ObjectPath("/org/freedesktop/secrets")
+	secretServiceIface = "org.freedesktop.Secret.Service"
+	secretItemIface    = "org.freedesktop.Secret.Item"
+	noPrompt           = dbus.ObjectPath("/")
+
+	// serviceAttribute matches the attribute libsecret-based tools such as
+	// go-keyring use, so their items can be read too.
+	serviceAttribute = "service"
+)
+
+// secretValue is the Secret struct (oayays) of the Secret Service API.
+type secretValue struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// SecretServiceStore keeps credentials in the freedesktop Secret Service
+// (GNOME Keyring, KWallet) on the session bus. Items are looked up by their
+// "service" attribute; new items go to the default collection.
+type SecretServiceStore struct {
+	connect func() (*dbus.Conn, error)
+}
+
+func NewSecretServiceStore() *SecretServiceStore {
+	return &SecretServiceStore{connect: func() (*dbus.Conn, error) { return dbus.ConnectSessionBus() }}
+}
+
+// secretSession is an open plain-transfer session on one connection.
+type secretSession struct {
+	conn    *dbus.Conn
+	service dbus.BusObject
+	path    dbus.ObjectPath
+}
+
+func (s *SecretServiceStore) open() (*secretSession, error) {
+	conn, err := s.connect()
+	if err != nil {
+		return nil, fmt.Errorf("connect to session bus: %v: %w", err, ErrCredentialStoreUnavailable)
+	}
+
+	service := conn.Object(secretsDest, secretsPath)
+	var output dbus.Variant
+	var path dbus.ObjectPath
+	if err := service.Call(secretServiceIface+".OpenSession", 0, "plain", dbus.MakeVariant("")).Store(&output, &path); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("open secret service session: %v: %w", err, ErrCredentialStoreUnavailable)
+	}
+	return &secretSession{conn: conn, service: service, path: path}, nil
+}
+
+func (s *secretSession) close() {
+	_ = s.conn.Object(secretsDest, s.path).Call("org.freedesktop.Secret.Session.Close", 0).Err
+	_ = s.conn.Close()
+}
+
+// search returns unlocked items for service, unlocking locked ones when that
+// needs no user prompt.
+func (s *secretSession) search(service string) ([]dbus.ObjectPath, error) {
+	var unlocked, locked []dbus.ObjectPath
+	attrs := map[string]string{serviceAttribute: service}
+	if err := s.service.Call(secretServiceIface+".SearchItems", 0, attrs).Store(&unlocked, &locked); err != nil {
+		return nil, fmt.Errorf("search secret service: %w", err)
+	}
+	if len(unlocked) > 0 || len(locked) == 0 {
+		return unlocked, nil
+	}
+
+	var prompt dbus.ObjectPath
+	if err := s.service.Call(secretServiceIface+".Unlock", 0, locked).Store(&unlocked, &prompt); err != nil {
+		return nil, fmt.Errorf("unlock secret service item: %w", err)
+	}
+	if len(unlocked) == 0 && prompt != noPrompt {
+		return nil, fmt.Errorf("secret service item for %s is locked", service)
+	}
+	return unlocked, nil
+}
+
+func (s *secretSession) secret(value string) secretValue {
+	return secretValue{Session: s.path, Parameters: []byte{}, Value: []byte(value), ContentType: "text/plain"}
+}
+
+func (s *SecretServiceStore) Read(service string) (string, error) {
+	session, err := s.open()
+	if err != nil {
+		return "", err
+	}
+	defer session.close()
+
+	items, err := session.search(service)
+	if err != nil {
+		return "", err
+	}
+	if len(items) == 0 {
+		return "", fmt.Errorf("secret service item %s: %w", service, ErrCredentialNotFound)
+	}
+
+	var secret secretValue
+	if err := session.conn.Object(secretsDest, items[0]).Call(secretItemIface+".GetSecret", 0, session.path).Store(&secret); err != nil {
+		return "", fmt.Errorf("read secret service item %s: %w", service, err)
+	}
+	return string(secret.Value), nil
+}
+
+func (s *SecretServiceStore) Write(service, value string) error {
+	session, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer session.close()
+
+	items, err := session.search(service)
+	if err != nil {
+		return err
+	}
+	if len(items) > 0 {
+		if err := session.conn.Object(secretsDest, items[0]).Call(secretItemIface+".SetSecret", 0, session.secret(value)).Err; err != nil {
+			return fmt.Errorf("update secret service item %s: %w", service, err)
+		}
+		return nil
+	}
+
+	var collection dbus.ObjectPath
+	if err := session.service.Call(secretServiceIface+".ReadAlias", 0, "default").Store(&collection); err != nil {
+		return fmt.Errorf("read default collection: %w", err)
+	}
+	if collection == noPrompt {
+		return fmt.Errorf("secret service has no default collection: %w", ErrCredentialStoreUnavailable)
+	}
+
+	properties := map[string]dbus.Variant{
+		"org.freedesktop.Secret.Item.Label":      dbus.MakeVariant("gopenusage: " + service),
+		"org.freedesktop.Secret.Item.Attributes": dbus.MakeVariant(map[string]string{serviceAttribute: service}),
+	}
+	var item, prompt dbus.ObjectPath
+	call := session.conn.Object(secretsDest, collection).Call("org.freedesktop.Secret.Collection.CreateItem", 0, properties, session.secret(value), true)
+	if err := call.Store(&item, &prompt); err != nil {
+		return fmt.Errorf("create secret service item %s: %w", service, err)
+	}
+	if prompt != noPrompt {
+		return fmt.Errorf("create secret service item %s: default collection is locked", service)
+	}
+	return nil
+}
+
+func (s *SecretServiceStore) Delete(service string) error {
+	session, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer session.close()
+
+	items, err := session.search(service)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("secret service item %s: %w", service, ErrCredentialNotFound)
+	}
+	for _, item := range items {
+		var prompt dbus.ObjectPath
+		if err := session.conn.Object(secretsDest, item).Call(secretItemIface+".Delete", 0).Store(&prompt); err != nil {
+			return fmt.Errorf("delete secret service item %s: %w", service, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package pluginruntime
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const testBusConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startTestBus runs a private dbus-daemon and returns its address.
+func startTestBus(t *testing.T) string {
+	t.Helper()
+
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "bus")
+	configPath := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(configPath, []byte(fmt.Sprintf(testBusConfig, socket)), 0o600); err != nil {
+		t.Fatalf("write bus config: %v", err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+configPath, "--nofork", "--nopidfile")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	address := "unix:path=" + socket
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := dbus.Connect(address)
+		if err == nil {
+			_ = conn.Close()
+			return address
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("dbus-daemon did not come up: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+type fakeItem struct {
+	service *fakeSecretService
+	path    dbus.ObjectPath
+	label   string
+	attrs   map[string]string
+	value   string
+	locked  bool
+}
+
+func (i *fakeItem) GetSecret(session dbus.ObjectPath) (secretValue, *dbus.Error) {
+	i.service.mu.Lock()
+	defer i.service.mu.Unlock()
+	if i.locked {
+		return secretValue{}, dbus.NewError("org.freedesktop.Secret.Error.IsLocked", nil)
+	}
+	return secretValue{Session: session, Parameters: []byte{}, Value: []byte(i.value), ContentType: "text/plain"}, nil
+}
+
+func (i *fakeItem) SetSecret(secret secretValue) *dbus.Error {
+	i.service.mu.Lock()
+	defer i.service.mu.Unlock()
+	i.value = string(secret.Value)
+	return nil
+}
+
+func (i *fakeItem) Delete() (dbus.ObjectPath, *dbus.Error) {
+	i.service.mu.Lock()
+	defer i.service.mu.Unlock()
+	delete(i.service.items, i.path)
+	_ = i.service.conn.Export(nil, i.path, secretItemIface)
+	return noPrompt, nil
+}
+
+type fakeSession struct{}
+
+func (fakeSession) Close() *dbus.Error { return nil }
+
+type fakeCollection struct{ service *fakeSecretService }
+
+func (c fakeCollection) CreateItem(properties map[string]dbus.Variant, secret secretValue, replace bool) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	label, _ := properties["org.freedesktop.Secret.Item.Label"].Value().(string)
+	attrs, _ := properties["org.freedesktop.Secret.Item.Attributes"].Value().(map[string]string)
+	item := c.service.add(label, attrs, string(secret.Value), false)
+	return item.path, noPrompt, nil
+}
+
+// fakeSecretService implements the parts of org.freedesktop.Secret.Service
+// the store uses.
+type fakeSecretService struct {
+	conn     *dbus.Conn
+	mu       sync.Mutex
+	items    map[dbus.ObjectPath]*fakeItem
+	next     int
+	sessions int
+}
+
+const fakeCollectionPath = dbus.ObjectPath("/org/freedesktop/secrets/collection/login")
+
+func startFakeSecretService(t *testing.T, address string) *fakeSecretService {
+	t.Helper()
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("connect fake service: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	svc := &fakeSecretService{conn: conn, items: make(map[dbus.ObjectPath]*fakeItem)}
+	if err := conn.Export(svc, secretsPath, secretServiceIface); err != nil {
+		t.Fatalf("export service: %v", err)
+	}
+	if err := conn.Export(fakeCollection{svc}, fakeCollectionPath, "org.freedesktop.Secret.Collection"); err != nil {
+		t.Fatalf("export collection: %v", err)
+	}
+	reply, err := conn.RequestName(secretsDest, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("request name: reply=%v err=%v", reply, err)
+	}
+	return svc
+}
+
+func (s *fakeSecretService) add(label string, attrs map[string]string, value string, locked bool) *fakeItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	item := &fakeItem{
+		service: s,
+		path:    dbus.ObjectPath(fmt.Sprintf("%s/%d", fakeCollectionPath, s.next)),
+		label:   label,
+		attrs:   attrs,
+		value:   value,
+		locked:  locked,
+	}
+	s.items[item.path] = item
+	_ = s.conn.Export(item, item.path, secretItemIface)
+	return item
+}
+
+func (s *fakeSecretService) OpenSession(algorithm string, input dbus.Variant) (dbus.Variant, dbus.ObjectPath, *dbus.Error) {
+	if algorithm != "plain" {
+		return dbus.Variant{}, "", dbus.NewError("org.freedesktop.DBus.Error.NotSupported", nil)
+	}
+	s.mu.Lock()
+	s.sessions++
+	path := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/secrets/session/%d", s.sessions))
+	s.mu.Unlock()
+	_ = s.conn.Export(fakeSession{}, path, "org.freedesktop.Secret.Session")
+	return dbus.MakeVariant(""), path, nil
+}
+
+func (s *fakeSecretService) SearchItems(attrs map[string]string) ([]dbus.ObjectPath, []dbus.ObjectPath, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlocked, locked := []dbus.ObjectPath{}, []dbus.ObjectPath{}
+	for path, item := range s.items {
+		match := true
+		for k, v := range attrs {
+			if item.attrs[k] != v {
+				match = false
+			}
+		}
+		if !match {
+			continue
+		}
+		if item.locked {
+			locked = append(locked, path)
+		} else {
+			unlocked = append(unlocked, path)
+		}
+	}
+	return unlocked, locked, nil
+}
+
+func (s *fakeSecretService) Unlock(objects []dbus.ObjectPath) ([]dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, path := range objects {
+		if item, ok := s.items[path]; ok {
+			item.locked = false
+		}
+	}
+	return objects, noPrompt, nil
+}
+
+func (s *fakeSecretService) ReadAlias(name string) (dbus.ObjectPath, *dbus.Error) {
+	if name == "default" {
+		return fakeCollectionPath, nil
+	}
+	return noPrompt, nil
+}
+
+func (s *fakeSecretService) find(service string) []*fakeItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var found []*fakeItem
+	for _, item := range s.items {
+		if item.attrs[serviceAttribute] == service {
+			found = append(found, item)
+		}
+	}
+	return found
+}
+
+func testSecretServiceStore(address string) *SecretServiceStore {
+	return &SecretServiceStore{connect: func() (*dbus.Conn, error) { return dbus.Connect(address) }}
+}
+
+func TestSecretServiceStoreRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	address := startTestBus(t)
+	fake := startFakeSecretService(t, address)
+	store := testSecretServiceStore(address)
+
+	if _, err := store.Read("OpenUsage-copilot"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("expected not found before write, got %v", err)
+	}
+
+	if err := store.Write("OpenUsage-copilot", `{"token":"one"}`); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	if err := store.Write("OpenUsage-copilot", `{"token":"two"}`); err != nil {
+		t.Fatalf("Write update error: %v", err)
+	}
+
+	items := fake.find("OpenUsage-copilot")
+	if len(items) != 1 {
+		t.Fatalf("expected a single item after update, got %d", len(items))
+	}
+	if items[0].label != "gopenusage: OpenUsage-copilot" {
+		t.Fatalf("unexpected label: %s", items[0].label)
+	}
+
+	got, err := store.Read("OpenUsage-copilot")
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+	if got != `{"token":"two"}` {
+		t.Fatalf("unexpected value: %s", got)
+	}
+
+	if err := store.Delete("OpenUsage-copilot"); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if len(fake.find("OpenUsage-copilot")) != 0 {
+		t.Fatal("expected item to be deleted")
+	}
+	if err := store.Delete("OpenUsage-copilot"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("expected not found on second delete, got %v", err)
+	}
+}
+
+func TestSecretServiceStoreReadsLockedForeignItem(t *testing.T) {
+	t.Parallel()
+
+	address := startTestBus(t)
+	fake := startFakeSecretService(t, address)
+	fake.add("gh", map[string]string{serviceAttribute: "gh:github.com", "username": "octocat"}, "gho_secret", true)
+
+	got, err := testSecretServiceStore(address).Read("gh:github.com")
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+	if got != "gho_secret" {
+		t.Fatalf("unexpected value: %s", got)
+	}
+}
+
+func TestSecretServiceStoreUnavailableFallsBackToFile(t *testing.T) {
+	t.Parallel()
+
+	address := startTestBus(t) // no secret service registered
+	store := &FallbackCredentialStore{
+		Primary:  testSecretServiceStore(address),
+		Fallback: NewFileCredentialStore(t.TempDir()),
+	}
+
+	if _, err := store.Primary.Read("svc"); !errors.Is(err, ErrCredentialStoreUnavailable) {
+		t.Fatalf("expected unavailable error, got %v", err)
+	}
+	if err := store.Write("svc", "value"); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	got, err := store.Read("svc")
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+	if got != "value" {
+		t.Fatalf("unexpected value: %s", got)
+	}
+	if err := store.Delete("svc"); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if _, err := store.Read("svc"); err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Fatalf("expected unavailable error after delete, got %v", err)
+	}
+}
@@ -1,57 +1,250 @@
 package pluginruntime
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
 	"strings"
+	"unicode"
+
+	"modernc.org/sqlite" // registers the pure-Go "sqlite" driver
 )
 
-func SQLiteQuery(dbPath, sql string) (string, error) {
-	if hasDotCommand(sql) {
+// sqliteLimitAttached is SQLITE_LIMIT_ATTACHED; at 0, ATTACH fails.
+const sqliteLimitAttached = 7
+
+// SQLiteQuery runs a single SELECT, WITH or VALUES statement against a
+// read-only, immutable view of dbPath and returns the rows as a JSON array of
+// objects, like `sqlite3 -json`. The connection cannot attach other
+// databases, so the query only reads dbPath.
+func SQLiteQuery(dbPath, query string) (string, error) {
+	if hasDotCommand(query) {
 		return "", fmt.Errorf("sqlite3 dot-commands are not allowed")
 	}
+	statement, err := readStatement(query)
+	if err != nil {
+		return "", err
+	}
 
-	expanded := ExpandPath(dbPath)
-	encoded := strings.NewReplacer(
-		"%", "%25",
-		" ", "%20",
-		"#", "%23",
-		"?", "%3F",
-	).Replace(expanded)
-	uri := fmt.Sprintf("file:%s?immutable=1", encoded)
+	db, err := openSQLite(dbPath, true)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return "", fmt.Errorf("sqlite error: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err := sqlite.Limit(conn, sqliteLimitAttached, 0); err != nil {
+		return "", fmt.Errorf("sqlite error: %w", err)
+	}
 
-	cmd := exec.Command("sqlite3", "-readonly", "-json", uri, sql)
-	output, err := cmd.CombinedOutput()
+	rows, err := conn.QueryContext(ctx, statement)
 	if err != nil {
-		msg := strings.TrimSpace(string(output))
-		if msg == "" {
-			msg = err.Error()
+		return "", fmt.Errorf("sqlite error: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", fmt.Errorf("sqlite error: %w", err)
+	}
+
+	result := make([]map[string]any, 0)
+	for rows.Next() {
+		values := make([]any, len(columns))
+		scan := make([]any, len(columns))
+		for i := range values {
+			scan[i] = &values[i]
+		}
+		if err := rows.Scan(scan...); err != nil {
+			return "", fmt.Errorf("sqlite error: %w", err)
 		}
-		return "", fmt.Errorf("sqlite3 error: %s", msg)
+
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[column] = values[i]
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("sqlite error: %w", err)
 	}
 
-	return string(output), nil
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
 }
 
-func SQLiteExec(dbPath, sql string) error {
-	if hasDotCommand(sql) {
+// SQLiteExec runs statements against an existing database file.
+func SQLiteExec(dbPath, statements string) error {
+	if hasDotCommand(statements) {
 		return fmt.Errorf("sqlite3 dot-commands are not allowed")
 	}
 
-	expanded := ExpandPath(dbPath)
-	cmd := exec.Command("sqlite3", expanded, sql)
-	output, err := cmd.CombinedOutput()
+	db, err := openSQLite(dbPath, false)
 	if err != nil {
-		msg := strings.TrimSpace(string(output))
-		if msg == "" {
-			msg = err.Error()
-		}
-		return fmt.Errorf("sqlite3 error: %s", msg)
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	if _, err := db.Exec(statements); err != nil {
+		return fmt.Errorf("sqlite error: %w", err)
+	}
+	return nil
+}
+
+// ItemTable is the key/value table VS Code based editors keep in their
+// state.vscdb databases.
+type ItemTable struct {
+	Path string
+}
+
+// Get returns the value for key; ok is false when the key is absent.
+func (t ItemTable) Get(key string) (value string, ok bool, err error) {
+	db, err := openSQLite(t.Path, true)
+	if err != nil {
+		return "", false, err
 	}
+	defer func() { _ = db.Close() }()
 
+	var raw any
+	err = db.QueryRow("SELECT value FROM ItemTable WHERE key = ? LIMIT 1", key).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("sqlite error: %w", err)
+	}
+
+	switch v := raw.(type) {
+	case string:
+		return v, true, nil
+	case []byte:
+		return string(v), true, nil
+	case nil:
+		return "", true, nil
+	default:
+		return fmt.Sprint(v), true, nil
+	}
+}
+
+// Set inserts or replaces the value for key.
+func (t ItemTable) Set(key, value string) error {
+	db, err := openSQLite(t.Path, false)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	if _, err := db.Exec("INSERT OR REPLACE INTO ItemTable (key, value) VALUES (?, ?)", key, value); err != nil {
+		return fmt.Errorf("sqlite error: %w", err)
+	}
 	return nil
 }
 
+// openSQLite opens dbPath without creating it. Read-only handles also set
+// immutable so SQLite neither locks the file nor touches its journal, which
+// matters while the owning editor has it open.
+func openSQLite(dbPath string, readOnly bool) (*sql.DB, error) {
+	if !FileExists(dbPath) {
+		return nil, fmt.Errorf("sqlite error: unable to open database file %s", dbPath)
+	}
+
+	params := "mode=rw&_pragma=busy_timeout(5000)"
+	if readOnly {
+		params = "mode=ro&immutable=1"
+	}
+	db, err := sql.Open("sqlite", "file:"+encodeSQLitePath(ExpandPath(dbPath))+"?"+params)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite error: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+func encodeSQLitePath(path string) string {
+	return strings.NewReplacer(
+		"%", "%25",
+		" ", "%20",
+		"#", "%23",
+		"?", "%3F",
+	).Replace(path)
+}
+
+// readStatement returns query when it is a single SELECT, WITH or VALUES
+// statement, with comments removed.
+func readStatement(query string) (string, error) {
+	var statements []string
+	var current strings.Builder
+	flush := func() {
+		if text := strings.TrimSpace(current.String()); text != "" {
+			statements = append(statements, text)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			j := strings.IndexByte(query[i+1:], end)
+			if j < 0 {
+				return "", fmt.Errorf("sqlite error: unterminated %c", c)
+			}
+			current.WriteString(query[i : i+j+2])
+			i += j + 1
+		case strings.HasPrefix(query[i:], "--"):
+			j := strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				j = len(query) - i
+			}
+			current.WriteByte(' ')
+			i += j
+		case strings.HasPrefix(query[i:], "/*"):
+			j := strings.Index(query[i+2:], "*/")
+			if j < 0 {
+				return "", fmt.Errorf("sqlite error: unterminated comment")
+			}
+			current.WriteByte(' ')
+			i += j + 3
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+
+	if len(statements) != 1 {
+		return "", fmt.Errorf("sqlite error: expected one statement, got %d", len(statements))
+	}
+	end := strings.IndexFunc(statements[0], func(r rune) bool { return !unicode.IsLetter(r) })
+	if end < 0 {
+		end = len(statements[0])
+	}
+	keyword := strings.ToUpper(statements[0][:end])
+	switch keyword {
+	case "SELECT", "WITH", "VALUES":
+		return statements[0], nil
+	}
+	return "", fmt.Errorf("sqlite error: only SELECT, WITH and VALUES statements are allowed, got %s", keyword)
+}
+
 func hasDotCommand(sql string) bool {
 	for _, line := range strings.Split(sql, "\n") {
 		if strings.HasPrefix(strings.TrimSpace(line), ".") {
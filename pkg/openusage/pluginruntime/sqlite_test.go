@@ -0,0 +1,123 @@
+package pluginruntime
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newStateDB creates a state.vscdb-like database in a directory whose name
+// needs URI escaping.
+func newStateDB(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "User Data #1", "state.vscdb")
+	if err := WriteText(path, ""); err != nil {
+		t.Fatalf("create db file: %v", err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	if _, err := db.Exec(`CREATE TABLE ItemTable (key TEXT UNIQUE ON CONFLICT REPLACE, value BLOB);
+		INSERT INTO ItemTable (key, value) VALUES ('text', 'plain'), ('blob', X'7B7D'), ('count', 3);`); err != nil {
+		t.Fatalf("seed db: %v", err)
+	}
+	return path
+}
+
+func TestSQLiteQueryReturnsJSONRows(t *testing.T) {
+	t.Parallel()
+
+	path := newStateDB(t)
+	got, err := SQLiteQuery(path, "SELECT key, value FROM ItemTable ORDER BY key")
+	if err != nil {
+		t.Fatalf("SQLiteQuery error: %v", err)
+	}
+
+	want := `[{"key":"blob","value":"{}"},{"key":"count","value":3},{"key":"text","value":"plain"}]`
+	if got != want {
+		t.Fatalf("unexpected rows:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSQLiteQueryIsReadOnly(t *testing.T) {
+	t.Parallel()
+
+	path := newStateDB(t)
+	if _, err := SQLiteQuery(path, "INSERT INTO ItemTable (key, value) VALUES ('x', 'y')"); err == nil {
+		t.Fatal("expected write through SQLiteQuery to fail")
+	}
+	if _, err := SQLiteQuery(path, ".tables"); err == nil {
+		t.Fatal("expected dot-command to be rejected")
+	}
+	if _, err := SQLiteQuery(filepath.Join(t.TempDir(), "missing.vscdb"), "SELECT 1"); err == nil {
+		t.Fatal("expected error for missing database")
+	}
+}
+
+func TestSQLiteExec(t *testing.T) {
+	t.Parallel()
+
+	path := newStateDB(t)
+	if err := SQLiteExec(path, "DELETE FROM ItemTable WHERE key = 'count'; DELETE FROM ItemTable WHERE key = 'blob';"); err != nil {
+		t.Fatalf("SQLiteExec error: %v", err)
+	}
+
+	got, err := SQLiteQuery(path, "SELECT count(*) AS n FROM ItemTable")
+	if err != nil {
+		t.Fatalf("SQLiteQuery error: %v", err)
+	}
+	if got != `[{"n":1}]` {
+		t.Fatalf("unexpected count: %s", got)
+	}
+}
+
+func TestItemTableGetSet(t *testing.T) {
+	t.Parallel()
+
+	table := ItemTable{Path: newStateDB(t)}
+
+	for key, want := range map[string]string{"text": "plain", "blob": "{}", "count": "3"} {
+		got, ok, err := table.Get(key)
+		if err != nil || !ok {
+			t.Fatalf("Get(%s): ok=%v err=%v", key, ok, err)
+		}
+		if got != want {
+			t.Fatalf("Get(%s) = %q, want %q", key, got, want)
+		}
+	}
+
+	if _, ok, err := table.Get("missing"); err != nil || ok {
+		t.Fatalf("expected missing key, got ok=%v err=%v", ok, err)
+	}
+
+	// Values are bound, so quotes cannot break out of the statement.
+	value := `{"token":"it's'); DROP TABLE ItemTable; --"}`
+	if err := table.Set("cursorAuth/accessToken", value); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	got, ok, err := table.Get("cursorAuth/accessToken")
+	if err != nil || !ok || got != value {
+		t.Fatalf("unexpected value after Set: %q ok=%v err=%v", got, ok, err)
+	}
+	if _, ok, _ := table.Get("text"); !ok {
+		t.Fatal("expected other rows to survive")
+	}
+}
+
+func TestItemTableSetRequiresExistingDatabase(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state.vscdb")
+	err := ItemTable{Path: path}.Set("k", "v")
+	if err == nil || !strings.Contains(err.Error(), "unable to open") {
+		t.Fatalf("expected open error, got %v", err)
+	}
+	if FileExists(path) {
+		t.Fatal("Set must not create the database")
+	}
+}
@@ -36,6 +36,20 @@ func Dollars(cents float64) float64 {
 	return math.Round(cents) / 100
 }
 
+// CompactCount shortens large counts: 950, 12.3K, 1.2M, 3B.
+func CompactCount(n int64) string {
+	units := []struct {
+		size   float64
+		suffix string
+	}{{1e9, "B"}, {1e6, "M"}, {1e3, "K"}}
+	for _, unit := range units {
+		if math.Abs(float64(n)) >= unit.size {
+			return strings.TrimSuffix(strconv.FormatFloat(float64(n)/unit.size, 'f', 1, 64), ".0") + unit.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
+
 func ParseDateMs(value any) (int64, bool) {
 	switch v := value.(type) {
 	case time.Time:
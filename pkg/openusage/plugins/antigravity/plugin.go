@@ -3,6 +3,7 @@ package antigravity
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -31,20 +32,23 @@ func (p *Plugin) ID() string {
 	return "antigravity"
 }
 
-func (p *Plugin) Query(ctx context.Context, _ *pluginruntime.Env) (openusage.QueryResult, error) {
-	discovery, err := pluginruntime.DiscoverLS(pluginruntime.LSDiscoverOptions{
+func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.QueryResult, error) {
+	discovery, err := env.DiscoverLS(pluginruntime.LSDiscoverOptions{
 		ProcessName: "language_server",
 		Markers:     []string{"antigravity"},
 		CSRFFlag:    "--csrf_token",
 		PortFlag:    "--extension_server_port",
 	})
+	if errors.Is(err, pluginruntime.ErrLSDiscoveryUnsupported) {
+		return openusage.QueryResult{}, err
+	}
 	if err != nil || discovery == nil {
-		return openusage.QueryResult{}, fmt.Errorf("start antigravity and try again")
+		return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorNotRunning, "antigravity is not running", "start antigravity and try again")
 	}
 
-	port, scheme, ok := p.findWorkingPort(ctx, discovery)
+	port, scheme, ok := p.findWorkingPort(ctx, env, discovery)
 	if !ok {
-		return openusage.QueryResult{}, fmt.Errorf("start antigravity and try again")
+		return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorNotRunning, "antigravity is not running", "start antigravity and try again")
 	}
 
 	metadata := map[string]any{
@@ -54,7 +58,7 @@ func (p *Plugin) Query(ctx context.Context, _ *pluginruntime.Env) (openusage.Que
 		"locale":        "en",
 	}
 
-	data := p.callLS(ctx, port, scheme, discovery.CSRF, "GetUserStatus", map[string]any{"metadata": metadata})
+	data := p.callLS(ctx, env, port, scheme, discovery.CSRF, "GetUserStatus", map[string]any{"metadata": metadata})
 	hasUserStatus := false
 	if data != nil {
 		if _, ok := pluginruntime.GetMap(data, "userStatus"); ok {
@@ -62,7 +66,7 @@ func (p *Plugin) Query(ctx context.Context, _ *pluginruntime.Env) (openusage.Que
 		}
 	}
 	if !hasUserStatus {
-		data = p.callLS(ctx, port, scheme, discovery.CSRF, "GetCommandModelConfigs", map[string]any{"metadata": metadata})
+		data = p.callLS(ctx, env, port, scheme, discovery.CSRF, "GetCommandModelConfigs", map[string]any{"metadata": metadata})
 	}
 
 	var configs []any
@@ -140,12 +144,12 @@ func (p *Plugin) Query(ctx context.Context, _ *pluginruntime.Env) (openusage.Que
 	return openusage.QueryResult{Plan: plan, Lines: lines}, nil
 }
 
-func (p *Plugin) findWorkingPort(ctx context.Context, discovery *pluginruntime.LSDiscoverResult) (int, string, bool) {
+func (p *Plugin) findWorkingPort(ctx context.Context, env *pluginruntime.Env, discovery *pluginruntime.LSDiscoverResult) (int, string, bool) {
 	for _, port := range discovery.Ports {
-		if p.probePort(ctx, "https", port, discovery.CSRF) {
+		if p.probePort(ctx, env, "https", port, discovery.CSRF) {
 			return port, "https", true
 		}
-		if p.probePort(ctx, "http", port, discovery.CSRF) {
+		if p.probePort(ctx, env, "http", port, discovery.CSRF) {
 			return port, "http", true
 		}
 	}
@@ -155,7 +159,7 @@ func (p *Plugin) findWorkingPort(ctx context.Context, discovery *pluginruntime.L
 	return 0, "", false
 }
 
-func (p *Plugin) probePort(ctx context.Context, scheme string, port int, csrf string) bool {
+func (p *Plugin) probePort(ctx context.Context, env *pluginruntime.Env, scheme string, port int, csrf string) bool {
 	body, _ := json.Marshal(map[string]any{
 		"context": map[string]any{
 			"properties": map[string]string{
@@ -168,13 +172,13 @@ func (p *Plugin) probePort(ctx context.Context, scheme string, port int, csrf st
 		},
 	})
 
-	_, err := pluginruntime.CallLS(ctx, scheme, port, csrf, lsService+"/GetUnleashData", "POST", string(body), 5*time.Second)
+	_, err := env.CallLS(ctx, scheme, port, csrf, lsService+"/GetUnleashData", "POST", string(body), 5*time.Second)
 	return err == nil
 }
 
-func (p *Plugin) callLS(ctx context.Context, port int, scheme, csrf, method string, body any) map[string]any {
+func (p *Plugin) callLS(ctx context.Context, env *pluginruntime.Env, port int, scheme, csrf, method string, body any) map[string]any {
 	payload, _ := json.Marshal(body)
-	resp, err := pluginruntime.CallLS(ctx, scheme, port, csrf, lsService+"/"+method, "POST", string(payload), 10*time.Second)
+	resp, err := env.CallLS(ctx, scheme, port, csrf, lsService+"/"+method, "POST", string(payload), 10*time.Second)
 	if err != nil {
 		return nil
 	}
@@ -29,6 +29,7 @@ type Plugin struct{}
 type credentials struct {
 	OAuth    map[string]any
 	Source   string
+	Path     string
 	FullData map[string]any
 }
 
@@ -43,12 +44,12 @@ func (p *Plugin) ID() string {
 func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.QueryResult, error) {
 	creds := p.loadCredentials(env)
 	if creds == nil {
-		return openusage.QueryResult{}, fmt.Errorf("not logged in; run `claude` to authenticate")
+		return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorAuthRequired, "not logged in", "run `claude` to authenticate")
 	}
 
 	accessToken, ok := pluginruntime.GetString(creds.OAuth, "accessToken")
 	if !ok || strings.TrimSpace(accessToken) == "" {
-		return openusage.QueryResult{}, fmt.Errorf("not logged in; run `claude` to authenticate")
+		return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorAuthRequired, "not logged in", "run `claude` to authenticate")
 	}
 
 	nowMs := time.Now().UnixMilli()
@@ -67,12 +68,12 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 			if token != "" {
 				useToken = token
 			}
-			response, reqErr := p.fetchUsage(ctx, useToken)
+			response, reqErr := p.fetchUsage(ctx, env, useToken)
 			if reqErr != nil {
 				if didRefresh {
-					return pluginruntime.HTTPResponse{}, fmt.Errorf("usage request failed after refresh, try again")
+					return pluginruntime.HTTPResponse{}, openusage.NewPluginError(openusage.ErrorNetwork, "usage request failed after refresh", "try again")
 				}
-				return pluginruntime.HTTPResponse{}, fmt.Errorf("usage request failed, check your connection")
+				return pluginruntime.HTTPResponse{}, openusage.NewPluginError(openusage.ErrorNetwork, "usage request failed", "check your connection")
 			}
 			return response, nil
 		},
@@ -86,15 +87,15 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 	}
 
 	if pluginruntime.IsAuthStatus(resp.Status) {
-		return openusage.QueryResult{}, fmt.Errorf("token expired; run `claude` to log in again")
+		return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorAuthExpired, "token expired", "run `claude` to log in again")
 	}
 	if resp.Status < 200 || resp.Status >= 300 {
-		return openusage.QueryResult{}, fmt.Errorf("usage request failed (HTTP %d), try again later", resp.Status)
+		return openusage.QueryResult{}, openusage.UpstreamStatusError(resp.Status, "try again later")
 	}
 
 	data, ok := pluginruntime.TryParseJSONMap(resp.Body)
 	if !ok {
-		return openusage.QueryResult{}, fmt.Errorf("usage response invalid, try again later")
+		return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorParse, "usage response invalid", "try again later")
 	}
 
 	plan := ""
@@ -153,20 +154,26 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 	return openusage.QueryResult{Plan: plan, Lines: lines}, nil
 }
 
-func (p *Plugin) loadCredentials(_ *pluginruntime.Env) *credentials {
-	if pluginruntime.FileExists(credentialFile) {
-		if text, err := pluginruntime.ReadText(credentialFile); err == nil {
+func (p *Plugin) loadCredentials(env *pluginruntime.Env) *credentials {
+	path := env.CredentialsPathOr(credentialFile)
+	if pluginruntime.FileExists(path) {
+		if text, err := pluginruntime.ReadText(path); err == nil {
 			if parsed, ok := parseCredentialJSON(text); ok {
 				if oauth, ok := pluginruntime.GetMap(parsed, "claudeAiOauth"); ok {
 					if accessToken, ok := pluginruntime.GetString(oauth, "accessToken"); ok && strings.TrimSpace(accessToken) != "" {
-						return &credentials{OAuth: oauth, Source: "file", FullData: parsed}
+						return &credentials{OAuth: oauth, Source: "file", Path: path, FullData: parsed}
 					}
 				}
 			}
 		}
 	}
 
-	if keychainValue, err := pluginruntime.ReadKeychainGenericPassword(keychainKey); err == nil {
+	// Accounts only use their configured file; the keychain holds the default login.
+	if env.Account != "" {
+		return nil
+	}
+
+	if keychainValue, err := env.ReadKeychainGenericPassword(keychainKey); err == nil {
 		if parsed, ok := parseCredentialJSON(keychainValue); ok {
 			if oauth, ok := pluginruntime.GetMap(parsed, "claudeAiOauth"); ok {
 				if accessToken, ok := pluginruntime.GetString(oauth, "accessToken"); ok && strings.TrimSpace(accessToken) != "" {
@@ -200,7 +207,7 @@ func parseCredentialJSON(text string) (map[string]any, bool) {
 	return pluginruntime.TryParseJSONMap(string(decoded))
 }
 
-func (p *Plugin) saveCredentials(creds *credentials) {
+func (p *Plugin) saveCredentials(env *pluginruntime.Env, creds *credentials) {
 	creds.FullData["claudeAiOauth"] = creds.OAuth
 	data, err := pluginruntime.JSONMarshal(creds.FullData)
 	if err != nil {
@@ -210,9 +217,9 @@ func (p *Plugin) saveCredentials(creds *credentials) {
 
 	switch creds.Source {
 	case "file":
-		_ = pluginruntime.WriteText(credentialFile, text)
+		_ = pluginruntime.WriteText(creds.Path, text)
 	case "keychain":
-		_ = pluginruntime.WriteKeychainGenericPassword(keychainKey, text)
+		_ = env.WriteKeychainGenericPassword(keychainKey, text)
 	}
 }
 
@@ -231,7 +238,7 @@ func (p *Plugin) refreshToken(ctx context.Context, env *pluginruntime.Env, creds
 	}
 
 	body := `{"grant_type":"refresh_token","refresh_token":"` + refreshToken + `","client_id":"` + clientID + `","scope":"` + scopes + `"}`
-	resp, err := pluginruntime.DoHTTPRequest(ctx, pluginruntime.HTTPRequest{
+	resp, err := env.DoHTTPRequest(ctx, pluginruntime.HTTPRequest{
 		Method:   "POST",
 		URL:      refreshURL,
 		Headers:  map[string]string{"Content-Type": "application/json"},
@@ -256,9 +263,9 @@ func (p *Plugin) refreshToken(ctx context.Context, env *pluginruntime.Env, creds
 			}
 		}
 		if errorCode == "invalid_grant" {
-			return "", fmt.Errorf("session expired; run `claude` to log in again")
+			return "", openusage.NewPluginError(openusage.ErrorAuthExpired, "session expired", "run `claude` to log in again")
 		}
-		return "", fmt.Errorf("token expired; run `claude` to log in again")
+		return "", openusage.NewPluginError(openusage.ErrorAuthExpired, "token expired", "run `claude` to log in again")
 	}
 
 	if resp.Status < 200 || resp.Status >= 300 {
@@ -281,12 +288,12 @@ func (p *Plugin) refreshToken(ctx context.Context, env *pluginruntime.Env, creds
 	if expiresIn, ok := pluginruntime.GetNumber(payload, "expires_in"); ok {
 		creds.OAuth["expiresAt"] = float64(time.Now().UnixMilli()) + expiresIn*1000
 	}
-	p.saveCredentials(creds)
+	p.saveCredentials(env, creds)
 	return newAccessToken, nil
 }
 
-func (p *Plugin) fetchUsage(ctx context.Context, accessToken string) (pluginruntime.HTTPResponse, error) {
-	return pluginruntime.DoHTTPRequest(ctx, pluginruntime.HTTPRequest{
+func (p *Plugin) fetchUsage(ctx context.Context, env *pluginruntime.Env, accessToken string) (pluginruntime.HTTPResponse, error) {
+	return env.DoHTTPRequest(ctx, pluginruntime.HTTPRequest{
 		Method: "GET",
 		URL:    usageURL,
 		Headers: map[string]string{
@@ -0,0 +1,224 @@
+// Package claudelogs totals Claude Code token usage from the local session
+// transcripts, without calling any API.
+package claudelogs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+	"github.com/deicod/gopenusage/pkg/openusage/plugins/logscan"
+)
+
+const topProjects = 3
+
+type Plugin struct {
+	now func() time.Time
+}
+
+func New() *Plugin {
+	return &Plugin{now: time.Now}
+}
+
+func (p *Plugin) ID() string {
+	return "claude-logs"
+}
+
+func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.QueryResult, error) {
+	root := projectsDir(env)
+	now := p.now()
+	return logscan.Query(ctx, env, logscan.Logs{
+		Root:    root,
+		Name:    "transcripts",
+		Missing: "no Claude Code transcripts found",
+		Hint:    "use Claude Code, or set plugins.claude-logs.logs_dir to its projects dir",
+	}, stateVersion, func(st *state) error {
+		return st.update(ctx, root, now)
+	}, func(st *state) []openusage.MetricLine {
+		return buildLines(st.Buckets, now)
+	})
+}
+
+// projectsDir is the configured path, $CLAUDE_CONFIG_DIR/projects, or the
+// first of ~/.config/claude/projects and ~/.claude/projects that exists.
+func projectsDir(env *pluginruntime.Env) string {
+	if path := env.LogsDirOr(""); path != "" {
+		return path
+	}
+	if dir := strings.TrimSpace(os.Getenv("CLAUDE_CONFIG_DIR")); dir != "" {
+		return filepath.Join(pluginruntime.ExpandPath(dir), "projects")
+	}
+	candidates := []string{
+		pluginruntime.ExpandPath("~/.config/claude/projects"),
+		pluginruntime.ExpandPath("~/.claude/projects"),
+	}
+	for _, path := range candidates {
+		if pluginruntime.FileExists(path) {
+			return path
+		}
+	}
+	return candidates[1]
+}
+
+// totals is usage with its estimated cost.
+type totals struct {
+	usage
+	Cost float64
+}
+
+func (t *totals) add(b *bucket) {
+	t.usage = t.usage.Plus(b.Usage)
+	if p, ok := priceFor(b.Key.Model); ok {
+		t.Cost += p.cost(b.Usage)
+	}
+}
+
+func (t totals) value() string {
+	return fmt.Sprintf("$%.2f · %s tokens", t.Cost, pluginruntime.CompactCount(t.total()))
+}
+
+func (t totals) breakdown() string {
+	count := pluginruntime.CompactCount
+	return fmt.Sprintf("in %s · out %s · cache write %s · cache read %s",
+		count(t.Input), count(t.Output), count(t.CacheCreation), count(t.CacheRead))
+}
+
+// buildLines reports the active 5-hour session, today, the last 7 days and
+// the 7-day totals per model, project and day.
+func buildLines(buckets []*bucket, now time.Time) []openusage.MetricLine {
+	weekStart := now.Add(-logscan.WeekWindow)
+	y, m, d := now.Date()
+	todayStart := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+
+	var session, today, week totals
+	models := make(map[string]*totals)
+	projects := make(map[string]*totals)
+	days := make(map[string]*totals)
+	unpriced := make(map[string]struct{})
+
+	sessionStart, sessionEnd, active := activeSession(buckets, now)
+	for _, b := range buckets {
+		at := time.Unix(b.Hour, 0)
+		if active && !at.Before(sessionStart) && at.Before(sessionEnd) {
+			session.add(b)
+		}
+		if !at.Before(todayStart) {
+			today.add(b)
+		}
+		if at.Before(weekStart.Truncate(time.Hour)) {
+			continue
+		}
+		week.add(b)
+		entry(models, b.Key.Model).add(b)
+		entry(projects, b.Key.Project).add(b)
+		entry(days, at.In(now.Location()).Format("2006-01-02")).add(b)
+		if _, ok := priceFor(b.Key.Model); !ok {
+			unpriced[b.Key.Model] = struct{}{}
+		}
+	}
+
+	lines := make([]openusage.MetricLine, 0, 8)
+	if active {
+		lines = append(lines, openusage.NewTextLine("Session", session.value(), openusage.TextLineOptions{
+			Subtitle: "5h window until " + sessionEnd.In(now.Location()).Format("15:04"),
+		}))
+	} else {
+		lines = append(lines, openusage.NewTextLine("Session", "No active session", openusage.TextLineOptions{}))
+	}
+	lines = append(lines,
+		openusage.NewTextLine("Today", today.value(), openusage.TextLineOptions{Subtitle: today.breakdown()}),
+		openusage.NewTextLine("This week", week.value(), openusage.TextLineOptions{Subtitle: "Last 7 days · " + week.breakdown()}),
+	)
+
+	for _, model := range sortedByCost(models, 0) {
+		lines = append(lines, openusage.NewTextLine(model, models[model].value(), openusage.TextLineOptions{Subtitle: "Model, last 7 days"}))
+	}
+	for _, project := range sortedByCost(projects, topProjects) {
+		lines = append(lines, openusage.NewTextLine(project, projects[project].value(), openusage.TextLineOptions{Subtitle: "Project, last 7 days"}))
+	}
+
+	dayKeys := make([]string, 0, len(days))
+	for day := range days {
+		dayKeys = append(dayKeys, day)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dayKeys)))
+	for _, day := range dayKeys {
+		t, _ := time.ParseInLocation("2006-01-02", day, now.Location())
+		lines = append(lines, openusage.NewTextLine(t.Format("Mon Jan 2"), days[day].value(), openusage.TextLineOptions{Subtitle: "Daily"}))
+	}
+
+	if len(unpriced) > 0 {
+		names := make([]string, 0, len(unpriced))
+		for name := range unpriced {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		lines = append(lines, openusage.NewBadgeLine("Unpriced", strings.Join(names, ", "), openusage.TextLineOptions{
+			Color:    "#eab308",
+			Subtitle: "Not included in cost estimates",
+		}))
+	}
+	return lines
+}
+
+// activeSession finds the 5-hour block containing now. Like Claude's
+// session limits, a block starts at the hour of its first message and a
+// message after the block ends starts a new one.
+func activeSession(buckets []*bucket, now time.Time) (start, end time.Time, ok bool) {
+	hours := make([]int64, 0, len(buckets))
+	for _, b := range buckets {
+		hours = append(hours, b.Hour)
+	}
+	sort.Slice(hours, func(i, j int) bool { return hours[i] < hours[j] })
+
+	for _, hour := range hours {
+		at := time.Unix(hour, 0)
+		if at.After(now) {
+			break
+		}
+		if start.IsZero() || !at.Before(end) {
+			start, end = at, at.Add(logscan.SessionWindow)
+		}
+	}
+	if start.IsZero() || !now.Before(end) {
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end, true
+}
+
+func entry(m map[string]*totals, key string) *totals {
+	t, ok := m[key]
+	if !ok {
+		t = &totals{}
+		m[key] = t
+	}
+	return t
+}
+
+// sortedByCost orders keys by cost, then tokens; limit 0 keeps all.
+func sortedByCost(m map[string]*totals, limit int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := m[keys[i]], m[keys[j]]
+		if a.Cost != b.Cost {
+			return a.Cost > b.Cost
+		}
+		if a.total() != b.total() {
+			return a.total() > b.total()
+		}
+		return keys[i] < keys[j]
+	})
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys
+}
@@ -0,0 +1,142 @@
+package claudelogs
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+	"github.com/deicod/gopenusage/pkg/openusage/plugins/logscan"
+	"github.com/deicod/gopenusage/pkg/openusage/plugins/plugintest"
+)
+
+func assistantLine(at time.Time, id, model, cwd string, input, output, cacheRead int64) string {
+	return fmt.Sprintf(`{"type":"assistant","timestamp":%q,"cwd":%q,"requestId":"req_%s","message":{"id":%q,"model":%q,"usage":{"input_tokens":%d,"output_tokens":%d,"cache_creation_input_tokens":0,"cache_read_input_tokens":%d}}}`,
+		at.Format(time.RFC3339Nano), cwd, id, id, model, input, output, cacheRead)
+}
+
+func lineValue(t *testing.T, lines []openusage.MetricLine, label string) string {
+	t.Helper()
+	line := plugintest.FindLine(t, lines, label)
+	if line.Value != nil {
+		return *line.Value
+	}
+	return *line.Text
+}
+
+func TestQueryTotalsTranscripts(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	sonnet := assistantLine(plugintest.Now.Add(-25*time.Minute), "msg_1", "claude-sonnet-4-5-20250929", "/src/app", 100_000, 20_000, 1_000_000)
+	plugintest.AppendLines(t, filepath.Join(root, "-src-app", "a.jsonl"),
+		`{"type":"user","timestamp":"2026-03-10T12:04:00Z","message":{"role":"user","content":"hi"}}`,
+		sonnet,
+	)
+	plugintest.AppendLines(t, filepath.Join(root, "-src-app", "resumed.jsonl"), sonnet) // duplicate
+	plugintest.AppendLines(t, filepath.Join(root, "-src-api", "b.jsonl"),
+		assistantLine(plugintest.Now.Add(-3*time.Hour-20*time.Minute), "msg_2", "claude-opus-4-1-20250805", "/src/api", 10_000, 10_000, 0),
+		assistantLine(plugintest.Now.Add(-72*time.Hour), "msg_3", "claude-next-9", "/src/api", 5_000, 0, 0),
+		assistantLine(plugintest.Now.Add(-10*24*time.Hour), "msg_4", "claude-opus-4-1-20250805", "/src/api", 1_000_000, 0, 0),
+	)
+
+	env, err := pluginruntime.NewEnv("claude-logs", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEnv error: %v", err)
+	}
+	env.LogsDir = root
+	plugin := &Plugin{now: func() time.Time { return plugintest.Now }}
+
+	result, err := plugin.Query(context.Background(), env)
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if result.Source != root {
+		t.Fatalf("unexpected source: %s", result.Source)
+	}
+
+	// sonnet: 0.1M*$3 + 0.02M*$15 + 1M*$0.30 = $0.90; opus: 0.01M*$15 + 0.01M*$75 = $0.90
+	if got := lineValue(t, result.Lines, "Session"); got != "$1.80 · 1.1M tokens" {
+		t.Fatalf("unexpected session: %s", got)
+	}
+	if got := lineValue(t, result.Lines, "Today"); got != "$1.80 · 1.1M tokens" {
+		t.Fatalf("unexpected today: %s", got)
+	}
+	if got := lineValue(t, result.Lines, "This week"); got != "$1.80 · 1.1M tokens" {
+		t.Fatalf("unexpected week: %s", got)
+	}
+	if got := lineValue(t, result.Lines, "claude-opus-4-1-20250805"); got != "$0.90 · 20K tokens" {
+		t.Fatalf("unexpected opus total: %s", got)
+	}
+	if got := lineValue(t, result.Lines, "api"); got != "$0.90 · 25K tokens" {
+		t.Fatalf("unexpected project total: %s", got)
+	}
+	if got := lineValue(t, result.Lines, "Sat Mar 7"); got != "$0.00 · 5K tokens" {
+		t.Fatalf("unexpected daily total: %s", got)
+	}
+	if got := lineValue(t, result.Lines, "Unpriced"); got != "claude-next-9" {
+		t.Fatalf("unexpected unpriced models: %s", got)
+	}
+
+	if !pluginruntime.FileExists(filepath.Join(env.PluginDataDir, logscan.StateFile)) {
+		t.Fatal("expected cursor file to be written")
+	}
+
+	// A second query only reads what was appended since the first.
+	plugintest.AppendLines(t, filepath.Join(root, "-src-app", "a.jsonl"),
+		assistantLine(plugintest.Now.Add(-5*time.Minute), "msg_5", "claude-haiku-4-5-20251001", "/src/app", 1_000_000, 0, 0))
+
+	result, err = (&Plugin{now: func() time.Time { return plugintest.Now }}).Query(context.Background(), env)
+	if err != nil {
+		t.Fatalf("second Query error: %v", err)
+	}
+	if got := lineValue(t, result.Lines, "Today"); got != "$2.80 · 2.1M tokens" {
+		t.Fatalf("unexpected today after append: %s", got)
+	}
+}
+
+func TestActiveSessionStartsNewBlockAfterWindow(t *testing.T) {
+	t.Parallel()
+
+	hour := func(d time.Duration) *bucket {
+		return &bucket{Hour: plugintest.Now.Add(-d).Truncate(time.Hour).Unix()}
+	}
+
+	// 06:00 starts a block until 11:00; 11:00 starts the active one.
+	start, end, ok := activeSession([]*bucket{hour(6 * time.Hour), hour(90 * time.Minute), hour(0)}, plugintest.Now)
+	if !ok {
+		t.Fatal("expected an active session")
+	}
+	if want := time.Date(2026, 3, 10, 11, 0, 0, 0, time.UTC); !start.Equal(want) || !end.Equal(want.Add(logscan.SessionWindow)) {
+		t.Fatalf("unexpected session %s - %s", start, end)
+	}
+
+	if _, _, ok := activeSession([]*bucket{hour(6 * time.Hour)}, plugintest.Now); ok {
+		t.Fatal("expected no active session after the window ended")
+	}
+}
+
+func TestQueryReportsMissingTranscripts(t *testing.T) {
+	t.Parallel()
+
+	env, err := pluginruntime.NewEnv("claude-logs", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEnv error: %v", err)
+	}
+	env.LogsDir = filepath.Join(t.TempDir(), "missing")
+
+	_, err = New().Query(context.Background(), env)
+	if detail := openusage.ClassifyError(err); detail == nil || detail.Code != openusage.ErrorNotConfigured || !strings.Contains(err.Error(), "no Claude Code transcripts") {
+		t.Fatalf("expected missing transcripts error, got %v", err)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	t.Parallel()
+
+	plugintest.Run(t, &Plugin{now: func() time.Time { return plugintest.Now }}, "testdata")
+}
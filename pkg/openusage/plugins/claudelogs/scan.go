@@ -0,0 +1,125 @@
+package claudelogs
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+	"github.com/deicod/gopenusage/pkg/openusage/plugins/logscan"
+)
+
+// stateVersion 2 moved the scan state to pluginruntime.LogScan.
+const stateVersion = 2
+
+type usage struct {
+	Input         int64 `json:"input"`
+	Output        int64 `json:"output"`
+	CacheCreation int64 `json:"cacheCreation"`
+	CacheRead     int64 `json:"cacheRead"`
+}
+
+func (u usage) Plus(o usage) usage {
+	return usage{
+		Input:         u.Input + o.Input,
+		Output:        u.Output + o.Output,
+		CacheCreation: u.CacheCreation + o.CacheCreation,
+		CacheRead:     u.CacheRead + o.CacheRead,
+	}
+}
+
+func (u usage) total() int64 {
+	return u.Input + u.Output + u.CacheCreation + u.CacheRead
+}
+
+// origin is what a bucket's usage is attributed to.
+type origin struct {
+	Model   string `json:"model"`
+	Project string `json:"project"`
+}
+
+// bucket aggregates usage for one model and project within one hour.
+type bucket = pluginruntime.LogBucket[origin, usage]
+
+// state is persisted between queries so only newly appended log lines are
+// parsed. Seen holds message keys already counted; resumed sessions repeat
+// earlier messages in new files.
+type state struct {
+	pluginruntime.LogScan[origin, usage, struct{}]
+}
+
+// update parses log lines appended under root since the last call.
+func (s *state) update(ctx context.Context, root string, now time.Time) error {
+	cutoff := now.Add(-logscan.Retention)
+	isTranscript := func(path string) bool { return filepath.Ext(path) == ".jsonl" }
+	return s.Update(ctx, root, cutoff, isTranscript, func(path string, _ *struct{}, line []byte) {
+		s.addLine(line, projectName(root, path), cutoff)
+	})
+}
+
+type logEntry struct {
+	Timestamp string `json:"timestamp"`
+	Cwd       string `json:"cwd"`
+	RequestID string `json:"requestId"`
+	Message   *struct {
+		ID    string `json:"id"`
+		Model string `json:"model"`
+		Usage *struct {
+			InputTokens              int64 `json:"input_tokens"`
+			OutputTokens             int64 `json:"output_tokens"`
+			CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+			CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+}
+
+func (s *state) addLine(line []byte, project string, cutoff time.Time) {
+	// Cheap pre-filter: only assistant messages carry usage.
+	if !strings.Contains(string(line), `"usage"`) {
+		return
+	}
+
+	var entry logEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return
+	}
+	if entry.Message == nil || entry.Message.Usage == nil || entry.Message.Model == "" || entry.Message.Model == "<synthetic>" {
+		return
+	}
+	at, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+	if err != nil || at.Before(cutoff) {
+		return
+	}
+
+	if entry.Message.ID != "" && s.Counted(entry.Message.ID+":"+entry.RequestID, at) {
+		return
+	}
+
+	if entry.Cwd != "" {
+		project = filepath.Base(entry.Cwd)
+	}
+	u := entry.Message.Usage
+	s.Add(at, origin{Model: entry.Message.Model, Project: project}, usage{
+		Input:         u.InputTokens,
+		Output:        u.OutputTokens,
+		CacheCreation: u.CacheCreationInputTokens,
+		CacheRead:     u.CacheReadInputTokens,
+	})
+}
+
+// projectName is the first directory below root, which Claude Code names
+// after the project path (e.g. "-home-me-src-app"); entries with a cwd use
+// its base name instead.
+func projectName(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "unknown"
+	}
+	first, _, found := strings.Cut(filepath.ToSlash(rel), "/")
+	if !found {
+		return "unknown"
+	}
+	return first
+}
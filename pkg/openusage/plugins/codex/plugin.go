@@ -36,9 +36,9 @@ func (p *Plugin) ID() string {
 }
 
 func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.QueryResult, error) {
-	auth, authPath, ok := p.loadAuth()
+	auth, authPath, ok := p.loadAuth(env)
 	if !ok {
-		return openusage.QueryResult{}, fmt.Errorf("not logged in; run `codex` to authenticate")
+		return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorAuthRequired, "not logged in", "run `codex` to authenticate")
 	}
 
 	tokens, hasTokens := pluginruntime.GetMap(auth, "tokens")
@@ -62,12 +62,12 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 				if token != "" {
 					useToken = token
 				}
-				response, reqErr := p.fetchUsage(ctx, useToken, accountID)
+				response, reqErr := p.fetchUsage(ctx, env, useToken, accountID)
 				if reqErr != nil {
 					if didRefresh {
-						return pluginruntime.HTTPResponse{}, fmt.Errorf("usage request failed after refresh, try again")
+						return pluginruntime.HTTPResponse{}, openusage.NewPluginError(openusage.ErrorNetwork, "usage request failed after refresh", "try again")
 					}
-					return pluginruntime.HTTPResponse{}, fmt.Errorf("usage request failed, check your connection")
+					return pluginruntime.HTTPResponse{}, openusage.NewPluginError(openusage.ErrorNetwork, "usage request failed", "check your connection")
 				}
 				return response, nil
 			},
@@ -81,15 +81,15 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 		}
 
 		if pluginruntime.IsAuthStatus(resp.Status) {
-			return openusage.QueryResult{}, fmt.Errorf("token expired; run `codex` to log in again")
+			return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorAuthExpired, "token expired", "run `codex` to log in again")
 		}
 		if resp.Status < 200 || resp.Status >= 300 {
-			return openusage.QueryResult{}, fmt.Errorf("usage request failed (HTTP %d), try again later", resp.Status)
+			return openusage.QueryResult{}, openusage.UpstreamStatusError(resp.Status, "try again later")
 		}
 
 		data, ok := pluginruntime.TryParseJSONMap(resp.Body)
 		if !ok {
-			return openusage.QueryResult{}, fmt.Errorf("usage response invalid, try again later")
+			return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorParse, "usage response invalid", "try again later")
 		}
 
 		nowSec := float64(time.Now().Unix())
@@ -154,7 +154,7 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 		return openusage.QueryResult{}, fmt.Errorf("usage not available for API key")
 	}
 
-	return openusage.QueryResult{}, fmt.Errorf("not logged in; run `codex` to authenticate")
+	return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorAuthRequired, "not logged in", "run `codex` to authenticate")
 }
 
 func buildWindowLine(label string, used float64, resetsAt string, periodMs int64) openusage.MetricLine {
@@ -178,8 +178,8 @@ func getResetsAtISO(nowSec float64, window map[string]any) string {
 	return ""
 }
 
-func (p *Plugin) loadAuth() (map[string]any, string, bool) {
-	authPath := p.resolveAuthPath()
+func (p *Plugin) loadAuth(env *pluginruntime.Env) (map[string]any, string, bool) {
+	authPath := env.CredentialsPathOr(p.resolveAuthPath())
 	if authPath == "" || !pluginruntime.FileExists(authPath) {
 		return nil, "", false
 	}
@@ -236,7 +236,7 @@ func (p *Plugin) refreshToken(ctx context.Context, env *pluginruntime.Env, auth
 	body.Set("client_id", clientID)
 	body.Set("refresh_token", refreshToken)
 
-	resp, err := pluginruntime.DoHTTPRequest(ctx, pluginruntime.HTTPRequest{
+	resp, err := env.DoHTTPRequest(ctx, pluginruntime.HTTPRequest{
 		Method:   "POST",
 		URL:      refreshURL,
 		Headers:  map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
@@ -264,13 +264,13 @@ func (p *Plugin) refreshToken(ctx context.Context, env *pluginruntime.Env, auth
 		}
 		switch code {
 		case "refresh_token_expired":
-			return "", fmt.Errorf("session expired; run `codex` to log in again")
+			return "", openusage.NewPluginError(openusage.ErrorAuthExpired, "session expired", "run `codex` to log in again")
 		case "refresh_token_reused":
-			return "", fmt.Errorf("token conflict; run `codex` to log in again")
+			return "", openusage.NewPluginError(openusage.ErrorAuthExpired, "token conflict", "run `codex` to log in again")
 		case "refresh_token_invalidated":
-			return "", fmt.Errorf("token revoked; run `codex` to log in again")
+			return "", openusage.NewPluginError(openusage.ErrorAuthExpired, "token revoked", "run `codex` to log in again")
 		default:
-			return "", fmt.Errorf("token expired; run `codex` to log in again")
+			return "", openusage.NewPluginError(openusage.ErrorAuthExpired, "token expired", "run `codex` to log in again")
 		}
 	}
 
@@ -305,7 +305,7 @@ func (p *Plugin) refreshToken(ctx context.Context, env *pluginruntime.Env, auth
 	return newAccessToken, nil
 }
 
-func (p *Plugin) fetchUsage(ctx context.Context, accessToken, accountID string) (pluginruntime.HTTPResponse, error) {
+func (p *Plugin) fetchUsage(ctx context.Context, env *pluginruntime.Env, accessToken, accountID string) (pluginruntime.HTTPResponse, error) {
 	headers := map[string]string{
 		"Authorization": "Bearer " + accessToken,
 		"Accept":        "application/json",
@@ -315,7 +315,7 @@ func (p *Plugin) fetchUsage(ctx context.Context, accessToken, accountID string)
 		headers["ChatGPT-Account-Id"] = accountID
 	}
 
-	return pluginruntime.DoHTTPRequest(ctx, pluginruntime.HTTPRequest{
+	return env.DoHTTPRequest(ctx, pluginruntime.HTTPRequest{
 		Method:  "GET",
 		URL:     usageURL,
 		Headers: headers,
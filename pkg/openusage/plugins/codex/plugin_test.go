@@ -0,0 +1,13 @@
+package codex
+
+import (
+	"testing"
+
+	"github.com/deicod/gopenusage/pkg/openusage/plugins/plugintest"
+)
+
+func TestGolden(t *testing.T) {
+	t.Parallel()
+
+	plugintest.Run(t, New(), "testdata")
+}
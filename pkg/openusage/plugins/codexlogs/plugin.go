@@ -0,0 +1,149 @@
+// Package codexlogs totals Codex CLI token usage from the local session
+// rollout files, without calling any API.
+package codexlogs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+	"github.com/deicod/gopenusage/pkg/openusage/plugins/logscan"
+)
+
+type Plugin struct {
+	now func() time.Time
+}
+
+func New() *Plugin {
+	return &Plugin{now: time.Now}
+}
+
+func (p *Plugin) ID() string {
+	return "codex-logs"
+}
+
+func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.QueryResult, error) {
+	root := sessionsDir(env)
+	now := p.now()
+	return logscan.Query(ctx, env, logscan.Logs{
+		Root:    root,
+		Name:    "sessions",
+		Missing: "no Codex sessions found",
+		Hint:    "use Codex, or set plugins.codex-logs.logs_dir to its sessions dir",
+	}, stateVersion, func(st *state) error {
+		return st.update(ctx, root, now)
+	}, func(st *state) []openusage.MetricLine {
+		return buildLines(st, now)
+	})
+}
+
+// sessionsDir is the configured path, $CODEX_HOME/sessions or
+// ~/.codex/sessions.
+func sessionsDir(env *pluginruntime.Env) string {
+	if path := env.LogsDirOr(""); path != "" {
+		return path
+	}
+	if dir := strings.TrimSpace(os.Getenv("CODEX_HOME")); dir != "" {
+		return filepath.Join(pluginruntime.ExpandPath(dir), "sessions")
+	}
+	return pluginruntime.ExpandPath("~/.codex/sessions")
+}
+
+func (u usage) value() string {
+	return pluginruntime.CompactCount(u.total()) + " tokens"
+}
+
+func (u usage) breakdown() string {
+	count := pluginruntime.CompactCount
+	return fmt.Sprintf("in %s (cached %s) · out %s (reasoning %s)",
+		count(u.Input), count(u.CachedInput), count(u.Output), count(u.Reasoning))
+}
+
+// buildLines reports local usage within the session and weekly rate-limit
+// windows, today, and the 7-day totals per model and day.
+func buildLines(st *state, now time.Time) []openusage.MetricLine {
+	var primary, secondary *rateWindow
+	if st.RateLimits != nil {
+		primary, secondary = st.RateLimits.Primary, st.RateLimits.Secondary
+	}
+
+	lines := []openusage.MetricLine{
+		windowLine("Session", st, primary, logscan.SessionWindow, now),
+		windowLine("Weekly", st, secondary, logscan.WeekWindow, now),
+	}
+
+	y, m, d := now.Date()
+	today := st.SumSince(time.Date(y, m, d, 0, 0, 0, 0, now.Location()))
+	lines = append(lines, openusage.NewTextLine("Today", today.value(), openusage.TextLineOptions{Subtitle: today.breakdown()}))
+
+	weekStart := now.Add(-logscan.WeekWindow).Truncate(time.Hour).Unix()
+	models := make(map[string]usage)
+	days := make(map[string]usage)
+	for _, b := range st.Buckets {
+		if b.Hour < weekStart {
+			continue
+		}
+		day := time.Unix(b.Hour, 0).In(now.Location()).Format("2006-01-02")
+		models[b.Key] = models[b.Key].Plus(b.Usage)
+		days[day] = days[day].Plus(b.Usage)
+	}
+
+	modelKeys := make([]string, 0, len(models))
+	for model := range models {
+		modelKeys = append(modelKeys, model)
+	}
+	sort.Slice(modelKeys, func(i, j int) bool {
+		a, b := models[modelKeys[i]].total(), models[modelKeys[j]].total()
+		if a != b {
+			return a > b
+		}
+		return modelKeys[i] < modelKeys[j]
+	})
+	for _, model := range modelKeys {
+		lines = append(lines, openusage.NewTextLine(model, models[model].value(), openusage.TextLineOptions{
+			Subtitle: "Model, last 7 days · " + models[model].breakdown(),
+		}))
+	}
+
+	dayKeys := make([]string, 0, len(days))
+	for day := range days {
+		dayKeys = append(dayKeys, day)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dayKeys)))
+	for _, day := range dayKeys {
+		t, _ := time.ParseInLocation("2006-01-02", day, now.Location())
+		lines = append(lines, openusage.NewTextLine(t.Format("Mon Jan 2"), days[day].value(), openusage.TextLineOptions{Subtitle: "Daily"}))
+	}
+	return lines
+}
+
+// windowLine totals local usage within the rate-limit window Codex last
+// reported, or within a rolling window when that report has expired.
+func windowLine(label string, st *state, window *rateWindow, fallback time.Duration, now time.Time) openusage.MetricLine {
+	if window != nil && time.Unix(window.ResetsAt, 0).After(now) {
+		start, end := window.start(), time.Unix(window.ResetsAt, 0)
+		subtitle := fmt.Sprintf("%.0f%% used · window %s – %s",
+			window.UsedPercent, formatWindowTime(start, now), formatWindowTime(end, now))
+		return openusage.NewTextLine(label, st.SumSince(start).value(), openusage.TextLineOptions{Subtitle: subtitle})
+	}
+	subtitle := "Last 5 hours"
+	if fallback == logscan.WeekWindow {
+		subtitle = "Last 7 days"
+	}
+	return openusage.NewTextLine(label, st.SumSince(now.Add(-fallback)).value(), openusage.TextLineOptions{Subtitle: subtitle})
+}
+
+// formatWindowTime shows the clock time, with the day when it is not today.
+func formatWindowTime(t, now time.Time) string {
+	t = t.In(now.Location())
+	if y, m, d := t.Date(); y == now.Year() && m == now.Month() && d == now.Day() {
+		return t.Format("15:04")
+	}
+	return t.Format("Mon 15:04")
+}
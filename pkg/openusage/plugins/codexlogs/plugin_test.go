@@ -0,0 +1,169 @@
+package codexlogs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+	"github.com/deicod/gopenusage/pkg/openusage/plugins/logscan"
+	"github.com/deicod/gopenusage/pkg/openusage/plugins/plugintest"
+)
+
+func turnContext(at time.Time, model string) string {
+	return fmt.Sprintf(`{"timestamp":%q,"type":"turn_context","payload":{"cwd":"/src/app","model":%q}}`,
+		at.Format(time.RFC3339Nano), model)
+}
+
+func tokenCount(at time.Time, total, input, cached, output int64, primaryUsed float64, primaryResetsIn int64) string {
+	return fmt.Sprintf(`{"timestamp":%q,"type":"event_msg","payload":{"type":"token_count","info":{"total_token_usage":{"total_tokens":%d},"last_token_usage":{"input_tokens":%d,"cached_input_tokens":%d,"output_tokens":%d,"reasoning_output_tokens":0,"total_tokens":%d}},"rate_limits":{"primary":{"used_percent":%g,"window_minutes":300,"resets_in_seconds":%d},"secondary":{"used_percent":12,"window_minutes":10080,"resets_in_seconds":345600}}}}`,
+		at.Format(time.RFC3339Nano), total, input, cached, output, input+output, primaryUsed, primaryResetsIn)
+}
+
+func TestQueryTotalsRollouts(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	first := plugintest.Now.Add(-2 * time.Hour)
+	plugintest.AppendLines(t, filepath.Join(root, "2026", "03", "10", "rollout-a.jsonl"),
+		`{"timestamp":"2026-03-10T10:29:00Z","type":"session_meta","payload":{"cwd":"/src/app"}}`,
+		turnContext(first, "gpt-5-codex"),
+		tokenCount(first.Add(time.Minute), 12_000, 10_000, 4_000, 2_000, 20, 3*3600),
+		tokenCount(first.Add(2*time.Minute), 12_000, 10_000, 4_000, 2_000, 20, 3*3600), // repeated
+		turnContext(first.Add(time.Hour), "gpt-5"),
+		tokenCount(first.Add(time.Hour+time.Minute), 20_000, 7_000, 0, 1_000, 35, 2*3600),
+	)
+	plugintest.AppendLines(t, filepath.Join(root, "2026", "03", "07", "rollout-b.jsonl"),
+		turnContext(plugintest.Now.Add(-72*time.Hour), "gpt-5-codex"),
+		tokenCount(plugintest.Now.Add(-72*time.Hour), 5_000, 4_000, 0, 1_000, 1, 3600),
+	)
+
+	env, err := pluginruntime.NewEnv("codex-logs", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEnv error: %v", err)
+	}
+	env.LogsDir = root
+	plugin := &Plugin{now: func() time.Time { return plugintest.Now }}
+
+	result, err := plugin.Query(context.Background(), env)
+	if err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if result.Source != root {
+		t.Fatalf("unexpected source: %s", result.Source)
+	}
+
+	// The newest report puts the session window at 08:31-13:31.
+	session := plugintest.FindLine(t, result.Lines, "Session")
+	if *session.Value != "20K tokens" {
+		t.Fatalf("unexpected session: %s", *session.Value)
+	}
+	if session.Subtitle == nil || *session.Subtitle != "35% used · window 08:31 – 13:31" {
+		t.Fatalf("unexpected session subtitle: %v", session.Subtitle)
+	}
+	if got := *plugintest.FindLine(t, result.Lines, "Weekly").Value; got != "25K tokens" {
+		t.Fatalf("unexpected weekly: %s", got)
+	}
+	if got := *plugintest.FindLine(t, result.Lines, "Today").Value; got != "20K tokens" {
+		t.Fatalf("unexpected today: %s", got)
+	}
+	if got := *plugintest.FindLine(t, result.Lines, "gpt-5-codex").Value; got != "17K tokens" {
+		t.Fatalf("unexpected model total: %s", got)
+	}
+	if got := *plugintest.FindLine(t, result.Lines, "Sat Mar 7").Value; got != "5K tokens" {
+		t.Fatalf("unexpected daily total: %s", got)
+	}
+
+	if !pluginruntime.FileExists(filepath.Join(env.PluginDataDir, logscan.StateFile)) {
+		t.Fatal("expected cursor file to be written")
+	}
+
+	// A second query only reads what was appended and keeps the model from
+	// the earlier turn_context.
+	plugintest.AppendLines(t, filepath.Join(root, "2026", "03", "10", "rollout-a.jsonl"),
+		tokenCount(plugintest.Now.Add(-time.Minute), 30_000, 9_000, 0, 1_000, 40, 2*3600))
+
+	result, err = (&Plugin{now: func() time.Time { return plugintest.Now }}).Query(context.Background(), env)
+	if err != nil {
+		t.Fatalf("second Query error: %v", err)
+	}
+	if got := *plugintest.FindLine(t, result.Lines, "Today").Value; got != "30K tokens" {
+		t.Fatalf("unexpected today after append: %s", got)
+	}
+	if got := *plugintest.FindLine(t, result.Lines, "gpt-5").Value; got != "18K tokens" {
+		t.Fatalf("unexpected gpt-5 total after append: %s", got)
+	}
+}
+
+func TestWindowLineFallsBackToRollingWindow(t *testing.T) {
+	t.Parallel()
+
+	st := &state{}
+	st.Restore(stateVersion)
+	st.Add(plugintest.Now.Add(-6*time.Hour), "gpt-5", usage{Input: 1_000})
+	st.Add(plugintest.Now.Add(-time.Hour), "gpt-5", usage{Input: 2_000})
+	expired := &rateWindow{UsedPercent: 90, WindowMinutes: 300, ResetsAt: plugintest.Now.Add(-time.Minute).Unix()}
+
+	line := windowLine("Session", st, expired, logscan.SessionWindow, plugintest.Now)
+	if *line.Value != "2K tokens" || *line.Subtitle != "Last 5 hours" {
+		t.Fatalf("unexpected line: %s (%s)", *line.Value, *line.Subtitle)
+	}
+}
+
+func TestQueryReportsMissingSessions(t *testing.T) {
+	t.Parallel()
+
+	env, err := pluginruntime.NewEnv("codex-logs", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEnv error: %v", err)
+	}
+	env.LogsDir = filepath.Join(t.TempDir(), "missing")
+
+	_, err = New().Query(context.Background(), env)
+	if detail := openusage.ClassifyError(err); detail == nil || detail.Code != openusage.ErrorNotConfigured || !strings.Contains(err.Error(), "no Codex sessions") {
+		t.Fatalf("expected missing sessions error, got %v", err)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	t.Parallel()
+
+	plugintest.Run(t, &Plugin{now: func() time.Time { return plugintest.Now }}, "testdata")
+}
+
+func TestQueryKeepsModelOfStaleRollouts(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	path := filepath.Join(root, "2026", "02", "28", "rollout-c.jsonl")
+	started := plugintest.Now.Add(-10 * 24 * time.Hour)
+	plugintest.AppendLines(t, path, turnContext(started, "gpt-5-codex"))
+	if err := os.Chtimes(path, started, started); err != nil {
+		t.Fatalf("Chtimes error: %v", err)
+	}
+
+	env, err := pluginruntime.NewEnv("codex-logs", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEnv error: %v", err)
+	}
+	env.LogsDir = root
+	plugin := &Plugin{now: func() time.Time { return plugintest.Now }}
+	if _, err := plugin.Query(context.Background(), env); err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+
+	// The session is resumed: usage lands in the file untouched for days.
+	plugintest.AppendLines(t, path, tokenCount(plugintest.Now.Add(-time.Minute), 3_000, 2_000, 0, 1_000, 5, 3600))
+	result, err := plugin.Query(context.Background(), env)
+	if err != nil {
+		t.Fatalf("second Query error: %v", err)
+	}
+	if got := *plugintest.FindLine(t, result.Lines, "gpt-5-codex").Value; got != "3K tokens" {
+		t.Fatalf("expected usage attributed to the earlier model, got %s", got)
+	}
+}
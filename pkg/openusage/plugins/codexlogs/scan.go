@@ -0,0 +1,191 @@
+package codexlogs
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+	"github.com/deicod/gopenusage/pkg/openusage/plugins/logscan"
+)
+
+// stateVersion 2 moved the scan state to pluginruntime.LogScan.
+const stateVersion = 2
+
+type usage struct {
+	Input       int64 `json:"input"` // includes cached input
+	CachedInput int64 `json:"cachedInput"`
+	Output      int64 `json:"output"` // includes reasoning
+	Reasoning   int64 `json:"reasoning"`
+}
+
+func (u usage) Plus(o usage) usage {
+	return usage{
+		Input:       u.Input + o.Input,
+		CachedInput: u.CachedInput + o.CachedInput,
+		Output:      u.Output + o.Output,
+		Reasoning:   u.Reasoning + o.Reasoning,
+	}
+}
+
+func (u usage) total() int64 {
+	return u.Input + u.Output
+}
+
+// bucket aggregates usage for one model, its Key, within one hour.
+type bucket = pluginruntime.LogBucket[string, usage]
+
+// fileCursor is the context needed to attribute the lines that follow where
+// parsing of one rollout file stopped.
+type fileCursor struct {
+	Model string `json:"model,omitempty"`
+	// Total is the last cumulative token count, used to skip repeated
+	// token_count events.
+	Total int64 `json:"total,omitempty"`
+}
+
+// rateWindow is a rate-limit window as reported in token_count events.
+type rateWindow struct {
+	UsedPercent   float64 `json:"usedPercent"`
+	WindowMinutes int64   `json:"windowMinutes"`
+	ResetsAt      int64   `json:"resetsAt"` // unix seconds
+}
+
+func (w *rateWindow) start() time.Time {
+	return time.Unix(w.ResetsAt, 0).Add(-time.Duration(w.WindowMinutes) * time.Minute)
+}
+
+// rateLimits is the most recent rate-limit report found in the logs.
+type rateLimits struct {
+	ObservedAt int64       `json:"observedAt"`
+	Primary    *rateWindow `json:"primary,omitempty"`
+	Secondary  *rateWindow `json:"secondary,omitempty"`
+}
+
+// state is persisted between queries so rollout files are only parsed from
+// where the previous query stopped.
+type state struct {
+	pluginruntime.LogScan[string, usage, fileCursor]
+	RateLimits *rateLimits `json:"rateLimits,omitempty"`
+}
+
+// update parses rollout lines appended under root since the last call.
+func (s *state) update(ctx context.Context, root string, now time.Time) error {
+	cutoff := now.Add(-logscan.Retention)
+	isRollout := func(path string) bool {
+		return strings.HasPrefix(filepath.Base(path), "rollout-") && filepath.Ext(path) == ".jsonl"
+	}
+	return s.Update(ctx, root, cutoff, isRollout, func(_ string, cursor *fileCursor, line []byte) {
+		s.addLine(line, cursor, cutoff)
+	})
+}
+
+type rolloutLine struct {
+	Timestamp string          `json:"timestamp"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+type tokenUsage struct {
+	InputTokens           int64 `json:"input_tokens"`
+	CachedInputTokens     int64 `json:"cached_input_tokens"`
+	OutputTokens          int64 `json:"output_tokens"`
+	ReasoningOutputTokens int64 `json:"reasoning_output_tokens"`
+	TotalTokens           int64 `json:"total_tokens"`
+}
+
+type reportedWindow struct {
+	UsedPercent     float64 `json:"used_percent"`
+	WindowMinutes   int64   `json:"window_minutes"`
+	ResetsAt        int64   `json:"resets_at"`
+	ResetsInSeconds int64   `json:"resets_in_seconds"`
+}
+
+type eventPayload struct {
+	Type  string `json:"type"`
+	Model string `json:"model"`
+	Info  *struct {
+		TotalTokenUsage *tokenUsage `json:"total_token_usage"`
+		LastTokenUsage  *tokenUsage `json:"last_token_usage"`
+	} `json:"info"`
+	RateLimits *struct {
+		Primary   *reportedWindow `json:"primary"`
+		Secondary *reportedWindow `json:"secondary"`
+	} `json:"rate_limits"`
+}
+
+func (s *state) addLine(line []byte, cursor *fileCursor, cutoff time.Time) {
+	var entry rolloutLine
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return
+	}
+	if entry.Type != "turn_context" && entry.Type != "event_msg" {
+		return
+	}
+	var payload eventPayload
+	if err := json.Unmarshal(entry.Payload, &payload); err != nil {
+		return
+	}
+
+	if entry.Type == "turn_context" {
+		if payload.Model != "" {
+			cursor.Model = payload.Model
+		}
+		return
+	}
+	if payload.Type != "token_count" {
+		return
+	}
+	at, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+	if err != nil {
+		return
+	}
+
+	if payload.RateLimits != nil {
+		s.observeRateLimits(at, payload.RateLimits.Primary, payload.RateLimits.Secondary)
+	}
+	if payload.Info == nil || payload.Info.LastTokenUsage == nil {
+		return
+	}
+	if total := payload.Info.TotalTokenUsage; total != nil {
+		if total.TotalTokens == cursor.Total {
+			return // repeated report without new usage
+		}
+		cursor.Total = total.TotalTokens
+	}
+	if at.Before(cutoff) {
+		return
+	}
+
+	model := cursor.Model
+	if model == "" {
+		model = "unknown"
+	}
+	last := payload.Info.LastTokenUsage
+	s.Add(at, model, usage{
+		Input:       last.InputTokens,
+		CachedInput: last.CachedInputTokens,
+		Output:      last.OutputTokens,
+		Reasoning:   last.ReasoningOutputTokens,
+	})
+}
+
+// observeRateLimits keeps the newest report across all files.
+func (s *state) observeRateLimits(at time.Time, primary, secondary *reportedWindow) {
+	if s.RateLimits != nil && s.RateLimits.ObservedAt > at.Unix() {
+		return
+	}
+	convert := func(w *reportedWindow) *rateWindow {
+		if w == nil || w.WindowMinutes <= 0 {
+			return nil
+		}
+		resetsAt := w.ResetsAt
+		if resetsAt == 0 {
+			resetsAt = at.Unix() + w.ResetsInSeconds
+		}
+		return &rateWindow{UsedPercent: w.UsedPercent, WindowMinutes: w.WindowMinutes, ResetsAt: resetsAt}
+	}
+	s.RateLimits = &rateLimits{ObservedAt: at.Unix(), Primary: convert(primary), Secondary: convert(secondary)}
+}
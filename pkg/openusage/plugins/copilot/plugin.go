@@ -38,15 +38,15 @@ func (p *Plugin) ID() string {
 func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.QueryResult, error) {
 	cred := p.loadToken(env)
 	if cred == nil {
-		return openusage.QueryResult{}, fmt.Errorf("not logged in; run `gh auth login` first")
+		return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorAuthRequired, "not logged in", "run `gh auth login` first")
 	}
 
 	token := cred.Token
 	source := cred.Source
 
-	resp, err := p.fetchUsage(ctx, token)
+	resp, err := p.fetchUsage(ctx, env, token)
 	if err != nil {
-		return openusage.QueryResult{}, fmt.Errorf("usage request failed, check your connection")
+		return openusage.QueryResult{}, networkError(err)
 	}
 
 	if pluginruntime.IsAuthStatus(resp.Status) {
@@ -55,9 +55,9 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 			p.clearCachedToken(env)
 			fallback := p.loadTokenFromGhCLI(env)
 			if fallback != nil {
-				resp, err = p.fetchUsage(ctx, fallback.Token)
+				resp, err = p.fetchUsage(ctx, env, fallback.Token)
 				if err != nil {
-					return openusage.QueryResult{}, fmt.Errorf("usage request failed, check your connection")
+					return openusage.QueryResult{}, networkError(err)
 				}
 				if resp.Status >= 200 && resp.Status < 300 {
 					p.saveToken(env, fallback.Token)
@@ -67,12 +67,12 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 			}
 		}
 		if pluginruntime.IsAuthStatus(resp.Status) {
-			return openusage.QueryResult{}, fmt.Errorf("token invalid; run `gh auth login` to re-authenticate")
+			return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorAuthExpired, "token invalid", "run `gh auth login` to re-authenticate")
 		}
 	}
 
 	if resp.Status < 200 || resp.Status >= 300 {
-		return openusage.QueryResult{}, fmt.Errorf("usage request failed (HTTP %d), try again later", resp.Status)
+		return openusage.QueryResult{}, openusage.UpstreamStatusError(resp.Status, "try again later")
 	}
 
 	if source == "gh-cli" {
@@ -81,7 +81,7 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 
 	data, ok := pluginruntime.TryParseJSONMap(resp.Body)
 	if !ok {
-		return openusage.QueryResult{}, fmt.Errorf("usage response invalid, try again later")
+		return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorParse, "usage response invalid", "try again later")
 	}
 
 	plan := ""
@@ -122,6 +122,13 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 	return openusage.QueryResult{Plan: plan, Lines: lines}, nil
 }
 
+// networkError classifies a failed usage request, keeping err as the cause.
+func networkError(err error) error {
+	netErr := openusage.NewPluginError(openusage.ErrorNetwork, "usage request failed", "check your connection")
+	netErr.Err = err
+	return netErr
+}
+
 func makeProgressLine(label string, snapshot map[string]any, resetDate any) (openusage.MetricLine, bool) {
 	remaining, ok := pluginruntime.GetNumber(snapshot, "percent_remaining")
 	if !ok {
@@ -153,23 +160,35 @@ func makeLimitedProgressLine(label string, remainingValue, totalValue, resetDate
 	return openusage.NewProgressLine(label, usedPercent, 100, openusage.PercentFormat(), opts), true
 }
 
+// statePath is the plugin's own token cache. A configured credentials file
+// is only ever read.
 func (p *Plugin) statePath(env *pluginruntime.Env) string {
 	return filepath.Join(env.PluginDataDir, "auth.json")
 }
 
 func (p *Plugin) loadToken(env *pluginruntime.Env) *credential {
+	// An explicitly configured token file wins over ambient credentials.
+	if env.CredentialsPath != "" {
+		if c := loadTokenFromFile(env.CredentialsPath, "credentials"); c != nil {
+			return c
+		}
+	}
+	// Accounts never borrow the default login from the keychain or gh.
+	if env.Account != "" {
+		return nil
+	}
 	if c := p.loadTokenFromKeychain(env); c != nil {
 		return c
 	}
 	if c := p.loadTokenFromGhCLI(env); c != nil {
 		return c
 	}
-	return p.loadTokenFromState(env)
+	return loadTokenFromFile(p.statePath(env), "state")
 }
 
 func (p *Plugin) loadTokenFromKeychain(env *pluginruntime.Env) *credential {
 	_ = env
-	raw, err := pluginruntime.ReadKeychainGenericPassword(keychainService)
+	raw, err := env.ReadKeychainGenericPassword(keychainService)
 	if err != nil {
 		return nil
 	}
@@ -186,7 +205,7 @@ func (p *Plugin) loadTokenFromKeychain(env *pluginruntime.Env) *credential {
 
 func (p *Plugin) loadTokenFromGhCLI(env *pluginruntime.Env) *credential {
 	_ = env
-	if raw, err := pluginruntime.ReadKeychainGenericPassword(ghKeychain); err == nil {
+	if raw, err := env.ReadKeychainGenericPassword(ghKeychain); err == nil {
 		if token := normalizeGhToken(raw); token != "" {
 			return &credential{Token: token, Source: "gh-cli"}
 		}
@@ -194,7 +213,7 @@ func (p *Plugin) loadTokenFromGhCLI(env *pluginruntime.Env) *credential {
 
 	// Cross-platform fallback: ask gh directly for the token.
 	// This is needed on systems where gh uses encrypted storage
-	// that cannot be read through the macOS keychain path.
+	// that cannot be read through the credential store.
 	for _, args := range [][]string{
 		{"auth", "token", "--hostname", "github.com"},
 		{"auth", "token", "-h", "github.com"},
@@ -230,8 +249,8 @@ func normalizeGhToken(raw string) string {
 	return strings.TrimSpace(token)
 }
 
-func (p *Plugin) loadTokenFromState(env *pluginruntime.Env) *credential {
-	text, err := pluginruntime.ReadText(p.statePath(env))
+func loadTokenFromFile(path, source string) *credential {
+	text, err := pluginruntime.ReadText(path)
 	if err != nil {
 		return nil
 	}
@@ -243,16 +262,20 @@ func (p *Plugin) loadTokenFromState(env *pluginruntime.Env) *credential {
 	if !ok || strings.TrimSpace(token) == "" {
 		return nil
 	}
-	return &credential{Token: token, Source: "state"}
+	return &credential{Token: token, Source: source}
 }
 
 func (p *Plugin) saveToken(env *pluginruntime.Env, token string) {
-	_ = pluginruntime.WriteKeychainGenericPassword(keychainService, fmt.Sprintf(`{"token":%q}`, token))
+	if env.Account == "" {
+		_ = env.WriteKeychainGenericPassword(keychainService, fmt.Sprintf(`{"token":%q}`, token))
+	}
 	_ = p.writeState(env, map[string]any{"token": token})
 }
 
 func (p *Plugin) clearCachedToken(env *pluginruntime.Env) {
-	_ = pluginruntime.DeleteKeychainGenericPassword(keychainService)
+	if env.Account == "" {
+		_ = env.DeleteKeychainGenericPassword(keychainService)
+	}
 	_ = p.writeState(env, nil)
 }
 
@@ -264,8 +287,8 @@ func (p *Plugin) writeState(env *pluginruntime.Env, value any) error {
 	return pluginruntime.WriteText(p.statePath(env), string(data))
 }
 
-func (p *Plugin) fetchUsage(ctx context.Context, token string) (pluginruntime.HTTPResponse, error) {
-	return pluginruntime.DoHTTPRequest(ctx, pluginruntime.HTTPRequest{
+func (p *Plugin) fetchUsage(ctx context.Context, env *pluginruntime.Env, token string) (pluginruntime.HTTPResponse, error) {
+	return env.DoHTTPRequest(ctx, pluginruntime.HTTPRequest{
 		Method: "GET",
 		URL:    usageURL,
 		Headers: map[string]string{
@@ -0,0 +1,43 @@
+package copilot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+	"github.com/deicod/gopenusage/pkg/openusage/plugins/plugintest"
+)
+
+func TestGolden(t *testing.T) {
+	t.Parallel()
+
+	plugintest.Run(t, New(), "testdata")
+}
+
+func TestSaveTokenLeavesConfiguredCredentials(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	configured := filepath.Join(dir, "token.json")
+	if err := os.WriteFile(configured, []byte(`{"token":"configured"}`), 0o600); err != nil {
+		t.Fatalf("write credentials: %v", err)
+	}
+	env := &pluginruntime.Env{
+		PluginDataDir:   filepath.Join(dir, "plugins_data", "copilot@work"),
+		CredentialsPath: configured,
+		Account:         "work",
+	}
+
+	p := New()
+	p.saveToken(env, "cached")
+	if text, err := pluginruntime.ReadText(configured); err != nil || text != `{"token":"configured"}` {
+		t.Fatalf("expected configured credentials untouched, got %q (%v)", text, err)
+	}
+	if cred := p.loadToken(env); cred == nil || cred.Token != "configured" {
+		t.Fatalf("expected configured token, got %+v", cred)
+	}
+	if cred := loadTokenFromFile(p.statePath(env), "state"); cred == nil || cred.Token != "cached" {
+		t.Fatalf("expected cached token in plugin data dir, got %+v", cred)
+	}
+}
@@ -12,7 +12,6 @@ import (
 )
 
 const (
-	stateDBPath      = "~/Library/Application Support/Cursor/User/globalStorage/state.vscdb"
 	baseURL          = "https://api2.cursor.sh"
 	usageURL         = baseURL + "/aiserver.v1.DashboardService/GetCurrentPeriodUsage"
 	planURL          = baseURL + "/aiserver.v1.DashboardService/GetPlanInfo"
@@ -23,6 +22,8 @@ const (
 	defaultBillingMs = int64((30 * 24 * time.Hour) / time.Millisecond)
 )
 
+var app = pluginruntime.EditorApp{Name: "Cursor", FlatpakID: "co.anysphere.cursor", SnapName: "cursor"}
+
 type Plugin struct{}
 
 func New() *Plugin {
@@ -34,11 +35,31 @@ func (p *Plugin) ID() string {
 }
 
 func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.QueryResult, error) {
-	accessToken := p.readStateValue("cursorAuth/accessToken")
-	refreshToken := p.readStateValue("cursorAuth/refreshToken")
+	dbPath := stateDBPath(env)
+	result, err := p.query(ctx, env, dbPath)
+	result.Source = dbPath
+	return result, err
+}
+
+// stateDBPath is the configured state database, or the first one found in
+// the platform's usual locations.
+func stateDBPath(env *pluginruntime.Env) string {
+	if path := env.CredentialsPathOr(""); path != "" {
+		return path
+	}
+	path, _ := app.FindStateDB()
+	return path
+}
+
+func (p *Plugin) query(ctx context.Context, env *pluginruntime.Env, dbPath string) (openusage.QueryResult, error) {
+	accessToken := p.readStateValue(dbPath, "cursorAuth/accessToken")
+	refreshToken := p.readStateValue(dbPath, "cursorAuth/refreshToken")
 
 	if accessToken == "" && refreshToken == "" {
-		return openusage.QueryResult{}, fmt.Errorf("not logged in; sign in via cursor app")
+		if !pluginruntime.FileExists(dbPath) {
+			return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorAuthRequired, "cursor state database not found at "+dbPath, "sign in via cursor app or set its path")
+		}
+		return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorAuthRequired, "not logged in", "sign in via cursor app")
 	}
 
 	nowMs := time.Now().UnixMilli()
@@ -51,7 +72,7 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 		} else if refreshed != "" {
 			accessToken = refreshed
 		} else if accessToken == "" {
-			return openusage.QueryResult{}, fmt.Errorf("not logged in; sign in via cursor app")
+			return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorAuthRequired, "not logged in", "sign in via cursor app")
 		}
 	}
 
@@ -62,12 +83,12 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 			if token != "" {
 				useToken = token
 			}
-			resp, reqErr := p.connectPost(ctx, usageURL, useToken)
+			resp, reqErr := p.connectPost(ctx, env, usageURL, useToken)
 			if reqErr != nil {
 				if didRefresh {
-					return pluginruntime.HTTPResponse{}, fmt.Errorf("usage request failed after refresh, try again")
+					return pluginruntime.HTTPResponse{}, openusage.NewPluginError(openusage.ErrorNetwork, "usage request failed after refresh", "try again")
 				}
-				return pluginruntime.HTTPResponse{}, fmt.Errorf("usage request failed, check your connection")
+				return pluginruntime.HTTPResponse{}, openusage.NewPluginError(openusage.ErrorNetwork, "usage request failed", "check your connection")
 			}
 			return resp, nil
 		},
@@ -88,15 +109,15 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 	}
 
 	if pluginruntime.IsAuthStatus(usageResp.Status) {
-		return openusage.QueryResult{}, fmt.Errorf("token expired; sign in via cursor app")
+		return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorAuthExpired, "token expired", "sign in via cursor app")
 	}
 	if usageResp.Status < 200 || usageResp.Status >= 300 {
-		return openusage.QueryResult{}, fmt.Errorf("usage request failed (HTTP %d), try again later", usageResp.Status)
+		return openusage.QueryResult{}, openusage.UpstreamStatusError(usageResp.Status, "try again later")
 	}
 
 	usage, ok := pluginruntime.TryParseJSONMap(usageResp.Body)
 	if !ok {
-		return openusage.QueryResult{}, fmt.Errorf("usage response invalid, try again later")
+		return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorParse, "usage response invalid", "try again later")
 	}
 
 	enabled, okEnabled := pluginruntime.GetBool(usage, "enabled")
@@ -106,7 +127,7 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 	}
 
 	planName := ""
-	if planResp, err := p.connectPost(ctx, planURL, accessToken); err == nil && planResp.Status >= 200 && planResp.Status < 300 {
+	if planResp, err := p.connectPost(ctx, env, planURL, accessToken); err == nil && planResp.Status >= 200 && planResp.Status < 300 {
 		if planData, ok := pluginruntime.TryParseJSONMap(planResp.Body); ok {
 			if planInfo, ok := pluginruntime.GetMap(planData, "planInfo"); ok {
 				planName, _ = pluginruntime.GetString(planInfo, "planName")
@@ -115,7 +136,7 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 	}
 
 	var creditGrants map[string]any
-	if creditsResp, err := p.connectPost(ctx, creditsURL, accessToken); err == nil && creditsResp.Status >= 200 && creditsResp.Status < 300 {
+	if creditsResp, err := p.connectPost(ctx, env, creditsURL, accessToken); err == nil && creditsResp.Status >= 200 && creditsResp.Status < 300 {
 		creditGrants, _ = pluginruntime.TryParseJSONMap(creditsResp.Body)
 	}
 
@@ -123,7 +144,7 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 
 	limit, hasLimit := pluginruntime.GetNumber(planUsage, "limit")
 	if !hasLimit {
-		return openusage.QueryResult{}, fmt.Errorf("plan usage limit missing from API response")
+		return openusage.QueryResult{}, openusage.NewPluginError(openusage.ErrorParse, "plan usage limit missing from API response", "")
 	}
 
 	planUsed, hasPlanUsed := pluginruntime.GetNumber(planUsage, "totalSpend")
@@ -185,28 +206,16 @@ func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.Q
 	return openusage.QueryResult{Plan: plan, Lines: lines}, nil
 }
 
-func (p *Plugin) readStateValue(key string) string {
-	sql := fmt.Sprintf("SELECT value FROM ItemTable WHERE key = '%s' LIMIT 1;", key)
-	jsonRows, err := pluginruntime.SQLiteQuery(stateDBPath, sql)
+func (p *Plugin) readStateValue(dbPath, key string) string {
+	value, _, err := pluginruntime.ItemTable{Path: dbPath}.Get(key)
 	if err != nil {
 		return ""
 	}
-	rows, ok := pluginruntime.TryParseJSONArray(jsonRows)
-	if !ok || len(rows) == 0 {
-		return ""
-	}
-	row, ok := pluginruntime.Map(rows[0])
-	if !ok {
-		return ""
-	}
-	value, _ := pluginruntime.GetString(row, "value")
 	return value
 }
 
-func (p *Plugin) writeStateValue(key, value string) bool {
-	escaped := strings.ReplaceAll(value, "'", "''")
-	sql := fmt.Sprintf("INSERT OR REPLACE INTO ItemTable (key, value) VALUES ('%s', '%s');", key, escaped)
-	return pluginruntime.SQLiteExec(stateDBPath, sql) == nil
+func (p *Plugin) writeStateValue(dbPath, key, value string) bool {
+	return pluginruntime.ItemTable{Path: dbPath}.Set(key, value) == nil
 }
 
 func (p *Plugin) tokenExpiration(token string) (int64, bool) {
@@ -235,7 +244,7 @@ func (p *Plugin) refreshToken(ctx context.Context, env *pluginruntime.Env, refre
 	}
 
 	body := `{"grant_type":"refresh_token","client_id":"` + clientID + `","refresh_token":"` + refreshToken + `"}`
-	resp, err := pluginruntime.DoHTTPRequest(ctx, pluginruntime.HTTPRequest{
+	resp, err := env.DoHTTPRequest(ctx, pluginruntime.HTTPRequest{
 		Method:   "POST",
 		URL:      refreshURL,
 		Headers:  map[string]string{"Content-Type": "application/json"},
@@ -250,10 +259,10 @@ func (p *Plugin) refreshToken(ctx context.Context, env *pluginruntime.Env, refre
 	if resp.Status == 400 || resp.Status == 401 {
 		if data, ok := pluginruntime.TryParseJSONMap(resp.Body); ok {
 			if shouldLogout, ok := pluginruntime.GetBool(data, "shouldLogout"); ok && shouldLogout {
-				return "", fmt.Errorf("session expired; sign in via cursor app")
+				return "", openusage.NewPluginError(openusage.ErrorAuthExpired, "session expired", "sign in via cursor app")
 			}
 		}
-		return "", fmt.Errorf("token expired; sign in via cursor app")
+		return "", openusage.NewPluginError(openusage.ErrorAuthExpired, "token expired", "sign in via cursor app")
 	}
 
 	if resp.Status < 200 || resp.Status >= 300 {
@@ -265,19 +274,19 @@ func (p *Plugin) refreshToken(ctx context.Context, env *pluginruntime.Env, refre
 		return "", nil
 	}
 	if shouldLogout, ok := pluginruntime.GetBool(data, "shouldLogout"); ok && shouldLogout {
-		return "", fmt.Errorf("session expired; sign in via cursor app")
+		return "", openusage.NewPluginError(openusage.ErrorAuthExpired, "session expired", "sign in via cursor app")
 	}
 	newAccessToken, ok := pluginruntime.GetString(data, "access_token")
 	if !ok || strings.TrimSpace(newAccessToken) == "" {
 		return "", nil
 	}
 
-	p.writeStateValue("cursorAuth/accessToken", newAccessToken)
+	p.writeStateValue(stateDBPath(env), "cursorAuth/accessToken", newAccessToken)
 	return newAccessToken, nil
 }
 
-func (p *Plugin) connectPost(ctx context.Context, endpoint, token string) (pluginruntime.HTTPResponse, error) {
-	return pluginruntime.DoHTTPRequest(ctx, pluginruntime.HTTPRequest{
+func (p *Plugin) connectPost(ctx context.Context, env *pluginruntime.Env, endpoint, token string) (pluginruntime.HTTPResponse, error) {
+	return env.DoHTTPRequest(ctx, pluginruntime.HTTPRequest{
 		Method: "POST",
 		URL:    endpoint,
 		Headers: map[string]string{
@@ -0,0 +1,70 @@
+// Package logscan holds what the plugins totaling local session logs share:
+// their time windows and a query around a persisted pluginruntime.LogScan.
+package logscan
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+const (
+	SessionWindow = 5 * time.Hour
+	WeekWindow    = 7 * 24 * time.Hour
+	// Retention covers the weekly window plus the rest of today.
+	Retention = WeekWindow + 24*time.Hour
+	// StateFile holds the scan state in the plugin data dir.
+	StateFile = "cursor.json"
+)
+
+// State is a plugin's scan state: a struct embedding a pluginruntime.LogScan,
+// possibly with further fields.
+type State[S any] interface {
+	*S
+	Restore(version int) bool
+}
+
+// Logs describes where a plugin reads its logs.
+type Logs struct {
+	Root string
+	// Name is what the logs are called in errors, e.g. "transcripts".
+	Name string
+	// Missing and Hint make up the error when Root does not exist.
+	Missing string
+	Hint    string
+}
+
+// Query loads the plugin's scan state, lets update parse what was appended
+// below logs.Root, saves the state and returns the lines build makes of it.
+// Saved state that is unreadable or of another version starts over from the
+// zero value.
+func Query[S any, P State[S]](ctx context.Context, env *pluginruntime.Env, logs Logs, version int, update func(P) error, build func(P) []openusage.MetricLine) (openusage.QueryResult, error) {
+	root := logs.Root
+	if !pluginruntime.FileExists(root) {
+		return openusage.QueryResult{Source: root}, openusage.NewPluginError(openusage.ErrorNotConfigured, fmt.Sprintf("%s at %s", logs.Missing, root), logs.Hint)
+	}
+
+	statePath := filepath.Join(env.PluginDataDir, StateFile)
+	st := P(new(S))
+	if err := pluginruntime.ReadJSONFile(statePath, st); err != nil || !st.Restore(version) {
+		st = P(new(S))
+		st.Restore(version)
+	}
+	if err := update(st); err != nil {
+		if ctx.Err() != nil {
+			return openusage.QueryResult{Source: root}, fmt.Errorf("read %s: %w", logs.Name, err)
+		}
+		parseErr := openusage.NewPluginError(openusage.ErrorParse, fmt.Sprintf("read %s: %v", logs.Name, err), "")
+		parseErr.Err = err
+		return openusage.QueryResult{Source: root}, parseErr
+	}
+	if err := pluginruntime.WriteJSONFile(statePath, st); err != nil && env.Logger != nil {
+		env.Logger.Printf("save cursor: %v", err)
+	}
+
+	return openusage.QueryResult{Lines: build(st), Source: root}, nil
+}
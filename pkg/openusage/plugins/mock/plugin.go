@@ -8,10 +8,12 @@ import (
 	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
 )
 
-type Plugin struct{}
+type Plugin struct {
+	now func() time.Time
+}
 
 func New() *Plugin {
-	return &Plugin{}
+	return &Plugin{now: time.Now}
 }
 
 func (p *Plugin) ID() string {
@@ -21,8 +23,9 @@ func (p *Plugin) ID() string {
 func (p *Plugin) Query(_ context.Context, _ *pluginruntime.Env) (openusage.QueryResult, error) {
 	fifteenDays := 15 * 24 * time.Hour
 	thirtyDaysMs := int64((30 * 24 * time.Hour) / time.Millisecond)
-	resetsAt := time.Now().Add(fifteenDays).UTC().Format("2006-01-02T15:04:05.000Z")
-	pastReset := time.Now().Add(-time.Minute).UTC().Format("2006-01-02T15:04:05.000Z")
+	now := p.now()
+	resetsAt := now.Add(fifteenDays).UTC().Format("2006-01-02T15:04:05.000Z")
+	pastReset := now.Add(-time.Minute).UTC().Format("2006-01-02T15:04:05.000Z")
 
 	lines := []openusage.MetricLine{
 		openusage.NewProgressLine("Ahead pace", 30, 100, openusage.PercentFormat(), openusage.ProgressLineOptions{ResetsAt: resetsAt, PeriodDurationMs: thirtyDaysMs}),
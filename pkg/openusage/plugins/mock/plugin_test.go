@@ -0,0 +1,15 @@
+package mock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage/plugins/plugintest"
+)
+
+func TestGolden(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	plugintest.Run(t, &Plugin{now: func() time.Time { return now }}, "testdata")
+}
@@ -0,0 +1,46 @@
+package plugintest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+)
+
+// Now is a fixed clock reading for plugins whose results depend on the time,
+// such as those totaling local logs.
+var Now = time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+
+// AppendLines appends lines to the file at path, creating it and its parent
+// dirs as needed.
+func AppendLines(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	for _, line := range lines {
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+}
+
+// FindLine returns the line labeled label, failing the test when there is
+// none.
+func FindLine(t *testing.T, lines []openusage.MetricLine, label string) openusage.MetricLine {
+	t.Helper()
+	for _, line := range lines {
+		if line.Label == label {
+			return line
+		}
+	}
+	t.Fatalf("line %q not found", label)
+	return openusage.MetricLine{}
+}
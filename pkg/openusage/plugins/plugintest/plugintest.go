@@ -0,0 +1,242 @@
+// Package plugintest runs plugins against recorded fixtures and compares
+// their results with golden files.
+//
+// Every directory in a plugin's testdata dir is one case and may contain:
+//
+//   - cassette.json: recorded HTTP traffic (a pluginruntime.Cassette);
+//   - credentials.json: copied to a temp dir and used as Env.CredentialsPath;
+//   - state.vscdb.json: ItemTable rows of a state database used as
+//     Env.CredentialsPath;
+//   - logs/: copied to a temp dir and used as Env.LogsDir;
+//   - ls.json: the pluginruntime.LSDiscoverResult language server discovery
+//     returns;
+//   - account: the account the case runs as, or empty for the default
+//     instance;
+//   - golden.json: the expected result.
+//
+// Cases without an account file run as the account "plugintest", so plugins
+// only use the fixture credentials and never the keychain or CLI logins of the
+// machine running the tests. Only cases whose fixtures cover every source the
+// plugin reads should run as the default instance. Requests missing from the
+// cassette fail like an unreachable host.
+//
+// Run the tests with -update to rewrite golden.json from the current results,
+// or with -record to query the live services and rewrite cassette.json and
+// golden.json; PLUGINTEST_CREDENTIALS then replaces the fixture credentials.
+// Recorded cassettes are redacted, but review them before committing.
+package plugintest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+const caseTimeout = 30 * time.Second
+
+var (
+	update = flag.Bool("update", false, "rewrite golden.json files from the current results")
+	record = flag.Bool("record", false, "query live services and rewrite cassette.json and golden.json files")
+)
+
+// Golden is the content of golden.json.
+type Golden struct {
+	Plan        string                 `json:"plan,omitempty"`
+	Lines       []openusage.MetricLine `json:"lines,omitempty"`
+	Source      string                 `json:"source,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	ErrorDetail *openusage.PluginError `json:"errorDetail,omitempty"`
+}
+
+// Run runs plugin once per case directory in dir as a subtest.
+func Run(t *testing.T, plugin openusage.Plugin, dir string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read cases: %v", err)
+	}
+	cases := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		cases++
+		caseDir := filepath.Join(dir, entry.Name())
+		t.Run(entry.Name(), func(t *testing.T) {
+			if !*record {
+				t.Parallel()
+			}
+			runCase(t, plugin, caseDir)
+		})
+	}
+	if cases == 0 {
+		t.Fatalf("no cases in %s", dir)
+	}
+}
+
+func runCase(t *testing.T, plugin openusage.Plugin, caseDir string) {
+	tmp := t.TempDir()
+	env, err := pluginruntime.NewEnv(plugin.ID(), filepath.Join(tmp, "data"))
+	if err != nil {
+		t.Fatalf("NewEnv error: %v", err)
+	}
+	env.Logger = log.New(io.Discard, "", 0)
+	if env.Account, err = fixtureAccount(caseDir); err != nil {
+		t.Fatalf("account fixture: %v", err)
+	}
+	if env.CredentialsPath, err = fixtureCredentials(caseDir, tmp); err != nil {
+		t.Fatalf("credentials fixture: %v", err)
+	}
+	if env.LogsDir, err = fixtureLogs(caseDir, tmp); err != nil {
+		t.Fatalf("logs fixture: %v", err)
+	}
+
+	var recorder *pluginruntime.Recorder
+	var replayer *pluginruntime.Replayer
+	if *record {
+		recorder = &pluginruntime.Recorder{}
+		env.Transport = recorder
+		if path := os.Getenv("PLUGINTEST_CREDENTIALS"); path != "" {
+			env.CredentialsPath = path
+		}
+	} else {
+		cassette := &pluginruntime.Cassette{}
+		if path := filepath.Join(caseDir, "cassette.json"); pluginruntime.FileExists(path) {
+			if cassette, err = pluginruntime.LoadCassette(path); err != nil {
+				t.Fatalf("LoadCassette error: %v", err)
+			}
+		}
+		replayer = pluginruntime.NewReplayer(cassette)
+		env.Transport = replayer
+		if env.LSDiscover, err = fixtureLS(caseDir); err != nil {
+			t.Fatalf("ls fixture: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+	defer cancel()
+	result, queryErr := plugin.Query(ctx, env)
+
+	got := Golden{Plan: result.Plan, Lines: result.Lines, Source: strings.ReplaceAll(result.Source, tmp, "$TMP")}
+	if queryErr != nil {
+		got.Error = strings.ReplaceAll(queryErr.Error(), tmp, "$TMP")
+		got.ErrorDetail = openusage.ClassifyError(queryErr)
+	}
+	data, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatalf("encode result: %v", err)
+	}
+	data = append(data, '\n')
+
+	goldenPath := filepath.Join(caseDir, "golden.json")
+	if recorder != nil {
+		if err := recorder.Cassette().Save(filepath.Join(caseDir, "cassette.json")); err != nil {
+			t.Fatalf("save cassette: %v", err)
+		}
+	}
+	if *update || *record {
+		if err := os.WriteFile(goldenPath, data, 0o644); err != nil {
+			t.Fatalf("write golden: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("read golden (run with -update to create it): %v", err)
+	}
+	if string(want) != string(data) {
+		t.Errorf("result differs from %s (run with -update to accept):\n got: %s\nwant: %s", goldenPath, data, want)
+	}
+	for _, unused := range replayer.Unused() {
+		t.Errorf("cassette interaction not requested: %s %s", unused.Request.Method, unused.Request.URL)
+	}
+}
+
+// fixtureAccount returns the content of the account file, or "plugintest"
+// when the case has none.
+func fixtureAccount(caseDir string) (string, error) {
+	text, err := pluginruntime.ReadText(filepath.Join(caseDir, "account"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "plugintest", nil
+	}
+	return strings.TrimSpace(text), err
+}
+
+// fixtureCredentials copies credentials.json, or builds a state database from
+// state.vscdb.json, into tmp and returns its path.
+func fixtureCredentials(caseDir, tmp string) (string, error) {
+	if text, err := pluginruntime.ReadText(filepath.Join(caseDir, "credentials.json")); err == nil {
+		path := filepath.Join(tmp, "credentials.json")
+		return path, pluginruntime.WriteText(path, text)
+	}
+
+	text, err := pluginruntime.ReadText(filepath.Join(caseDir, "state.vscdb.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var rows map[string]string
+	if err := json.Unmarshal([]byte(text), &rows); err != nil {
+		return "", err
+	}
+	path := filepath.Join(tmp, "state.vscdb")
+	if err := pluginruntime.WriteText(path, ""); err != nil {
+		return "", err
+	}
+	if err := pluginruntime.SQLiteExec(path, "CREATE TABLE ItemTable (key TEXT UNIQUE ON CONFLICT REPLACE, value BLOB)"); err != nil {
+		return "", err
+	}
+	for key, value := range rows {
+		if err := (pluginruntime.ItemTable{Path: path}).Set(key, value); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+// fixtureLogs copies the logs dir into tmp and returns its path.
+func fixtureLogs(caseDir, tmp string) (string, error) {
+	src := filepath.Join(caseDir, "logs")
+	if !pluginruntime.FileExists(src) {
+		return "", nil
+	}
+	path := filepath.Join(tmp, "logs")
+	return path, os.CopyFS(path, os.DirFS(src))
+}
+
+// fixtureLS returns a discovery that serves ls.json, or finds no language
+// server when the case has none.
+func fixtureLS(caseDir string) (func(pluginruntime.LSDiscoverOptions) (*pluginruntime.LSDiscoverResult, error), error) {
+	text, err := pluginruntime.ReadText(filepath.Join(caseDir, "ls.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return func(pluginruntime.LSDiscoverOptions) (*pluginruntime.LSDiscoverResult, error) {
+			return nil, errors.New("no language server in fixture")
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result pluginruntime.LSDiscoverResult
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		return nil, err
+	}
+	return func(pluginruntime.LSDiscoverOptions) (*pluginruntime.LSDiscoverResult, error) {
+		found := result
+		return &found, nil
+	}, nil
+}
@@ -3,7 +3,7 @@ package windsurf
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"strings"
 	"time"
 
@@ -16,6 +16,8 @@ const lsService = "exa.language_server_pb.LanguageServerService"
 type variant struct {
 	Marker  string
 	IdeName string
+	App     pluginruntime.EditorApp
+	// StateDB overrides the state database location derived from App.
 	StateDB string
 }
 
@@ -23,20 +25,29 @@ var variants = []variant{
 	{
 		Marker:  "windsurf",
 		IdeName: "windsurf",
-		StateDB: "~/Library/Application Support/Windsurf/User/globalStorage/state.vscdb",
+		App:     pluginruntime.EditorApp{Name: "Windsurf", FlatpakID: "com.codeium.windsurf", SnapName: "windsurf"},
 	},
 	{
 		Marker:  "windsurf-next",
 		IdeName: "windsurf-next",
-		StateDB: "~/Library/Application Support/Windsurf - Next/User/globalStorage/state.vscdb",
+		App:     pluginruntime.EditorApp{Name: "Windsurf - Next"},
 	},
 }
 
+func (v variant) stateDBPath() string {
+	if v.StateDB != "" {
+		return v.StateDB
+	}
+	path, _ := v.App.FindStateDB()
+	return path
+}
+
 type Plugin struct{}
 
 type variantResult struct {
-	Plan  string
-	Lines []openusage.MetricLine
+	Plan   string
+	Lines  []openusage.MetricLine
+	Source string
 }
 
 func New() *Plugin {
@@ -47,37 +58,52 @@ func (p *Plugin) ID() string {
 	return "windsurf"
 }
 
-func (p *Plugin) Query(ctx context.Context, _ *pluginruntime.Env) (openusage.QueryResult, error) {
-	for _, v := range variants {
-		result := p.probeVariant(ctx, v)
+func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.QueryResult, error) {
+	candidates := variants
+	if env.CredentialsPath != "" {
+		// A configured state database pins the stable variant to that file.
+		pinned := variants[0]
+		pinned.StateDB = env.CredentialsPathOr("")
+		candidates = []variant{pinned}
+	}
+	for _, v := range candidates {
+		result, err := p.probeVariant(ctx, env, v)
+		if err != nil {
+			return openusage.QueryResult{Source: v.stateDBPath()}, err
+		}
 		if result != nil {
-			return openusage.QueryResult{Plan: result.Plan, Lines: result.Lines}, nil
+			return openusage.QueryResult{Plan: result.Plan, Lines: result.Lines, Source: result.Source}, nil
 		}
 	}
 
-	return openusage.QueryResult{}, fmt.Errorf("start windsurf and try again")
+	// Point at the state database the stable variant reads once it runs.
+	return openusage.QueryResult{Source: candidates[0].stateDBPath()}, openusage.NewPluginError(openusage.ErrorNotRunning, "windsurf is not running", "start windsurf and try again")
 }
 
-func (p *Plugin) probeVariant(ctx context.Context, v variant) *variantResult {
-	discovery, err := pluginruntime.DiscoverLS(pluginruntime.LSDiscoverOptions{
+func (p *Plugin) probeVariant(ctx context.Context, env *pluginruntime.Env, v variant) (*variantResult, error) {
+	discovery, err := env.DiscoverLS(pluginruntime.LSDiscoverOptions{
 		ProcessName: "language_server",
 		Markers:     []string{v.Marker},
 		CSRFFlag:    "--csrf_token",
 		PortFlag:    "--extension_server_port",
 		ExtraFlags:  []string{"--windsurf_version"},
 	})
+	if errors.Is(err, pluginruntime.ErrLSDiscoveryUnsupported) {
+		return nil, err
+	}
 	if err != nil || discovery == nil {
-		return nil
+		return nil, nil
 	}
 
-	port, scheme, ok := p.findWorkingPort(ctx, discovery, v.IdeName)
+	port, scheme, ok := p.findWorkingPort(ctx, env, discovery, v.IdeName)
 	if !ok {
-		return nil
+		return nil, nil
 	}
 
-	apiKey := p.loadAPIKey(v)
+	stateDB := v.stateDBPath()
+	apiKey := p.loadAPIKey(stateDB)
 	if apiKey == "" {
-		return nil
+		return nil, nil
 	}
 
 	version := "unknown"
@@ -94,13 +120,13 @@ func (p *Plugin) probeVariant(ctx context.Context, v variant) *variantResult {
 		"locale":           "en",
 	}
 
-	data := p.callLS(ctx, port, scheme, discovery.CSRF, "GetUserStatus", map[string]any{"metadata": metadata})
+	data := p.callLS(ctx, env, port, scheme, discovery.CSRF, "GetUserStatus", map[string]any{"metadata": metadata})
 	if data == nil {
-		return nil
+		return nil, nil
 	}
 	userStatus, ok := pluginruntime.GetMap(data, "userStatus")
 	if !ok {
-		return nil
+		return nil, nil
 	}
 	planStatus, _ := pluginruntime.GetMap(userStatus, "planStatus")
 	planInfo, _ := pluginruntime.GetMap(planStatus, "planInfo")
@@ -137,24 +163,12 @@ func (p *Plugin) probeVariant(ctx context.Context, v variant) *variantResult {
 		lines = append(lines, openusage.NewBadgeLine("Credits", "Unlimited", openusage.TextLineOptions{}))
 	}
 
-	return &variantResult{Plan: plan, Lines: lines}
+	return &variantResult{Plan: plan, Lines: lines, Source: stateDB}, nil
 }
 
-func (p *Plugin) loadAPIKey(v variant) string {
-	rowsJSON, err := pluginruntime.SQLiteQuery(v.StateDB, "SELECT value FROM ItemTable WHERE key = 'windsurfAuthStatus' LIMIT 1")
-	if err != nil {
-		return ""
-	}
-	rows, ok := pluginruntime.TryParseJSONArray(rowsJSON)
-	if !ok || len(rows) == 0 {
-		return ""
-	}
-	row, ok := pluginruntime.Map(rows[0])
-	if !ok {
-		return ""
-	}
-	value, ok := pluginruntime.GetString(row, "value")
-	if !ok {
+func (p *Plugin) loadAPIKey(stateDB string) string {
+	value, ok, err := pluginruntime.ItemTable{Path: stateDB}.Get("windsurfAuthStatus")
+	if err != nil || !ok {
 		return ""
 	}
 	auth, ok := pluginruntime.TryParseJSONMap(value)
@@ -165,12 +179,12 @@ func (p *Plugin) loadAPIKey(v variant) string {
 	return apiKey
 }
 
-func (p *Plugin) findWorkingPort(ctx context.Context, discovery *pluginruntime.LSDiscoverResult, ideName string) (int, string, bool) {
+func (p *Plugin) findWorkingPort(ctx context.Context, env *pluginruntime.Env, discovery *pluginruntime.LSDiscoverResult, ideName string) (int, string, bool) {
 	for _, port := range discovery.Ports {
-		if p.probePort(ctx, "https", port, discovery.CSRF, ideName) {
+		if p.probePort(ctx, env, "https", port, discovery.CSRF, ideName) {
 			return port, "https", true
 		}
-		if p.probePort(ctx, "http", port, discovery.CSRF, ideName) {
+		if p.probePort(ctx, env, "http", port, discovery.CSRF, ideName) {
 			return port, "http", true
 		}
 	}
@@ -180,7 +194,7 @@ func (p *Plugin) findWorkingPort(ctx context.Context, discovery *pluginruntime.L
 	return 0, "", false
 }
 
-func (p *Plugin) probePort(ctx context.Context, scheme string, port int, csrf, ideName string) bool {
+func (p *Plugin) probePort(ctx context.Context, env *pluginruntime.Env, scheme string, port int, csrf, ideName string) bool {
 	body, _ := json.Marshal(map[string]any{
 		"context": map[string]any{
 			"properties": map[string]string{
@@ -193,13 +207,13 @@ func (p *Plugin) probePort(ctx context.Context, scheme string, port int, csrf, i
 		},
 	})
 
-	_, err := pluginruntime.CallLS(ctx, scheme, port, csrf, lsService+"/GetUnleashData", "POST", string(body), 5*time.Second)
+	_, err := env.CallLS(ctx, scheme, port, csrf, lsService+"/GetUnleashData", "POST", string(body), 5*time.Second)
 	return err == nil
 }
 
-func (p *Plugin) callLS(ctx context.Context, port int, scheme, csrf, method string, body any) map[string]any {
+func (p *Plugin) callLS(ctx context.Context, env *pluginruntime.Env, port int, scheme, csrf, method string, body any) map[string]any {
 	payload, _ := json.Marshal(body)
-	resp, err := pluginruntime.CallLS(ctx, scheme, port, csrf, lsService+"/"+method, "POST", string(payload), 10*time.Second)
+	resp, err := env.CallLS(ctx, scheme, port, csrf, lsService+"/"+method, "POST", string(payload), 10*time.Second)
 	if err != nil {
 		return nil
 	}
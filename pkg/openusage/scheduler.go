@@ -0,0 +1,189 @@
+package openusage
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPollInterval = 5 * time.Minute
+	DefaultPollJitter   = 0.1
+	DefaultMaxBackoff   = time.Hour
+)
+
+type SchedulerOptions struct {
+	// Interval is the default poll interval. Zero uses DefaultPollInterval.
+	Interval time.Duration
+	// Intervals overrides Interval per plugin ID. A negative value disables
+	// polling for that plugin.
+	Intervals map[string]time.Duration
+	// Jitter is the fraction of each delay that is randomised in either
+	// direction. Zero uses DefaultPollJitter; a negative value disables jitter.
+	Jitter float64
+	// MaxBackoff caps the delay after consecutive errors. Zero uses DefaultMaxBackoff.
+	MaxBackoff time.Duration
+}
+
+// Scheduler polls every implemented plugin on its own interval and keeps the
+// Manager's cache warm, so reads return the latest snapshot immediately.
+// Failed polls back off exponentially up to MaxBackoff.
+type Scheduler struct {
+	manager    *Manager
+	interval   time.Duration
+	intervals  map[string]time.Duration
+	jitter     float64
+	maxBackoff time.Duration
+	random     func() float64
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running []string
+}
+
+func NewScheduler(manager *Manager, opts SchedulerOptions) *Scheduler {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	jitter := opts.Jitter
+	if jitter == 0 {
+		jitter = DefaultPollJitter
+	}
+	if jitter < 0 {
+		jitter = 0
+	}
+
+	maxBackoff := opts.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	intervals := make(map[string]time.Duration, len(opts.Intervals))
+	for id, d := range opts.Intervals {
+		intervals[id] = d
+	}
+
+	return &Scheduler{
+		manager:    manager,
+		interval:   interval,
+		intervals:  intervals,
+		jitter:     jitter,
+		maxBackoff: maxBackoff,
+		random:     rand.Float64,
+	}
+}
+
+// Start launches one polling loop per implemented plugin. Each plugin is
+// polled once right away. Loops run until ctx is done or Stop is called.
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return errors.New("scheduler already started")
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.running = s.running[:0]
+
+	for _, id := range s.manager.PluginIDs() {
+		if !s.manager.HasImplementation(id) {
+			continue
+		}
+		interval := s.intervalFor(id)
+		if interval < 0 {
+			continue
+		}
+
+		s.manager.setPolled(id, true)
+		s.running = append(s.running, id)
+		s.wg.Add(1)
+		go func(id string, interval time.Duration) {
+			defer s.wg.Done()
+			s.poll(runCtx, id, interval)
+		}(id, interval)
+	}
+	return nil
+}
+
+// Stop cancels all polling loops and waits for them to return.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.cancel = nil
+	running := s.running
+	s.running = nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	s.wg.Wait()
+
+	for _, id := range running {
+		s.manager.setPolled(id, false)
+	}
+}
+
+func (s *Scheduler) intervalFor(id string) time.Duration {
+	if d, ok := s.intervals[id]; ok && d != 0 {
+		return d
+	}
+	return s.interval
+}
+
+func (s *Scheduler) poll(ctx context.Context, id string, interval time.Duration) {
+	failures := 0
+	for {
+		output, err := s.manager.Refresh(ctx, id)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil || output.Error != "" {
+			failures++
+		} else {
+			failures = 0
+		}
+
+		timer := time.NewTimer(s.nextDelay(interval, failures))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Scheduler) nextDelay(interval time.Duration, failures int) time.Duration {
+	delay := backoffDelay(interval, s.maxBackoff, failures)
+	if s.jitter == 0 {
+		return delay
+	}
+	// Spread the delay uniformly over [delay*(1-jitter), delay*(1+jitter)].
+	factor := 1 + s.jitter*(2*s.random()-1)
+	return time.Duration(float64(delay) * factor)
+}
+
+// backoffDelay doubles interval for each consecutive failure, capped at
+// maxBackoff (or at interval itself when that is already longer).
+func backoffDelay(interval, maxBackoff time.Duration, failures int) time.Duration {
+	if maxBackoff < interval {
+		maxBackoff = interval
+	}
+	delay := interval
+	for i := 0; i < failures; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,120 @@
+package openusage
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		interval   time.Duration
+		maxBackoff time.Duration
+		failures   int
+		want       time.Duration
+	}{
+		{interval: time.Minute, maxBackoff: time.Hour, failures: 0, want: time.Minute},
+		{interval: time.Minute, maxBackoff: time.Hour, failures: 1, want: 2 * time.Minute},
+		{interval: time.Minute, maxBackoff: time.Hour, failures: 3, want: 8 * time.Minute},
+		{interval: time.Minute, maxBackoff: time.Hour, failures: 10, want: time.Hour},
+		{interval: 2 * time.Hour, maxBackoff: time.Hour, failures: 2, want: 2 * time.Hour},
+	}
+
+	for _, tc := range cases {
+		if got := backoffDelay(tc.interval, tc.maxBackoff, tc.failures); got != tc.want {
+			t.Fatalf("backoffDelay(%s, %s, %d) = %s, want %s", tc.interval, tc.maxBackoff, tc.failures, got, tc.want)
+		}
+	}
+}
+
+func TestSchedulerNextDelayAppliesJitter(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduler(&Manager{}, SchedulerOptions{Interval: time.Minute, Jitter: 0.5})
+
+	s.random = func() float64 { return 0 }
+	if got := s.nextDelay(time.Minute, 0); got != 30*time.Second {
+		t.Fatalf("unexpected low jittered delay: %s", got)
+	}
+	s.random = func() float64 { return 1 }
+	if got := s.nextDelay(time.Minute, 0); got != 90*time.Second {
+		t.Fatalf("unexpected high jittered delay: %s", got)
+	}
+}
+
+func TestSchedulerPollsAndServesSnapshot(t *testing.T) {
+	t.Parallel()
+
+	var okCalls, failCalls atomic.Int32
+	manager, err := NewManager(Options{
+		DataDir:  t.TempDir(),
+		CacheTTL: time.Nanosecond,
+	}, []Plugin{
+		stubPlugin{
+			id: "ok",
+			fn: func(_ context.Context, _ *pluginruntime.Env) (QueryResult, error) {
+				okCalls.Add(1)
+				return QueryResult{Lines: []MetricLine{NewTextLine("Status", "ok", TextLineOptions{})}}, nil
+			},
+		},
+		stubPlugin{
+			id: "failing",
+			fn: func(_ context.Context, _ *pluginruntime.Env) (QueryResult, error) {
+				failCalls.Add(1)
+				return QueryResult{}, errors.New("boom")
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	scheduler := NewScheduler(manager, SchedulerOptions{
+		Interval:   10 * time.Millisecond,
+		Jitter:     -1,
+		MaxBackoff: time.Hour,
+	})
+	if err := scheduler.Start(context.Background()); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if err := scheduler.Start(context.Background()); err == nil {
+		t.Fatalf("expected second Start to fail")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for okCalls.Load() < 6 {
+		if time.Now().After(deadline) {
+			t.Fatalf("scheduler did not keep polling, calls=%d", okCalls.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	before := okCalls.Load()
+	out, err := manager.QueryOne(context.Background(), "ok")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	if !out.Cached {
+		t.Fatalf("expected polled snapshot to be served from cache")
+	}
+
+	scheduler.Stop()
+	if okCalls.Load() < before {
+		t.Fatalf("call counter went backwards")
+	}
+	if manager.isPolled("ok") {
+		t.Fatalf("Stop should clear polled state")
+	}
+
+	// 10ms, 20ms, 40ms, 80ms... the failing plugin should have been polled far
+	// fewer times than the healthy one.
+	if fails, oks := failCalls.Load(), okCalls.Load(); fails >= oks {
+		t.Fatalf("expected backoff to slow failing plugin: fails=%d oks=%d", fails, oks)
+	}
+}
@@ -1,6 +1,9 @@
 package openusage
 
-import "math"
+import (
+	"math"
+	"time"
+)
 
 const (
 	LineTypeText     = "text"
@@ -8,6 +11,14 @@ const (
 	LineTypeBadge    = "badge"
 )
 
+// Line scopes from the manifest. ScopePrimary is not declared by manifests;
+// as a filter it selects lines with a primaryOrder.
+const (
+	ScopeOverview = "overview"
+	ScopeDetail   = "detail"
+	ScopePrimary  = "primary"
+)
+
 const (
 	FormatKindPercent = "percent"
 	FormatKindDollars = "dollars"
@@ -31,20 +42,54 @@ type MetricLine struct {
 	PeriodDurationMs *int64          `json:"periodDurationMs,omitempty"`
 	Color            *string         `json:"color,omitempty"`
 	Subtitle         *string         `json:"subtitle,omitempty"`
+	Forecast         *Forecast       `json:"forecast,omitempty"`
+	// Scope and PrimaryOrder are copied from the manifest line with the same
+	// label.
+	Scope        string `json:"scope,omitempty"`
+	PrimaryOrder *int   `json:"primaryOrder,omitempty"`
 }
 
 type PluginOutput struct {
-	ProviderID  string       `json:"providerId"`
+	ProviderID string `json:"providerId"`
+	// Provider is the plugin behind ProviderID and Account the account name
+	// for instances such as "copilot@work", so outputs can be grouped.
+	Provider    string       `json:"provider,omitempty"`
+	Account     string       `json:"account,omitempty"`
 	DisplayName string       `json:"displayName"`
 	Plan        string       `json:"plan,omitempty"`
 	Lines       []MetricLine `json:"lines"`
 	IconURL     string       `json:"iconUrl,omitempty"`
 	Error       string       `json:"error,omitempty"`
+	// ErrorDetail classifies Error when the failure is known.
+	ErrorDetail *PluginError `json:"errorDetail,omitempty"`
+	FetchedAt   string       `json:"fetchedAt,omitempty"`
+	Cached      bool         `json:"cached"`
+	// Source is where the plugin read its credentials, e.g. a state database.
+	Source string `json:"source,omitempty"`
+}
+
+// HistorySeries is the recorded history of one progress line, as served by
+// /v1/history.
+type HistorySeries struct {
+	ProviderID string         `json:"providerId"`
+	Label      string         `json:"label"`
+	Format     string         `json:"format,omitempty"`
+	Points     []HistoryPoint `json:"points"`
+}
+
+// HistoryPoint is one recorded reading of a progress line.
+type HistoryPoint struct {
+	Time     time.Time `json:"time"`
+	Used     float64   `json:"used"`
+	Limit    float64   `json:"limit"`
+	ResetsAt string    `json:"resetsAt,omitempty"`
 }
 
 type QueryResult struct {
 	Plan  string
 	Lines []MetricLine
+	// Source is copied to PluginOutput.Source, also when Query fails.
+	Source string
 }
 
 type ProgressLineOptions struct {
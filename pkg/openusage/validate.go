@@ -0,0 +1,310 @@
+package openusage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ManifestSchemaVersions lists the plugin.json schema versions this package
+// understands. Version 1 is the upstream OpenUsage format; version 2 adds
+// permissions and limits for external, JavaScript and WebAssembly plugins.
+var ManifestSchemaVersions = []int{1, 2}
+
+var (
+	manifestFieldsV1 = []string{"schemaVersion", "id", "name", "version", "entry", "icon", "brandColor", "lines"}
+	manifestFieldsV2 = []string{"permissions", "limits"}
+	lineFields       = []string{"type", "label", "scope", "primaryOrder"}
+	lineTypes        = []string{LineTypeText, LineTypeProgress, LineTypeBadge}
+	lineScopes       = []string{ScopeOverview, ScopeDetail}
+
+	pluginIDPattern  = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	hexColorPattern  = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+	hostPatternRegex = regexp.MustCompile(`^(\*|(\*\.)?[a-zA-Z0-9.-]+)$`)
+)
+
+// ManifestError is one problem found in a plugin.json.
+type ManifestError struct {
+	Path string
+	// Field is a JSON path such as "lines[2].scope"; empty for problems with
+	// the whole file.
+	Field  string
+	Reason string
+}
+
+func (e ManifestError) Error() string {
+	if e.Field == "" {
+		return e.Path + ": " + e.Reason
+	}
+	return e.Path + ": " + e.Field + ": " + e.Reason
+}
+
+// ManifestErrors is every problem found by LintManifests.
+type ManifestErrors []ManifestError
+
+func (e ManifestErrors) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("%d invalid plugin manifest field(s):", len(e)))
+	for _, problem := range e {
+		lines = append(lines, "  "+problem.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LintManifests strictly validates every plugin.json below pluginsDir and
+// reports all problems, including IDs used by more than one manifest. Entries
+// are loaded with factories to check them. The error is only set when
+// pluginsDir cannot be read.
+func LintManifests(pluginsDir string, factories PluginFactories) (ManifestErrors, error) {
+	entries, err := os.ReadDir(pluginsDir)
+	if err != nil {
+		return nil, fmt.Errorf("read plugins dir: %w", err)
+	}
+
+	var problems ManifestErrors
+	seen := make(map[string]string)
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		pluginDir := filepath.Join(pluginsDir, entry.Name())
+		loaded, found := ValidateManifest(pluginDir, factories)
+		problems = append(problems, found...)
+
+		id := loaded.Manifest.ID
+		if id == "" {
+			continue
+		}
+		path := filepath.Join(pluginDir, "plugin.json")
+		if first, ok := seen[id]; ok {
+			problems = append(problems, ManifestError{Path: path, Field: "id", Reason: fmt.Sprintf("duplicates the id in %s", first)})
+			continue
+		}
+		seen[id] = path
+	}
+	return problems, nil
+}
+
+// ValidateManifest strictly validates pluginDir/plugin.json. The manifest is
+// returned as far as it could be decoded. Its entry is loaded with factories
+// to check it.
+func ValidateManifest(pluginDir string, factories PluginFactories) (LoadedManifest, []ManifestError) {
+	path := filepath.Join(pluginDir, "plugin.json")
+	loaded := LoadedManifest{PluginDir: pluginDir}
+	fail := func(field, format string, args ...any) []ManifestError {
+		return []ManifestError{{Path: path, Field: field, Reason: fmt.Sprintf(format, args...)}}
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return loaded, fail("", "missing plugin.json")
+	}
+	if err != nil {
+		return loaded, fail("", "cannot read: %v", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, col := position(data, syntaxErr.Offset)
+			return loaded, fail("", "invalid JSON at line %d, column %d: %v", line, col, syntaxErr)
+		}
+		return loaded, fail("", "must be a JSON object")
+	}
+	if err := json.Unmarshal(data, &loaded.Manifest); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return loaded, fail(typeErr.Field, "expected %s, got %s", typeErr.Type, typeErr.Value)
+		}
+		return loaded, fail("", "%v", err)
+	}
+
+	v := &manifestValidator{path: path, dir: pluginDir, loaded: loaded, factories: factories}
+	v.validate(fields)
+	return loaded, v.problems
+}
+
+type manifestValidator struct {
+	path      string
+	dir       string
+	loaded    LoadedManifest
+	factories PluginFactories
+	problems  []ManifestError
+}
+
+func (v *manifestValidator) add(field, format string, args ...any) {
+	v.problems = append(v.problems, ManifestError{Path: v.path, Field: field, Reason: fmt.Sprintf(format, args...)})
+}
+
+func (v *manifestValidator) validate(fields map[string]json.RawMessage) {
+	m := v.loaded.Manifest
+
+	version := m.SchemaVersion
+	switch {
+	case version == 0:
+		v.add("schemaVersion", "is required")
+	case !slices.Contains(ManifestSchemaVersions, version):
+		v.add("schemaVersion", "unsupported version %d (supported: %s)", version, joinInts(ManifestSchemaVersions))
+	}
+
+	for _, key := range sortedKeys(fields) {
+		switch {
+		case slices.Contains(manifestFieldsV1, key):
+		case slices.Contains(manifestFieldsV2, key):
+			if version == 1 {
+				v.add(key, "requires schemaVersion 2")
+			}
+		default:
+			v.add(key, "unknown field")
+		}
+	}
+
+	switch {
+	case m.ID == "":
+		v.add("id", "is required")
+	case !pluginIDPattern.MatchString(m.ID):
+		v.add("id", "must use lowercase letters, digits, '-' or '_'")
+	}
+	if strings.TrimSpace(m.Name) == "" {
+		v.add("name", "is required")
+	}
+	if m.BrandColor != "" && !hexColorPattern.MatchString(m.BrandColor) {
+		v.add("brandColor", "must be a #rrggbb color")
+	}
+
+	if m.Icon != "" && v.checkFile("icon", m.Icon) && !strings.EqualFold(filepath.Ext(m.Icon), ".svg") {
+		v.add("icon", "must be an .svg file")
+	}
+	if m.Entry != "" && v.checkFile("entry", m.Entry) {
+		if _, err := v.factories.New(v.loaded); err != nil {
+			v.add("entry", "%v", err)
+		}
+	}
+
+	v.validateLines(fields["lines"])
+	v.validatePermissions()
+	if m.Limits.MemoryMB < 0 {
+		v.add("limits.memoryMb", "must not be negative")
+	}
+	if m.Limits.MemoryMB > MaxWASMMemoryMB {
+		v.add("limits.memoryMb", "must not exceed %d", MaxWASMMemoryMB)
+	}
+	if m.Limits.CPUMs < 0 {
+		v.add("limits.cpuMs", "must not be negative")
+	}
+}
+
+// checkFile reports whether name is an existing file inside the plugin dir.
+func (v *manifestValidator) checkFile(field, name string) bool {
+	if !filepath.IsLocal(name) {
+		v.add(field, "must be a relative path inside the plugin dir")
+		return false
+	}
+	info, err := os.Stat(filepath.Join(v.dir, name))
+	if err != nil || info.IsDir() {
+		v.add(field, "file %s does not exist", name)
+		return false
+	}
+	return true
+}
+
+func (v *manifestValidator) validateLines(raw json.RawMessage) {
+	var rawLines []map[string]json.RawMessage
+	_ = json.Unmarshal(raw, &rawLines)
+
+	labels := make(map[string]int)
+	orders := make(map[int]int)
+	for i, line := range v.loaded.Manifest.Lines {
+		field := fmt.Sprintf("lines[%d]", i)
+		if i < len(rawLines) {
+			for _, key := range sortedKeys(rawLines[i]) {
+				if !slices.Contains(lineFields, key) {
+					v.add(field+"."+key, "unknown field")
+				}
+			}
+		}
+
+		if !slices.Contains(lineTypes, line.Type) {
+			v.add(field+".type", "must be one of %s", strings.Join(lineTypes, ", "))
+		}
+		switch first, dup := labels[line.Label]; {
+		case line.Label == "":
+			v.add(field+".label", "is required")
+		case dup:
+			v.add(field+".label", "duplicates lines[%d].label", first)
+		default:
+			labels[line.Label] = i
+		}
+		if !slices.Contains(lineScopes, line.Scope) {
+			v.add(field+".scope", "must be one of %s", strings.Join(lineScopes, ", "))
+		}
+
+		if line.PrimaryOrder == nil {
+			continue
+		}
+		order := *line.PrimaryOrder
+		switch first, dup := orders[order]; {
+		case line.Type != LineTypeProgress:
+			v.add(field+".primaryOrder", "is only allowed on progress lines")
+		case order < 1:
+			v.add(field+".primaryOrder", "must be at least 1")
+		case dup:
+			v.add(field+".primaryOrder", "duplicates lines[%d].primaryOrder", first)
+		default:
+			orders[order] = i
+		}
+	}
+}
+
+func (v *manifestValidator) validatePermissions() {
+	perms := v.loaded.Manifest.Permissions
+	for i, host := range perms.HTTP {
+		if !hostPatternRegex.MatchString(host) {
+			v.add(fmt.Sprintf("permissions.http[%d]", i), "must be a host name, *.domain or *")
+		}
+	}
+	for _, list := range []struct {
+		name  string
+		paths []string
+	}{{"files", perms.Files}, {"sqlite", perms.SQLite}} {
+		for i, path := range list.paths {
+			if !filepath.IsAbs(path) && path != "~" && !strings.HasPrefix(path, "~/") {
+				v.add(fmt.Sprintf("permissions.%s[%d]", list.name, i), "must be an absolute path or start with ~/")
+			}
+		}
+	}
+}
+
+// position converts a json.SyntaxError offset, which counts the offending
+// byte, into a 1-based line and column.
+func position(data []byte, offset int64) (line, col int) {
+	offset = min(max(offset-1, 0), int64(len(data)))
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, ", ")
+}
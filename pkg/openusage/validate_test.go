@@ -0,0 +1,149 @@
+package openusage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeManifest(t *testing.T, pluginsDir, dir, manifest string) {
+	t.Helper()
+
+	pluginDir := filepath.Join(pluginsDir, dir)
+	if err := os.MkdirAll(pluginDir, 0o755); err != nil {
+		t.Fatalf("mkdir plugin dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(pluginDir, "plugin.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+}
+
+// brokenFactories loads ".stub" entries like a runtime that cannot compile
+// them.
+var brokenFactories = PluginFactories{".stub": func(loaded LoadedManifest) (Plugin, error) {
+	return nil, fmt.Errorf("compile plugin %s: unexpected end of input", loaded.Manifest.ID)
+}}
+
+// writeStubPlugin writes a manifest whose entry is loaded by brokenFactories.
+func writeStubPlugin(t *testing.T, pluginsDir, id string) {
+	t.Helper()
+
+	writeManifest(t, pluginsDir, id, `{"schemaVersion":1,"id":"`+id+`","name":"Stub","entry":"plugin.stub"}`)
+	if err := os.WriteFile(filepath.Join(pluginsDir, id, "plugin.stub"), nil, 0o644); err != nil {
+		t.Fatalf("write entry: %v", err)
+	}
+}
+
+func problemFields(problems ManifestErrors) []string {
+	fields := make([]string, len(problems))
+	for i, problem := range problems {
+		fields[i] = filepath.Base(filepath.Dir(problem.Path)) + ":" + problem.Field
+	}
+	return fields
+}
+
+func TestLintManifestsReportsEveryProblem(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writeManifest(t, pluginsDir, "good", `{"schemaVersion":2,"id":"good","name":"Good","permissions":{"http":["*.example.com"]},
+		"lines":[{"type":"progress","label":"Session","scope":"overview","primaryOrder":1}]}`)
+	writeManifest(t, pluginsDir, "bad", `{"schemaVersion":1,"id":"Bad!","brandColor":"red","entry":"../escape","limits":{"cpuMs":-1,"memoryMb":8192},"extra":true,
+		"lines":[{"type":"meter","label":"A","scope":"overview"},{"type":"text","label":"A","scope":"detail","primaryOrder":1,"color":"red"}]}`)
+	writeManifest(t, pluginsDir, "copy", `{"schemaVersion":2,"id":"good","name":"Copy"}`)
+	writeManifest(t, pluginsDir, "broken", "{\n  \"id\": \"broken\",\n}")
+	writeManifest(t, pluginsDir, "typed", `{"schemaVersion":"1","id":"typed","name":"Typed"}`)
+	writeManifest(t, pluginsDir, "future", `{"schemaVersion":9,"id":"future","name":"Future"}`)
+	if err := os.MkdirAll(filepath.Join(pluginsDir, "empty"), 0o755); err != nil {
+		t.Fatalf("mkdir empty: %v", err)
+	}
+
+	problems, err := LintManifests(pluginsDir, nil)
+	if err != nil {
+		t.Fatalf("LintManifests error: %v", err)
+	}
+	want := []string{
+		"bad:extra",
+		"bad:limits",
+		"bad:id",
+		"bad:name",
+		"bad:brandColor",
+		"bad:entry",
+		"bad:lines[0].type",
+		"bad:lines[1].color",
+		"bad:lines[1].label",
+		"bad:lines[1].primaryOrder",
+		"bad:limits.memoryMb",
+		"bad:limits.cpuMs",
+		"broken:",
+		"empty:",
+		"future:schemaVersion",
+		"good:id",
+		"typed:schemaVersion",
+	}
+	if got := problemFields(problems); !slices.Equal(got, want) {
+		t.Fatalf("unexpected problems:\n got %q\nwant %q\n%v", got, want, problems)
+	}
+
+	reasons := map[string]string{}
+	for _, problem := range problems {
+		reasons[problemFields(ManifestErrors{problem})[0]] = problem.Reason
+	}
+	for field, reason := range map[string]string{
+		"broken:":              `invalid JSON at line 3, column 1: invalid character '}' looking for beginning of object key string`,
+		"typed:schemaVersion":  "expected int, got string",
+		"future:schemaVersion": "unsupported version 9 (supported: 1, 2)",
+		"bad:limits":           "requires schemaVersion 2",
+		"bad:limits.memoryMb":  "must not exceed 4096",
+		"good:id":              "duplicates the id in " + filepath.Join(pluginsDir, "copy", "plugin.json"),
+		"empty:":               "missing plugin.json",
+	} {
+		if reasons[field] != reason {
+			t.Fatalf("%s: expected %q, got %q", field, reason, reasons[field])
+		}
+	}
+}
+
+func TestValidateManifestChecksEntry(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writeStubPlugin(t, pluginsDir, "stub")
+
+	_, problems := ValidateManifest(filepath.Join(pluginsDir, "stub"), brokenFactories)
+	if len(problems) != 1 || problems[0].Field != "entry" || problems[0].Reason != "compile plugin stub: unexpected end of input" {
+		t.Fatalf("expected entry problem, got %v", problems)
+	}
+
+	// Without a factory the entry must be an executable.
+	_, problems = ValidateManifest(filepath.Join(pluginsDir, "stub"), nil)
+	if len(problems) != 1 || problems[0].Field != "entry" || !strings.Contains(problems[0].Reason, "is not executable") {
+		t.Fatalf("expected executable problem, got %v", problems)
+	}
+}
+
+func TestNewManagerStrictManifests(t *testing.T) {
+	t.Parallel()
+
+	pluginsDir := t.TempDir()
+	writeManifest(t, pluginsDir, "bad", `{"schemaVersion":1,"id":"bad"}`)
+
+	var logs strings.Builder
+	if _, err := NewManager(Options{PluginsDir: pluginsDir, DataDir: t.TempDir(), Logger: log.New(&logs, "", 0)}, nil); err != nil {
+		t.Fatalf("NewManager error without strict: %v", err)
+	}
+	if !strings.Contains(logs.String(), "invalid plugin manifest: "+filepath.Join(pluginsDir, "bad", "plugin.json")+": name") {
+		t.Fatalf("expected the problem to be logged without strict, got %q", logs.String())
+	}
+
+	_, err := NewManager(Options{PluginsDir: pluginsDir, DataDir: t.TempDir(), StrictManifests: true}, nil)
+	var problems ManifestErrors
+	if !errors.As(err, &problems) || len(problems) != 1 || problems[0].Field != "name" {
+		t.Fatalf("expected name problem, got %v", err)
+	}
+}
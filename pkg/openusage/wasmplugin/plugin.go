@@ -0,0 +1,277 @@
+// Package wasmplugin runs WebAssembly plugins in a sandbox: manifest entries
+// ending in ".wasm".
+package wasmplugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+const (
+	defaultMemoryMB = 64
+	defaultCPU      = 2 * time.Second
+	pageSize        = 64 << 10
+)
+
+// compilationCache shares compiled modules between the per-query runtimes.
+var compilationCache = wazero.NewCompilationCache()
+
+// Plugin runs a WebAssembly module in a sandbox. The module has no file
+// system or network access of its own; it exports memory, alloc(size) ptr and
+// query(ptr, len) and may import openusage.host_call(methodPtr, methodLen,
+// paramsPtr, paramsLen). Both query and host_call return a packed
+// (ptr << 32 | len) JSON-RPC response held in guest memory, and host_call
+// dispatches to pluginruntime.Host under the manifest's permissions.
+//
+// Each query gets a fresh instance with a memory limit and a CPU budget that
+// counts guest execution only, not time spent in host calls. WASI is
+// available without preopened directories; stdout and stderr are logged.
+type Plugin struct {
+	id          string
+	module      []byte
+	permissions pluginruntime.Permissions
+	memoryPages uint32
+	cpuLimit    time.Duration
+}
+
+// New loads and validates a manifest's .wasm entry.
+func New(loaded openusage.LoadedManifest) (*Plugin, error) {
+	manifest := loaded.Manifest
+	if !filepath.IsLocal(manifest.Entry) {
+		return nil, fmt.Errorf("plugin %s entry %q is outside the plugin dir", manifest.ID, manifest.Entry)
+	}
+	module, err := os.ReadFile(filepath.Join(loaded.PluginDir, manifest.Entry))
+	if err != nil {
+		return nil, fmt.Errorf("read plugin %s entry: %w", manifest.ID, err)
+	}
+
+	memoryMB := manifest.Limits.MemoryMB
+	if memoryMB <= 0 {
+		memoryMB = defaultMemoryMB
+	}
+	memoryMB = min(memoryMB, openusage.MaxWASMMemoryMB)
+	cpuLimit := time.Duration(manifest.Limits.CPUMs) * time.Millisecond
+	if cpuLimit <= 0 {
+		cpuLimit = defaultCPU
+	}
+	p := &Plugin{
+		id:          manifest.ID,
+		module:      module,
+		permissions: manifest.Permissions,
+		memoryPages: uint32(memoryMB * (1 << 20) / pageSize),
+		cpuLimit:    cpuLimit,
+	}
+
+	ctx := context.Background()
+	r := p.newRuntime(ctx)
+	defer func() { _ = r.Close(ctx) }()
+	if _, err := r.CompileModule(ctx, module); err != nil {
+		return nil, fmt.Errorf("compile plugin %s: %w", manifest.ID, err)
+	}
+	return p, nil
+}
+
+// Factory is New as an openusage.PluginFactory.
+func Factory(loaded openusage.LoadedManifest) (openusage.Plugin, error) {
+	plugin, err := New(loaded)
+	if err != nil {
+		return nil, err
+	}
+	return plugin, nil
+}
+
+func (p *Plugin) ID() string {
+	return p.id
+}
+
+func (p *Plugin) newRuntime(ctx context.Context) wazero.Runtime {
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(p.memoryPages).
+		WithCloseOnContextDone(true).
+		WithCompilationCache(compilationCache)
+	return wazero.NewRuntimeWithConfig(ctx, config)
+}
+
+func (p *Plugin) Query(ctx context.Context, env *pluginruntime.Env) (openusage.QueryResult, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	budget := &cpuBudget{remaining: p.cpuLimit, cancel: cancel}
+
+	r := p.newRuntime(runCtx)
+	defer func() { _ = r.Close(context.Background()) }()
+
+	if _, err := wasi_snapshot_preview1.Instantiate(runCtx, r); err != nil {
+		return openusage.QueryResult{}, fmt.Errorf("instantiate wasi: %w", err)
+	}
+	host := &pluginruntime.Host{Env: env, Permissions: p.permissions}
+	hostCall := func(ctx context.Context, m api.Module, methodPtr, methodLen, paramsPtr, paramsLen uint32) uint64 {
+		budget.pause()
+		response := callHost(ctx, host, m, methodPtr, methodLen, paramsPtr, paramsLen)
+		budget.resume()
+		packed, err := writeGuest(ctx, m, response)
+		if err != nil {
+			panic(err)
+		}
+		return packed
+	}
+	if _, err := r.NewHostModuleBuilder("openusage").
+		NewFunctionBuilder().WithFunc(hostCall).Export("host_call").
+		Instantiate(runCtx); err != nil {
+		return openusage.QueryResult{}, fmt.Errorf("instantiate host module: %w", err)
+	}
+
+	output := pluginruntime.NewOutputTail(env.Logger)
+	defer output.Flush()
+	config := wazero.NewModuleConfig().
+		WithStartFunctions("_initialize").
+		WithStdout(output).
+		WithStderr(output)
+
+	budget.resume()
+	defer budget.pause()
+
+	result, err := p.run(runCtx, r, config, env)
+	if err == nil {
+		return result, nil
+	}
+	if budget.isExceeded() {
+		err = fmt.Errorf("plugin exceeded its CPU limit of %s", p.cpuLimit)
+	} else if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	if line := output.LastLine(); line != "" {
+		return result, fmt.Errorf("%w: %s", err, line)
+	}
+	return result, err
+}
+
+func (p *Plugin) run(ctx context.Context, r wazero.Runtime, config wazero.ModuleConfig, env *pluginruntime.Env) (openusage.QueryResult, error) {
+	module, err := r.InstantiateWithConfig(ctx, p.module, config)
+	if err != nil {
+		return openusage.QueryResult{}, fmt.Errorf("instantiate plugin: %w", err)
+	}
+	query := module.ExportedFunction("query")
+	if query == nil {
+		return openusage.QueryResult{}, errors.New("plugin does not export query")
+	}
+
+	input, err := openusage.ExternalQueryParams(env)
+	if err != nil {
+		return openusage.QueryResult{}, err
+	}
+	packed, err := writeGuest(ctx, module, input)
+	if err != nil {
+		return openusage.QueryResult{}, err
+	}
+	results, err := query.Call(ctx, packed>>32, packed&0xffffffff)
+	if err != nil {
+		return openusage.QueryResult{}, fmt.Errorf("plugin query failed: %w", err)
+	}
+	if len(results) != 1 {
+		return openusage.QueryResult{}, errors.New("plugin query must return one i64")
+	}
+	data, err := readGuest(module, results[0])
+	if err != nil {
+		return openusage.QueryResult{}, err
+	}
+	return openusage.DecodeAnswer(data)
+}
+
+// callHost reads a host_call request from guest memory and returns the
+// encoded JSON-RPC response.
+func callHost(ctx context.Context, host *pluginruntime.Host, m api.Module, methodPtr, methodLen, paramsPtr, paramsLen uint32) []byte {
+	var value any
+	var err error
+	method, ok := m.Memory().Read(methodPtr, methodLen)
+	params, paramsOK := m.Memory().Read(paramsPtr, paramsLen)
+	if !ok || !paramsOK {
+		err = errors.New("host_call arguments out of bounds")
+	} else {
+		value, err = host.Call(ctx, string(method), json.RawMessage(params))
+	}
+	return openusage.EncodeHostResponse(value, err)
+}
+
+// writeGuest copies data into memory allocated by the guest's alloc export
+// and returns it packed as ptr << 32 | len.
+func writeGuest(ctx context.Context, m api.Module, data []byte) (uint64, error) {
+	alloc := m.ExportedFunction("alloc")
+	if alloc == nil {
+		return 0, errors.New("plugin does not export alloc")
+	}
+	results, err := alloc.Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("plugin alloc failed: %w", err)
+	}
+	if len(results) != 1 {
+		return 0, errors.New("plugin alloc must return one i32")
+	}
+	ptr := uint32(results[0])
+	if !m.Memory().Write(ptr, data) {
+		return 0, errors.New("plugin alloc returned memory out of bounds")
+	}
+	return uint64(ptr)<<32 | uint64(len(data)), nil
+}
+
+func readGuest(m api.Module, packed uint64) ([]byte, error) {
+	ptr, size := uint32(packed>>32), uint32(packed)
+	if size > openusage.ExternalMessageLimit {
+		return nil, fmt.Errorf("plugin output exceeds %d bytes", openusage.ExternalMessageLimit)
+	}
+	data, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		return nil, errors.New("plugin output out of bounds")
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// cpuBudget cancels a query once the guest has run for longer than the
+// remaining budget; the clock is paused during host calls.
+type cpuBudget struct {
+	mu        sync.Mutex
+	remaining time.Duration
+	started   time.Time
+	timer     *time.Timer
+	cancel    context.CancelFunc
+	exceeded  bool
+}
+
+func (b *cpuBudget) resume() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.started = time.Now()
+	b.timer = time.AfterFunc(b.remaining, func() {
+		b.mu.Lock()
+		b.exceeded = true
+		b.mu.Unlock()
+		b.cancel()
+	})
+}
+
+func (b *cpuBudget) pause() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timer != nil && b.timer.Stop() {
+		b.remaining -= time.Since(b.started)
+	}
+	b.timer = nil
+}
+
+func (b *cpuBudget) isExceeded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exceeded
+}
@@ -0,0 +1,214 @@
+package wasmplugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deicod/gopenusage/pkg/openusage"
+	"github.com/deicod/gopenusage/pkg/openusage/pluginruntime"
+)
+
+// The tests assemble small modules by hand: they import openusage.host_call,
+// export memory, a bump alloc and query, whose body varies per test.
+
+func uleb(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			out = append(out, b|0x80)
+			continue
+		}
+		return append(out, b)
+	}
+}
+
+func sleb(v int64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func wasmVec(items ...[]byte) []byte {
+	out := uleb(uint64(len(items)))
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+func wasmName(name string) []byte {
+	return append(uleb(uint64(len(name))), name...)
+}
+
+func wasmSection(id byte, body []byte) []byte {
+	return append(append([]byte{id}, uleb(uint64(len(body)))...), body...)
+}
+
+func wasmBody(code ...byte) []byte {
+	body := append([]byte{0x00}, code...) // no locals
+	return append(uleb(uint64(len(body))), body...)
+}
+
+func i32Const(v int) []byte { return append([]byte{0x41}, sleb(int64(v))...) }
+
+func i64Const(v uint64) []byte { return append([]byte{0x42}, sleb(int64(v))...) }
+
+func packed(ptr, size int) uint64 { return uint64(ptr)<<32 | uint64(size) }
+
+func buildWASMModule(memoryPages int, data []byte, query []byte) []byte {
+	i32, i64 := byte(0x7f), byte(0x7e)
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	module = append(module, wasmSection(1, wasmVec(
+		[]byte{0x60, 1, i32, 1, i32},                // alloc
+		[]byte{0x60, 2, i32, i32, 1, i64},           // query
+		[]byte{0x60, 4, i32, i32, i32, i32, 1, i64}, // host_call
+	))...)
+	module = append(module, wasmSection(2, wasmVec(
+		append(append(wasmName("openusage"), wasmName("host_call")...), 0x00, 2),
+	))...)
+	module = append(module, wasmSection(3, wasmVec([]byte{0}, []byte{1}))...)
+	module = append(module, wasmSection(5, wasmVec(append([]byte{0x00}, uleb(uint64(memoryPages))...)))...)
+	module = append(module, wasmSection(6, wasmVec(append(append([]byte{i32, 0x01}, i32Const(4096)...), 0x0b)))...)
+	module = append(module, wasmSection(7, wasmVec(
+		append(wasmName("memory"), 0x02, 0),
+		append(wasmName("alloc"), 0x00, 1),
+		append(wasmName("query"), 0x00, 2),
+	))...)
+	alloc := wasmBody(0x23, 0, 0x23, 0, 0x20, 0, 0x6a, 0x24, 0, 0x0b)
+	module = append(module, wasmSection(10, wasmVec(alloc, wasmBody(append(query, 0x0b)...)))...)
+	segment := append(append([]byte{0x00}, append(i32Const(0), 0x0b)...), append(uleb(uint64(len(data))), data...)...)
+	return append(module, wasmSection(11, wasmVec(segment))...)
+}
+
+const wasmAnswer = `{"jsonrpc":"2.0","id":1,"result":{"plan":"Sandboxed","lines":[{"type":"text","label":"Hello","value":"wasm"}]}}`
+
+// hostCallModule calls host_call(method, params) and returns its response
+// when returnResponse is set, otherwise wasmAnswer.
+func hostCallModule(method, params string, returnResponse bool) []byte {
+	data := method + params + wasmAnswer
+	code := append(i32Const(0), i32Const(len(method))...)
+	code = append(code, i32Const(len(method))...)
+	code = append(code, i32Const(len(params))...)
+	code = append(code, 0x10, 0) // call host_call
+	if !returnResponse {
+		code = append(code, 0x1a) // drop
+		code = append(code, i64Const(packed(len(method)+len(params), len(wasmAnswer)))...)
+	}
+	return buildWASMModule(1, []byte(data), code)
+}
+
+func writeWASMPlugin(t *testing.T, pluginsDir, id, extra string, module []byte) {
+	t.Helper()
+
+	pluginDir := filepath.Join(pluginsDir, id)
+	if err := os.MkdirAll(pluginDir, 0o755); err != nil {
+		t.Fatalf("mkdir plugin dir: %v", err)
+	}
+	manifest := `{"schemaVersion":1,"id":"` + id + `","name":"Sandboxed","entry":"plugin.wasm"` + extra + `}`
+	if err := os.WriteFile(filepath.Join(pluginDir, "plugin.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(pluginDir, "plugin.wasm"), module, 0o644); err != nil {
+		t.Fatalf("write module: %v", err)
+	}
+}
+
+func queryWASM(t *testing.T, extra string, module []byte) (openusage.PluginOutput, string) {
+	t.Helper()
+
+	pluginsDir := t.TempDir()
+	writeWASMPlugin(t, pluginsDir, "sandboxed", extra, module)
+	dataDir := t.TempDir()
+	manager, err := openusage.NewManager(openusage.Options{
+		PluginsDir:      pluginsDir,
+		DataDir:         dataDir,
+		PluginFactories: openusage.PluginFactories{".wasm": Factory},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	if !manager.HasImplementation("sandboxed") {
+		t.Fatal("expected wasm plugin to be registered")
+	}
+	out, err := manager.QueryOne(context.Background(), "sandboxed")
+	if err != nil {
+		t.Fatalf("QueryOne error: %v", err)
+	}
+	return out, filepath.Join(dataDir, "plugins_data", "sandboxed")
+}
+
+func TestWASMPluginAnswersQuery(t *testing.T) {
+	t.Parallel()
+
+	out, dataDir := queryWASM(t, "", hostCallModule("state.write", `{"name":"note.txt","text":"hi"}`, false))
+	if out.Error != "" {
+		t.Fatalf("unexpected error: %s", out.Error)
+	}
+	if out.Plan != "Sandboxed" || len(out.Lines) != 1 || *out.Lines[0].Value != "wasm" {
+		t.Fatalf("unexpected output: %+v", out)
+	}
+	if text, err := pluginruntime.ReadText(filepath.Join(dataDir, "note.txt")); err != nil || text != "hi" {
+		t.Fatalf("expected state written through host_call, got %q, %v", text, err)
+	}
+}
+
+func TestWASMPluginGatesHostCalls(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name, extra, method, params, want string
+	}{
+		{"http", `,"permissions":{"http":["api.example.com"]}`, "http.request", `{"url":"https://example.com/"}`, "permission denied: http host example.com"},
+		{"file", `,"permissions":{"files":["/opt/allowed/"]}`, "fs.readText", `{"path":"/etc/passwd"}`, "permission denied: path /etc/passwd"},
+		{"sqlite", `,"permissions":{"files":["/opt/app/"]}`, "sqlite.query", `{"path":"/opt/app/state.vscdb","sql":"select 1"}`, "permission denied: path /opt/app/state.vscdb"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			out, _ := queryWASM(t, tc.extra, hostCallModule(tc.method, tc.params, true))
+			if out.Error != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, out.Error)
+			}
+		})
+	}
+}
+
+func TestWASMPluginEnforcesLimits(t *testing.T) {
+	t.Parallel()
+
+	spin := append([]byte{0x03, 0x40, 0x0c, 0x00, 0x0b}, i64Const(0)...) // loop br 0 end
+	out, _ := queryWASM(t, `,"limits":{"cpuMs":100}`, buildWASMModule(1, nil, spin))
+	if out.Error != "plugin exceeded its CPU limit of 100ms" {
+		t.Fatalf("expected CPU limit error, got %q", out.Error)
+	}
+
+	pluginsDir := t.TempDir()
+	writeWASMPlugin(t, pluginsDir, "greedy", `,"limits":{"memoryMb":1}`, buildWASMModule(32, nil, i64Const(0)))
+	_, err := New(openusage.LoadedManifest{
+		Manifest:  openusage.PluginManifest{ID: "greedy", Entry: "plugin.wasm", Limits: openusage.PluginLimits{MemoryMB: 1}},
+		PluginDir: filepath.Join(pluginsDir, "greedy"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "compile plugin greedy") {
+		t.Fatalf("expected memory limit error, got %v", err)
+	}
+
+	writeWASMPlugin(t, pluginsDir, "huge", `,"limits":{"memoryMb":8192}`, buildWASMModule(1, nil, i64Const(0)))
+	if _, err := New(openusage.LoadedManifest{
+		Manifest:  openusage.PluginManifest{ID: "huge", Entry: "plugin.wasm", Limits: openusage.PluginLimits{MemoryMB: 8192}},
+		PluginDir: filepath.Join(pluginsDir, "huge"),
+	}); err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+}